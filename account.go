@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -32,7 +33,6 @@ var lclient = &legacyClient{gclient}
 var gnetwork = snetwork.PublicNetworkPassphrase
 
 const defaultMemo = "via keybase"
-const baseReserve = 5000000
 const submitAttempts = 3
 
 // SetClientAndNetwork sets the horizon client and network. Used by stellarnet/testclient.
@@ -176,7 +176,9 @@ func (a *Account) internalNativeBalance() string {
 	return balance
 }
 
-// Balances returns all the balances for an account.
+// Balances returns all the balances for an account.  Liquidity pool
+// share balances have the liquidity_pool_shares asset type and a
+// LiquidityPoolId; see PoolShareBalances for what they are worth.
 func (a *Account) Balances() ([]horizonProtocol.Balance, error) {
 	if err := a.load(); err != nil {
 		return nil, err
@@ -202,9 +204,12 @@ func (a *Account) Assets() (res []horizonProtocolBase.Asset, complete bool, err
 }
 
 // Trustline describes a stellar trustline.  It contains an asset and a limit.
+// For liquidity pool shares, the asset type is liquidity_pool_shares and
+// LiquidityPoolID is the pool's ID.
 type Trustline struct {
 	base.Asset
-	Limit string
+	Limit           string
+	LiquidityPoolID string
 }
 
 // Trustlines returns all the trustlines for an account.
@@ -216,8 +221,9 @@ func (a *Account) Trustlines() ([]Trustline, error) {
 	tlines := make([]Trustline, len(balances))
 	for i, b := range balances {
 		tlines[i] = Trustline{
-			Asset: b.Asset,
-			Limit: b.Limit,
+			Asset:           b.Asset,
+			Limit:           b.Limit,
+			LiquidityPoolID: b.LiquidityPoolId,
 		}
 	}
 	return tlines, nil
@@ -234,30 +240,46 @@ func (a *Account) SubentryCount() (int, error) {
 }
 
 // AvailableBalanceXLM returns the native lumen balance minus any
-// required minimum balance.
+// required minimum balance and selling liabilities.
 func (a *Account) AvailableBalanceXLM() (string, error) {
 	if err := a.load(); err != nil {
 		return "", err
 	}
 
-	return a.availableBalanceXLMLoaded()
+	return a.availableBalanceXLMLoaded(networkParamsOrDefault().BaseReserve)
 }
 
 // availableBalanceXLMLoaded must be called after a.load().
-func (a *Account) availableBalanceXLMLoaded() (string, error) {
-	return AvailableBalance(a.internalNativeBalance(), int(a.internal.SubentryCount))
+func (a *Account) availableBalanceXLMLoaded(reserve int64) (string, error) {
+	spendable, err := SpendableBalances(a.internal, reserve)
+	if err != nil {
+		return "", err
+	}
+	return nativeSpendable(spendable), nil
+}
+
+func nativeSpendable(spendable []SpendableBalance) string {
+	for _, s := range spendable {
+		if s.Asset.AssetType == "native" {
+			return s.Spendable
+		}
+	}
+	return "0.0000000"
 }
 
 // AvailableBalance determines the amount of the balance that could
 // be sent to another account (leaving enough XLM in the sender's
-// account to maintain the minimum balance).
+// account to maintain the minimum balance).  It uses the base reserve
+// from NetworkParams, or the default one if the latest ledger can't be
+// loaded, and ignores liabilities and sponsorships; see
+// SpendableBalances for an account's actual spendable amounts.
 func AvailableBalance(balance string, subentryCount int) (string, error) {
 	balanceInt, err := ParseStellarAmount(balance)
 	if err != nil {
 		return "", err
 	}
 
-	minimum := baseReserve * (2 + int64(subentryCount))
+	minimum := networkParamsOrDefault().BaseReserve * (2 + int64(subentryCount))
 
 	available := balanceInt - minimum
 	if available < 0 {
@@ -268,11 +290,16 @@ func AvailableBalance(balance string, subentryCount int) (string, error) {
 }
 
 // AccountDetails contains basic details about a stellar account.
+// Available is the spendable lumen balance, and Spendable has the
+// spendable amounts of all of Balances.
 type AccountDetails struct {
 	Seqno                string
 	SubentryCount        int
 	Available            string
 	Balances             []horizonProtocol.Balance
+	Spendable            []SpendableBalance
+	BaseReserve          string
+	MinimumBalance       string
 	InflationDestination string
 }
 
@@ -282,7 +309,8 @@ func (a *Account) Details() (*AccountDetails, error) {
 		return nil, err
 	}
 
-	available, err := a.availableBalanceXLMLoaded()
+	reserve := networkParamsOrDefault().BaseReserve
+	spendable, err := SpendableBalances(a.internal, reserve)
 	if err != nil {
 		return nil, err
 	}
@@ -291,7 +319,10 @@ func (a *Account) Details() (*AccountDetails, error) {
 		Seqno:                a.internal.Sequence,
 		SubentryCount:        int(a.internal.SubentryCount),
 		Balances:             a.internal.Balances,
-		Available:            available,
+		Available:            nativeSpendable(spendable),
+		Spendable:            spendable,
+		BaseReserve:          StringFromStellarAmount(reserve),
+		MinimumBalance:       StringFromStellarAmount(MinimumBalance(a.internal, reserve)),
 		InflationDestination: a.internal.InflationDestination,
 	}
 
@@ -401,65 +432,31 @@ func (a *Account) Transactions(cursor string, limit int) (res []horizonProtocol.
 	return res, finalPage, nil
 }
 
-// RecentTransactionsAndOps returns the account's recent transactions, for
-// all types of transactions.
+// RecentTransactionsAndOps returns the account's 10 most recent
+// transactions, for all types of transactions, with the operations in
+// them that involve the account.  It is LoadTransactionsAndOps with the
+// default options.
 func (a *Account) RecentTransactionsAndOps() ([]Transaction, error) {
-	link, err := horizonLink(Client().HorizonURL, "/accounts/"+a.address.String()+"/transactions")
-	if err != nil {
-		return nil, err
-	}
-	var page TransactionsPage
-	err = getDecodeJSONStrict(link+"?order=desc&limit=10", Client().HTTP.Get, &page)
-	if err != nil {
-		return nil, errMap(err)
-	}
-
-	transactions := make([]Transaction, len(page.Embedded.Records))
-	// unfortunately, the operations are not included, so for each
-	// transaction, we need to make an additional request to get
-	// the operations.
-	for i := 0; i < len(page.Embedded.Records); i++ {
-		transactions[i] = Transaction{Internal: page.Embedded.Records[i]}
-		ops, err := a.loadOperations(transactions[i])
-		if err != nil {
-			return nil, err
-		}
-		transactions[i].Operations = ops
-	}
-
-	return transactions, nil
-}
-
-func (a *Account) loadOperations(tx Transaction) ([]Operation, error) {
-	link, err := horizonLink(Client().HorizonURL, "/transactions/"+tx.Internal.ID+"/operations")
-	if err != nil {
-		return nil, err
-	}
-	var page OperationsPage
-	err = getDecodeJSONStrict(link, Client().HTTP.Get, &page)
-	if err != nil {
-		return nil, errMap(err)
-	}
-	return page.Embedded.Records, nil
+	txs, _, err := a.LoadTransactionsAndOps(HistoryOptions{})
+	return txs, err
 }
 
 // TxPayments returns payment operations in a transaction.
-// Note: may not return all payments as the backing response is paginated.
+// It follows the next links until it has read all the pages.
 func TxPayments(txID string) ([]operations.Payment, error) {
 	txID, err := CheckTxID(txID)
 	if err != nil {
 		return nil, err
 	}
-	var page PaymentsPage
-	link, err := horizonLink(Client().HorizonURL, "/transactions/"+txID+"/payments")
-	if err != nil {
-		return nil, err
+	var payments []operations.Payment
+	it := txPaymentIterator(txID, HistoryOptions{Order: "asc"})
+	for it.Next() {
+		payments = append(payments, it.Payment())
 	}
-	err = getDecodeJSONStrict(link, Client().HTTP.Get, &page)
-	if err != nil {
-		return nil, errMap(err)
+	if err := it.Err(); err != nil {
+		return nil, err
 	}
-	return page.Embedded.Records, nil
+	return payments, nil
 }
 
 // TxDetails gets a horizonProtocol.Transaction for txID.
@@ -585,7 +582,7 @@ func MakeTimeboundsWithMaxTime(maxTime time.Time) txnbuild.Timebounds {
 
 // paymentXLM creates a payment transaction from 'from' to 'to' for 'amount' lumens.
 func paymentXLM(from SeedStr, to AddressStr, amount, memoText string) (ledger int32, txid string, attempt int, err error) {
-	sig, err := PaymentXLMTransaction(from, to, amount, memoText, Client(), nil /* timeBounds */, txnbuild.MinBaseFee)
+	sig, err := PaymentXLMTransaction(from, to, amount, memoText, Client(), nil /* timeBounds */, MinBaseFee())
 	if err != nil {
 		return 0, "", 0, errMap(err)
 	}
@@ -615,7 +612,7 @@ func PaymentXLMTransactionWithMemo(from SeedStr, to AddressStr, amount string, m
 
 // payment creates a payment transaction for a custom asset and sends it to the network.
 func payment(from SeedStr, to AddressStr, asset AssetBase, amount, memoText string) (ledger int32, txid string, attempt int, err error) {
-	sig, err := PaymentTransaction(from, to, asset, amount, memoText, Client(), nil /* timeBounds */, txnbuild.MinBaseFee)
+	sig, err := PaymentTransaction(from, to, asset, amount, memoText, Client(), nil /* timeBounds */, MinBaseFee())
 	if err != nil {
 		return 0, "", 0, errMap(err)
 	}
@@ -641,7 +638,7 @@ func PaymentTransaction(from SeedStr, to AddressStr, asset AssetBase, amount, me
 
 // pathPayment creates a transaction with a path payment operation in it and submits it to the network.
 func pathPayment(from SeedStr, to AddressStr, sendAsset AssetBase, sendAmountMax string, destAsset AssetBase, destAmount string, path []AssetBase, memoText string) (ledger int32, txid string, attempt int, err error) {
-	sig, err := PathPaymentTransaction(from, to, sendAsset, sendAmountMax, destAsset, destAmount, path, memoText, Client(), nil /* timeBounds */, txnbuild.MinBaseFee)
+	sig, err := PathPaymentTransaction(from, to, sendAsset, sendAmountMax, destAsset, destAmount, path, memoText, Client(), nil /* timeBounds */, MinBaseFee())
 	if err != nil {
 		return 0, "", 0, errMap(err)
 	}
@@ -672,7 +669,7 @@ func PathPaymentTransactionWithMemo(from SeedStr, to AddressStr, sendAsset Asset
 // createAccountXLM funds an new account 'to' from 'from' with a starting balance of 'amount'.
 // memoText is a public memo.
 func createAccountXLM(from SeedStr, to AddressStr, amount, memoText string) (ledger int32, txid string, attempt int, err error) {
-	sig, err := CreateAccountXLMTransaction(from, to, amount, memoText, Client(), nil /* timeBounds */, txnbuild.MinBaseFee)
+	sig, err := CreateAccountXLMTransaction(from, to, amount, memoText, Client(), nil /* timeBounds */, MinBaseFee())
 	if err != nil {
 		return 0, "", 0, errMap(err)
 	}
@@ -706,6 +703,7 @@ func CreateAccountXLMTransactionWithMemo(from SeedStr, to AddressStr, amount str
 // the `from` account has custom assets, this transaction will include payments of all of the
 // custom assets, and deletion of the `from` account's trustlines. AccountMergeTransaction will
 // error if the `from` account has a balance for an asset that the `to` account does not "trust."
+// Use PlanAccountMerge for accounts with offers, data entries, signers or sponsorships.
 func AccountMergeTransaction(from SeedStr, to AddressStr,
 	seqnoProvider SequenceProvider, timeBounds *txnbuild.Timebounds, baseFee uint64) (res SignResult, err error) {
 	t, err := newBaseTxSeed(from, seqnoProvider, baseFee)
@@ -808,7 +806,7 @@ func SetInflationDestinationTransaction(from SeedStr, to AddressStr, seqnoProvid
 }
 
 func setInflationDestination(from SeedStr, to AddressStr) (ledger int32, txid string, attempt int, err error) {
-	sig, err := SetInflationDestinationTransaction(from, to, Client(), nil /* timeBounds */, txnbuild.MinBaseFee)
+	sig, err := SetInflationDestinationTransaction(from, to, Client(), nil /* timeBounds */, MinBaseFee())
 	if err != nil {
 		return 0, "", 0, errMap(err)
 	}
@@ -829,7 +827,7 @@ func SetHomeDomainTransaction(from SeedStr, domain string, seqnoProvider Sequenc
 }
 
 func setHomeDomain(from SeedStr, domain string) (ledger int32, txid string, attempt int, err error) {
-	sig, err := SetHomeDomainTransaction(from, domain, Client(), nil /* timeBounds */, txnbuild.MinBaseFee)
+	sig, err := SetHomeDomainTransaction(from, domain, Client(), nil /* timeBounds */, MinBaseFee())
 	if err != nil {
 		return 0, "", 0, errMap(err)
 	}
@@ -849,7 +847,7 @@ func MakeOfferTransaction(from SeedStr, selling, buying xdr.Asset, amountToSell,
 }
 
 func makeOffer(from SeedStr, selling, buying xdr.Asset, amountToSell, price string) (ledger int32, txid string, attempt int, err error) {
-	sig, err := MakeOfferTransaction(from, selling, buying, amountToSell, price, Client(), nil /* timeBounds */, txnbuild.MinBaseFee)
+	sig, err := MakeOfferTransaction(from, selling, buying, amountToSell, price, Client(), nil /* timeBounds */, MinBaseFee())
 	if err != nil {
 		return 0, "", 0, errMap(err)
 	}
@@ -965,8 +963,23 @@ type SubmitResult struct {
 	ResultXDR string
 }
 
-// Submit submits a signed transaction to horizon.
+// Submit submits a signed transaction to horizon.  It waits for the
+// transaction to get into a ledger; use SubmitAsync to find out what
+// happened to it when horizon times out.
+//
+// Unless it has been turned off with SetMemoRequiredCheck, transactions
+// without a memo are checked first with CheckMemoRequired.
 func Submit(signed string) (res SubmitResult, err error) {
+	if memoRequiredCheckEnabled() {
+		var txEnv xdr.TransactionEnvelope
+		if err := xdr.SafeUnmarshalBase64(signed, &txEnv); err != nil {
+			return SubmitResult{}, err
+		}
+		if err := CheckMemoRequired(txEnv); err != nil {
+			return SubmitResult{}, err
+		}
+	}
+
 	var resp horizonProtocol.Transaction
 	for i := 0; i < submitAttempts; i++ {
 		resp, err = Client().SubmitTransactionXDR(signed)
@@ -1020,6 +1033,32 @@ func (a *Account) FindPaymentPaths(to AddressStr, assetCode string, assetIssuer
 	return page.Embedded.Records, nil
 }
 
+// FindStrictSendPaths searches for path payments that send exactly
+// sourceAmount of sourceAsset and deliver one of destAssets, best first.
+func FindStrictSendPaths(sourceAsset AssetBase, sourceAmount string, destAssets []AssetBase) ([]FullPath, error) {
+	if len(destAssets) == 0 {
+		return nil, ErrMissingParameter{Key: "destination_assets"}
+	}
+	v := url.Values{}
+	setAssetValues(v, "source_", sourceAsset)
+	v.Set("source_amount", sourceAmount)
+	dest := make([]string, len(destAssets))
+	for i, a := range destAssets {
+		dest[i] = canonicalAssetString(a)
+	}
+	v.Set("destination_assets", strings.Join(dest, ","))
+	link, err := horizonLink(Client().HorizonURL, "/paths/strict-send?"+v.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var page PathsPage
+	if err := getDecodeJSONStrict(link, Client().HTTP.Get, &page); err != nil {
+		return nil, errMap(err)
+	}
+	return page.Embedded.Records, nil
+}
+
 // CreateCustomAsset will create a new asset on the network.  It will
 // return two new account seeds:  one for the issuing account, one for
 // the distribution account.
@@ -1027,6 +1066,9 @@ func (a *Account) FindPaymentPaths(to AddressStr, assetCode string, assetIssuer
 // If an error occurs after the issuer and distributor are funded,
 // the issuer and distributor seeds will be returned along with any
 // error so you can reclaim your funds.
+//
+// IssueAsset does the same in fewer transactions, is configurable and
+// can resume after an error.
 func CreateCustomAsset(source SeedStr, assetCode, limit, homeDomain string, xlmPrice string, baseFee uint64) (issuer, distributor SeedStr, err error) {
 	issuerPair, err := NewKeyPair()
 	if err != nil {
@@ -1171,18 +1213,7 @@ func getDecodeJSONStrict(urlIn string, getter func(string) (*http.Response, erro
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		horizonError := &horizonclient.Error{
-			Response: resp,
-		}
-		err := json.NewDecoder(resp.Body).Decode(&horizonError.Problem)
-		if err != nil {
-			return Error{
-				Display:      "stellar network error",
-				Details:      fmt.Sprintf("horizon http error: %v %v, decode body error: %s", resp.StatusCode, resp.Status, err),
-				HorizonError: horizonError,
-			}
-		}
-		return errMap(horizonError)
+		return decodeHorizonError(resp)
 	}
 	if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
 		return errMap(err)
@@ -1191,6 +1222,23 @@ func getDecodeJSONStrict(urlIn string, getter func(string) (*http.Response, erro
 	return nil
 }
 
+// decodeHorizonError returns the error for a horizon response with
+// an error status.
+func decodeHorizonError(resp *http.Response) error {
+	horizonError := &horizonclient.Error{
+		Response: resp,
+	}
+	err := json.NewDecoder(resp.Body).Decode(&horizonError.Problem)
+	if err != nil {
+		return Error{
+			Display:      "stellar network error",
+			Details:      fmt.Sprintf("horizon http error: %v %v, decode body error: %s", resp.StatusCode, resp.Status, err),
+			HorizonError: horizonError,
+		}
+	}
+	return errMap(horizonError)
+}
+
 func horizonLink(base, path string) (string, error) {
 	u, err := url.Parse(base)
 	if err != nil {
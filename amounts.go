@@ -195,7 +195,8 @@ func parseExchangeRate(rate string) (*big.Rat, error) {
 	}
 }
 
-// PathPaymentMaxValue returns 105% * amount.
+// PathPaymentMaxValue returns 105% * amount.  PathPaymentSendMax
+// recommends a value from the current order books instead.
 func PathPaymentMaxValue(amount string) (string, error) {
 	amtInt, err := stellaramount.ParseInt64(amount)
 	if err != nil {
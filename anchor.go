@@ -0,0 +1,684 @@
+package stellarnet
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/txnbuild"
+)
+
+// HTTPDoer is an interface for making arbitrary http requests.
+// *http.Client implements it.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// AnchorProtocol selects which transfer server protocol to use
+// with an anchor.
+type AnchorProtocol int
+
+// These are the supported anchor protocols.
+const (
+	// AnchorSEP6 is the SEP6 (non-interactive) deposit and withdrawal protocol.
+	AnchorSEP6 AnchorProtocol = iota
+	// AnchorSEP24 is the SEP24 (interactive) deposit and withdrawal protocol.
+	AnchorSEP24
+	// AnchorSEP38 is the SEP38 quote protocol.
+	AnchorSEP38
+)
+
+func (p AnchorProtocol) String() string {
+	switch p {
+	case AnchorSEP6:
+		return "SEP6"
+	case AnchorSEP24:
+		return "SEP24"
+	case AnchorSEP38:
+		return "SEP38"
+	}
+	return fmt.Sprintf("AnchorProtocol(%d)", int(p))
+}
+
+// These are the anchor transaction statuses from SEP6 and SEP24.
+const (
+	AnchorStatusIncomplete                  = "incomplete"
+	AnchorStatusPendingUserTransferStart    = "pending_user_transfer_start"
+	AnchorStatusPendingUserTransferComplete = "pending_user_transfer_complete"
+	AnchorStatusPendingExternal             = "pending_external"
+	AnchorStatusPendingAnchor               = "pending_anchor"
+	AnchorStatusPendingStellar              = "pending_stellar"
+	AnchorStatusPendingTrust                = "pending_trust"
+	AnchorStatusPendingUser                 = "pending_user"
+	AnchorStatusPendingCustomerInfoUpdate   = "pending_customer_info_update"
+	AnchorStatusCompleted                   = "completed"
+	AnchorStatusRefunded                    = "refunded"
+	AnchorStatusExpired                     = "expired"
+	AnchorStatusNoMarket                    = "no_market"
+	AnchorStatusTooSmall                    = "too_small"
+	AnchorStatusTooLarge                    = "too_large"
+	AnchorStatusError                       = "error"
+)
+
+// AnchorStatusFinal returns true if an anchor transaction with status
+// will not change any more.
+func AnchorStatusFinal(status string) bool {
+	switch status {
+	case AnchorStatusCompleted, AnchorStatusRefunded, AnchorStatusExpired,
+		AnchorStatusNoMarket, AnchorStatusTooSmall, AnchorStatusTooLarge, AnchorStatusError:
+		return true
+	}
+	return false
+}
+
+// ErrAnchorNotSupported is returned when an anchor's stellar.toml
+// doesn't list a server needed for a request.
+type ErrAnchorNotSupported struct {
+	Domain string
+	Key    string
+}
+
+// Error implements error for ErrAnchorNotSupported.
+func (e ErrAnchorNotSupported) Error() string {
+	return fmt.Sprintf("stellar.toml for %s has no %s", e.Domain, e.Key)
+}
+
+// ErrAnchorStatus is returned when an anchor responds to a request
+// with an http error status.
+type ErrAnchorStatus struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implements error for ErrAnchorStatus.
+func (e ErrAnchorStatus) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("anchor request failed with status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("anchor request failed with status %d", e.StatusCode)
+}
+
+// ErrAnchorCustomerInfoNeeded is returned by SEP6 deposit and withdraw
+// requests when the anchor needs more information about the customer
+// before it can proceed.  Type is one of non_interactive_customer_info_needed,
+// interactive_customer_info_needed or customer_info_status.
+type ErrAnchorCustomerInfoNeeded struct {
+	Type   string
+	Fields []string
+	URL    string
+	Status string
+	ETA    int
+}
+
+// Error implements error for ErrAnchorCustomerInfoNeeded.
+func (e ErrAnchorCustomerInfoNeeded) Error() string {
+	if e.Status != "" {
+		return fmt.Sprintf("anchor needs customer info (%s): status %s", e.Type, e.Status)
+	}
+	return fmt.Sprintf("anchor needs customer info (%s)", e.Type)
+}
+
+// ErrAnchorAuthRequired is returned when a request needs a SEP10
+// token and Authenticate hasn't been called.
+var ErrAnchorAuthRequired = errors.New("anchor request requires authentication")
+
+// AnchorToml contains the parts of an anchor's stellar.toml that are used
+// for deposits, withdrawals and quotes.
+type AnchorToml struct {
+	NetworkPassphrase   string `toml:"NETWORK_PASSPHRASE"`
+	TransferServer      string `toml:"TRANSFER_SERVER"`
+	TransferServerSEP24 string `toml:"TRANSFER_SERVER_SEP0024"`
+	WebAuthEndpoint     string `toml:"WEB_AUTH_ENDPOINT"`
+	SigningKey          string `toml:"SIGNING_KEY"`
+	QuoteServer         string `toml:"ANCHOR_QUOTE_SERVER"`
+}
+
+// Anchor is a client for an anchor's SEP6 and SEP24 transfer servers
+// and its SEP38 quote server.
+type Anchor struct {
+	Domain string
+	Toml   AnchorToml
+
+	client HTTPDoer
+	token  string
+}
+
+// NewAnchor fetches the stellar.toml file for domain and returns an
+// Anchor that uses its transfer servers.
+func NewAnchor(domain string, client HTTPDoer) (*Anchor, error) {
+	domain = strings.TrimSpace(domain)
+	if !isDomainName(strings.Split(domain, ":")[0]) {
+		return nil, ErrInvalidParameter{Key: "domain"}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://%s/.well-known/stellar.toml", domain), nil)
+	if err != nil {
+		return nil, ErrInvalidParameter{Key: "domain"}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, ErrNetworkWellKnownOrigin{Wrapped: err}
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ErrInvalidWellKnownOrigin{Wrapped: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrInvalidWellKnownOrigin{Wrapped: errors.New("stellar.toml not found")}
+	}
+
+	a := &Anchor{Domain: domain, client: client}
+	if _, err := toml.Decode(string(body), &a.Toml); err != nil {
+		return nil, ErrInvalidWellKnownOrigin{Wrapped: err}
+	}
+	if a.Toml.NetworkPassphrase != "" && a.Toml.NetworkPassphrase != NetworkPassphrase() {
+		return nil, ErrNetworkPassphraseMismatch{URIPassphrase: a.Toml.NetworkPassphrase, NetworkPassphrase: NetworkPassphrase()}
+	}
+
+	return a, nil
+}
+
+// NewAnchorForAsset looks up the home domain of asset's issuer and
+// returns the Anchor for it.
+func NewAnchorForAsset(asset AssetBase, client HTTPDoer) (*Anchor, error) {
+	issuer, err := assetBaseIssuer(asset)
+	if err != nil {
+		return nil, err
+	}
+	acct, err := Client().AccountDetail(horizonclient.AccountRequest{AccountID: issuer.String()})
+	if err != nil {
+		return nil, errMapAccount(err)
+	}
+	if acct.HomeDomain == "" {
+		return nil, ErrAnchorNotSupported{Domain: issuer.String(), Key: "home_domain"}
+	}
+	return NewAnchor(acct.HomeDomain, client)
+}
+
+// Token returns the SEP10 token from Authenticate.
+func (a *Anchor) Token() string {
+	return a.token
+}
+
+// SetToken sets the SEP10 token used for requests to the anchor, for
+// callers that keep tokens around between sessions.
+func (a *Anchor) SetToken(token string) {
+	a.token = token
+}
+
+func (a *Anchor) transferServer(protocol AnchorProtocol) (string, error) {
+	var server, key string
+	switch protocol {
+	case AnchorSEP6:
+		server, key = a.Toml.TransferServer, "TRANSFER_SERVER"
+	case AnchorSEP24:
+		server, key = a.Toml.TransferServerSEP24, "TRANSFER_SERVER_SEP0024"
+	case AnchorSEP38:
+		server, key = a.Toml.QuoteServer, "ANCHOR_QUOTE_SERVER"
+	default:
+		return "", ErrInvalidParameter{Key: "protocol"}
+	}
+	if server == "" {
+		return "", ErrAnchorNotSupported{Domain: a.Domain, Key: key}
+	}
+	return strings.TrimSuffix(server, "/"), nil
+}
+
+// AnchorAssetInfo describes deposits or withdrawals of an asset.
+type AnchorAssetInfo struct {
+	Enabled                bool                       `json:"enabled"`
+	AuthenticationRequired bool                       `json:"authentication_required"`
+	MinAmount              json.Number                `json:"min_amount,omitempty"`
+	MaxAmount              json.Number                `json:"max_amount,omitempty"`
+	FeeFixed               json.Number                `json:"fee_fixed,omitempty"`
+	FeePercent             json.Number                `json:"fee_percent,omitempty"`
+	FeeMinimum             json.Number                `json:"fee_minimum,omitempty"`
+	Fields                 map[string]AnchorFieldInfo `json:"fields,omitempty"`
+	Types                  map[string]AnchorTypeInfo  `json:"types,omitempty"`
+}
+
+// AnchorFieldInfo describes a field a SEP6 deposit or withdrawal needs.
+type AnchorFieldInfo struct {
+	Description string   `json:"description"`
+	Optional    bool     `json:"optional"`
+	Choices     []string `json:"choices,omitempty"`
+}
+
+// AnchorTypeInfo describes a SEP6 withdrawal type.
+type AnchorTypeInfo struct {
+	Fields map[string]AnchorFieldInfo `json:"fields,omitempty"`
+}
+
+// AnchorEndpointInfo says whether an optional endpoint is available.
+type AnchorEndpointInfo struct {
+	Enabled                bool `json:"enabled"`
+	AuthenticationRequired bool `json:"authentication_required"`
+}
+
+// AnchorInfo is the response from the /info endpoint of a transfer server.
+type AnchorInfo struct {
+	Deposit      map[string]AnchorAssetInfo `json:"deposit"`
+	Withdraw     map[string]AnchorAssetInfo `json:"withdraw"`
+	Fee          AnchorEndpointInfo         `json:"fee"`
+	Transactions AnchorEndpointInfo         `json:"transactions"`
+	Transaction  AnchorEndpointInfo         `json:"transaction"`
+}
+
+// Info returns what the anchor supports.
+func (a *Anchor) Info(protocol AnchorProtocol) (AnchorInfo, error) {
+	var info AnchorInfo
+	if err := a.get(protocol, "/info", nil, false, &info); err != nil {
+		return AnchorInfo{}, err
+	}
+	return info, nil
+}
+
+// AnchorDepositRequest contains the parameters for a deposit.
+type AnchorDepositRequest struct {
+	AssetCode string
+	Account   AddressStr
+	// Memo, if set, is the memo the anchor should use on the deposit payment.
+	Memo   *Memo
+	Amount string
+	// Type is the SEP6 deposit method (SEPA, SWIFT, cash...).
+	Type string
+	// Extra are any other fields the anchor asks for in /info.
+	Extra map[string]string
+}
+
+func (r AnchorDepositRequest) values() (url.Values, error) {
+	v := url.Values{}
+	v.Set("asset_code", r.AssetCode)
+	v.Set("account", r.Account.String())
+	if err := setAnchorMemo(v, "memo", "memo_type", r.Memo); err != nil {
+		return nil, err
+	}
+	setIfNotEmpty(v, "amount", r.Amount)
+	setIfNotEmpty(v, "type", r.Type)
+	for key, value := range r.Extra {
+		v.Set(key, value)
+	}
+	return v, nil
+}
+
+// AnchorDepositResponse is the response from a SEP6 /deposit request.
+type AnchorDepositResponse struct {
+	How        string          `json:"how"`
+	ID         string          `json:"id"`
+	ETA        int             `json:"eta"`
+	MinAmount  json.Number     `json:"min_amount,omitempty"`
+	MaxAmount  json.Number     `json:"max_amount,omitempty"`
+	FeeFixed   json.Number     `json:"fee_fixed,omitempty"`
+	FeePercent json.Number     `json:"fee_percent,omitempty"`
+	ExtraInfo  json.RawMessage `json:"extra_info,omitempty"`
+}
+
+// Deposit asks the anchor how to deposit an asset (SEP6).  If the anchor
+// needs KYC information first, the error will be ErrAnchorCustomerInfoNeeded.
+func (a *Anchor) Deposit(r AnchorDepositRequest) (AnchorDepositResponse, error) {
+	params, err := r.values()
+	if err != nil {
+		return AnchorDepositResponse{}, err
+	}
+	var res AnchorDepositResponse
+	if err := a.get(AnchorSEP6, "/deposit", params, a.token != "", &res); err != nil {
+		return AnchorDepositResponse{}, err
+	}
+	return res, nil
+}
+
+// AnchorWithdrawRequest contains the parameters for a withdrawal.
+type AnchorWithdrawRequest struct {
+	AssetCode string
+	// Account is the account that will send the withdrawal payment.
+	Account AddressStr
+	Amount  string
+	// Type is the SEP6 withdrawal method (bank_account, cash...).
+	Type string
+	// Dest and DestExtra identify where the anchor should send
+	// the funds (SEP6 only).
+	Dest      string
+	DestExtra string
+	// Extra are any other fields the anchor asks for in /info.
+	Extra map[string]string
+}
+
+func (r AnchorWithdrawRequest) values() url.Values {
+	v := url.Values{}
+	v.Set("asset_code", r.AssetCode)
+	setIfNotEmpty(v, "account", r.Account.String())
+	setIfNotEmpty(v, "amount", r.Amount)
+	setIfNotEmpty(v, "type", r.Type)
+	setIfNotEmpty(v, "dest", r.Dest)
+	setIfNotEmpty(v, "dest_extra", r.DestExtra)
+	for key, value := range r.Extra {
+		v.Set(key, value)
+	}
+	return v
+}
+
+// AnchorWithdrawResponse is the response from a SEP6 /withdraw request.
+type AnchorWithdrawResponse struct {
+	AccountID  string          `json:"account_id"`
+	MemoType   string          `json:"memo_type,omitempty"`
+	Memo       string          `json:"memo,omitempty"`
+	ID         string          `json:"id"`
+	ETA        int             `json:"eta"`
+	MinAmount  json.Number     `json:"min_amount,omitempty"`
+	MaxAmount  json.Number     `json:"max_amount,omitempty"`
+	FeeFixed   json.Number     `json:"fee_fixed,omitempty"`
+	FeePercent json.Number     `json:"fee_percent,omitempty"`
+	ExtraInfo  json.RawMessage `json:"extra_info,omitempty"`
+}
+
+// Payment returns where to send the withdrawal payment.
+func (w AnchorWithdrawResponse) Payment() (AnchorPayment, error) {
+	return newAnchorPayment(w.AccountID, w.Memo, w.MemoType)
+}
+
+// Withdraw asks the anchor where to send an asset to withdraw it (SEP6).
+// If the anchor needs KYC information first, the error will be
+// ErrAnchorCustomerInfoNeeded.
+func (a *Anchor) Withdraw(r AnchorWithdrawRequest) (AnchorWithdrawResponse, error) {
+	var res AnchorWithdrawResponse
+	if err := a.get(AnchorSEP6, "/withdraw", r.values(), a.token != "", &res); err != nil {
+		return AnchorWithdrawResponse{}, err
+	}
+	return res, nil
+}
+
+// AnchorInteractiveResponse is the response from a SEP24 interactive
+// deposit or withdraw request.  The wallet should show URL to the user
+// and then poll the transaction with ID.
+type AnchorInteractiveResponse struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	ID   string `json:"id"`
+}
+
+// DepositInteractive starts a SEP24 interactive deposit.  It requires
+// a token from Authenticate.
+func (a *Anchor) DepositInteractive(r AnchorDepositRequest) (AnchorInteractiveResponse, error) {
+	params, err := r.values()
+	if err != nil {
+		return AnchorInteractiveResponse{}, err
+	}
+	return a.interactive("/transactions/deposit/interactive", params)
+}
+
+// WithdrawInteractive starts a SEP24 interactive withdrawal.  It requires
+// a token from Authenticate.
+func (a *Anchor) WithdrawInteractive(r AnchorWithdrawRequest) (AnchorInteractiveResponse, error) {
+	return a.interactive("/transactions/withdraw/interactive", r.values())
+}
+
+func (a *Anchor) interactive(path string, params url.Values) (AnchorInteractiveResponse, error) {
+	var res AnchorInteractiveResponse
+	if err := a.do(AnchorSEP24, http.MethodPost, path, params, true, &res); err != nil {
+		return AnchorInteractiveResponse{}, err
+	}
+	return res, nil
+}
+
+// AnchorTransaction is a deposit or withdrawal from a SEP6 or SEP24
+// /transaction request.
+type AnchorTransaction struct {
+	ID                    string      `json:"id"`
+	Kind                  string      `json:"kind"`
+	Status                string      `json:"status"`
+	StatusETA             int         `json:"status_eta,omitempty"`
+	MoreInfoURL           string      `json:"more_info_url,omitempty"`
+	AmountIn              json.Number `json:"amount_in,omitempty"`
+	AmountOut             json.Number `json:"amount_out,omitempty"`
+	AmountFee             json.Number `json:"amount_fee,omitempty"`
+	StartedAt             string      `json:"started_at,omitempty"`
+	CompletedAt           string      `json:"completed_at,omitempty"`
+	StellarTransactionID  string      `json:"stellar_transaction_id,omitempty"`
+	ExternalTransactionID string      `json:"external_transaction_id,omitempty"`
+	Message               string      `json:"message,omitempty"`
+	Refunded              bool        `json:"refunded,omitempty"`
+	From                  string      `json:"from,omitempty"`
+	To                    string      `json:"to,omitempty"`
+	DepositMemo           string      `json:"deposit_memo,omitempty"`
+	DepositMemoType       string      `json:"deposit_memo_type,omitempty"`
+	WithdrawAnchorAccount string      `json:"withdraw_anchor_account,omitempty"`
+	WithdrawMemo          string      `json:"withdraw_memo,omitempty"`
+	WithdrawMemoType      string      `json:"withdraw_memo_type,omitempty"`
+}
+
+// WithdrawPayment returns where to send the payment for a withdrawal
+// transaction.  For SEP24 withdrawals, this is known once the status
+// is pending_user_transfer_start.
+func (t AnchorTransaction) WithdrawPayment() (AnchorPayment, error) {
+	if t.Kind != "" && t.Kind != "withdrawal" {
+		return AnchorPayment{}, ErrInvalidParameter{Key: "kind"}
+	}
+	return newAnchorPayment(t.WithdrawAnchorAccount, t.WithdrawMemo, t.WithdrawMemoType)
+}
+
+// Transaction returns the status of an anchor transaction.
+func (a *Anchor) Transaction(protocol AnchorProtocol, id string) (AnchorTransaction, error) {
+	params := url.Values{}
+	params.Set("id", id)
+	var res struct {
+		Transaction AnchorTransaction `json:"transaction"`
+	}
+	if err := a.get(protocol, "/transaction", params, true, &res); err != nil {
+		return AnchorTransaction{}, err
+	}
+	return res.Transaction, nil
+}
+
+// WaitForTransaction polls the anchor transaction every interval
+// until its status is one of statuses or it is final (see
+// AnchorStatusFinal), or until ctx is done.
+func (a *Anchor) WaitForTransaction(ctx context.Context, protocol AnchorProtocol, id string, interval time.Duration, statuses ...string) (AnchorTransaction, error) {
+	for {
+		tx, err := a.Transaction(protocol, id)
+		if err != nil {
+			return AnchorTransaction{}, err
+		}
+		if AnchorStatusFinal(tx.Status) {
+			return tx, nil
+		}
+		for _, s := range statuses {
+			if tx.Status == s {
+				return tx, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return tx, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// AnchorPayment is the account and memo to pay to complete a withdrawal.
+type AnchorPayment struct {
+	Account AddressStr
+	Memo    *Memo
+}
+
+func newAnchorPayment(account, memo, memoType string) (AnchorPayment, error) {
+	address, err := NewAddressStr(account)
+	if err != nil {
+		return AnchorPayment{}, ErrInvalidParameter{Key: "account_id"}
+	}
+	m, err := anchorMemo(memo, memoType)
+	if err != nil {
+		return AnchorPayment{}, err
+	}
+	return AnchorPayment{Account: address, Memo: m}, nil
+}
+
+// anchorMemo converts a memo from an anchor response to a Memo.
+// Anchors encode hash memos in base64.
+func anchorMemo(memo, memoType string) (*Memo, error) {
+	lowered := strings.ToLower(memoType)
+	switch lowered {
+	case "hash", "return":
+		decoded, err := base64.StdEncoding.DecodeString(memo)
+		if err != nil || len(decoded) != len(MemoHash{}) {
+			return nil, ErrInvalidParameter{Key: "memo"}
+		}
+		var h MemoHash
+		copy(h[:], decoded)
+		if lowered == "return" {
+			return NewMemoReturn(h), nil
+		}
+		return NewMemoHash(h), nil
+	}
+	m, err := NewMemoFromStrings(memo, memoType)
+	if err != nil {
+		return nil, ErrInvalidParameter{Key: "memo"}
+	}
+	return m, nil
+}
+
+// setAnchorMemo sets the memo and memo type parameters for m the way
+// anchors expect them.
+func setAnchorMemo(v url.Values, memoKey, typeKey string, m *Memo) error {
+	if m == nil || m.Type == MemoTypeNone {
+		return nil
+	}
+	switch m.Type {
+	case MemoTypeText:
+		v.Set(typeKey, "text")
+		v.Set(memoKey, *m.Text)
+	case MemoTypeID:
+		v.Set(typeKey, "id")
+		v.Set(memoKey, fmt.Sprintf("%d", *m.ID))
+	case MemoTypeHash:
+		v.Set(typeKey, "hash")
+		v.Set(memoKey, base64.StdEncoding.EncodeToString(m.Hash[:]))
+	case MemoTypeReturn:
+		v.Set(typeKey, "return")
+		v.Set(memoKey, base64.StdEncoding.EncodeToString(m.ReturnHash[:]))
+	default:
+		return ErrInvalidParameter{Key: memoKey}
+	}
+	return nil
+}
+
+func setIfNotEmpty(v url.Values, key, value string) {
+	if value != "" {
+		v.Set(key, value)
+	}
+}
+
+// WithdrawPaymentTransaction creates a signed transaction that sends
+// amount of asset to the anchor account in dest with the memo the anchor
+// asked for.
+func WithdrawPaymentTransaction(from SeedStr, dest AnchorPayment, asset AssetBase, amount string,
+	seqnoProvider SequenceProvider, timeBounds *txnbuild.Timebounds, baseFee uint64) (SignResult, error) {
+	t, err := newBaseTxSeed(from, seqnoProvider, baseFee)
+	if err != nil {
+		return SignResult{}, err
+	}
+	assetXDR, err := assetBaseToXDR(asset)
+	if err != nil {
+		return SignResult{}, err
+	}
+	t.AddAssetPaymentOp(dest.Account, assetXDR, amount)
+	if dest.Memo != nil {
+		t.AddMemo(dest.Memo)
+	}
+	t.AddBuiltTimeBounds(timeBounds)
+	return t.Sign(from)
+}
+
+func (a *Anchor) get(protocol AnchorProtocol, path string, params url.Values, auth bool, out interface{}) error {
+	return a.do(protocol, http.MethodGet, path, params, auth, out)
+}
+
+// do makes a request to the transfer server for protocol and decodes
+// the JSON response into out.  GET params go in the query string,
+// POST params are form encoded.
+func (a *Anchor) do(protocol AnchorProtocol, method, path string, params url.Values, auth bool, out interface{}) error {
+	server, err := a.transferServer(protocol)
+	if err != nil {
+		return err
+	}
+	if auth && a.token == "" {
+		return ErrAnchorAuthRequired
+	}
+
+	u := server + path
+	var body io.Reader
+	if method == http.MethodGet {
+		if len(params) > 0 {
+			u += "?" + params.Encode()
+		}
+	} else {
+		body = strings.NewReader(params.Encode())
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if auth {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+
+	return doAnchorRequest(a.client, req, out)
+}
+
+// anchorResponseError is the error body anchors return.  SEP6
+// customer info responses use the same status code (403), so the
+// type field is checked first.
+type anchorResponseError struct {
+	Error  string   `json:"error"`
+	Type   string   `json:"type"`
+	Fields []string `json:"fields"`
+	URL    string   `json:"url"`
+	Status string   `json:"status"`
+	ETA    int      `json:"eta"`
+}
+
+func doAnchorRequest(client HTTPDoer, req *http.Request, out interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e anchorResponseError
+		if err := json.Unmarshal(body, &e); err != nil {
+			return ErrAnchorStatus{StatusCode: resp.StatusCode}
+		}
+		switch e.Type {
+		case "non_interactive_customer_info_needed", "interactive_customer_info_needed", "customer_info_status":
+			if resp.StatusCode == http.StatusForbidden {
+				return ErrAnchorCustomerInfoNeeded{Type: e.Type, Fields: e.Fields, URL: e.URL, Status: e.Status, ETA: e.ETA}
+			}
+		}
+		return ErrAnchorStatus{StatusCode: resp.StatusCode, Message: e.Error}
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("invalid anchor response: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package stellarnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+)
+
+// ErrChallengeAccountMismatch is returned when a SEP10 challenge
+// transaction is for a different account than the one authenticating.
+var ErrChallengeAccountMismatch = errors.New("challenge transaction is for a different account")
+
+// Authenticate gets a SEP10 token for the account of seed from the
+// anchor's WEB_AUTH_ENDPOINT.  The challenge transaction is checked
+// (server signature, home domain, web auth domain, time bounds) before
+// it is signed.  The token is used for all later requests that need
+// authentication.
+func (a *Anchor) Authenticate(seed SeedStr) error {
+	if a.Toml.WebAuthEndpoint == "" {
+		return ErrAnchorNotSupported{Domain: a.Domain, Key: "WEB_AUTH_ENDPOINT"}
+	}
+	if a.Toml.SigningKey == "" {
+		return ErrAnchorNotSupported{Domain: a.Domain, Key: "SIGNING_KEY"}
+	}
+	endpoint, err := url.Parse(a.Toml.WebAuthEndpoint)
+	if err != nil {
+		return ErrInvalidParameter{Key: "WEB_AUTH_ENDPOINT"}
+	}
+	kp, err := keypair.ParseFull(seed.SecureNoLogString())
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("account", kp.Address())
+	params.Set("home_domain", a.Domain)
+	req, err := http.NewRequest(http.MethodGet, a.Toml.WebAuthEndpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	var challenge struct {
+		Transaction       string `json:"transaction"`
+		NetworkPassphrase string `json:"network_passphrase"`
+	}
+	if err := doAnchorRequest(a.client, req, &challenge); err != nil {
+		return err
+	}
+	if challenge.NetworkPassphrase != "" && challenge.NetworkPassphrase != NetworkPassphrase() {
+		return ErrNetworkPassphraseMismatch{URIPassphrase: challenge.NetworkPassphrase, NetworkPassphrase: NetworkPassphrase()}
+	}
+
+	tx, clientAccountID, _, err := txnbuild.ReadChallengeTx(challenge.Transaction, a.Toml.SigningKey, NetworkPassphrase(), endpoint.Host, []string{a.Domain})
+	if err != nil {
+		return err
+	}
+	if clientAccountID != kp.Address() {
+		return ErrChallengeAccountMismatch
+	}
+	tx, err = tx.Sign(NetworkPassphrase(), kp)
+	if err != nil {
+		return err
+	}
+	signed, err := tx.Base64()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]string{"transaction": signed})
+	if err != nil {
+		return err
+	}
+	req, err = http.NewRequest(http.MethodPost, a.Toml.WebAuthEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var res struct {
+		Token string `json:"token"`
+	}
+	if err := doAnchorRequest(a.client, req, &res); err != nil {
+		return err
+	}
+	if res.Token == "" {
+		return errors.New("anchor did not return a token")
+	}
+	a.token = res.Token
+
+	return nil
+}
@@ -0,0 +1,343 @@
+package stellarnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// QuoteAsset returns the SEP38 asset identifier for a: "stellar:native"
+// or "stellar:CODE:ISSUER".
+func QuoteAsset(a AssetBase) string {
+	if a.TypeString() == "native" || (a.CodeString() == "" && a.IssuerString() == "") {
+		return "stellar:native"
+	}
+	return "stellar:" + strings.TrimSpace(a.CodeString()) + ":" + strings.TrimSpace(a.IssuerString())
+}
+
+// ParseQuoteAsset converts a SEP38 stellar asset identifier into an
+// AssetMinimal.  Off chain assets (like iso4217:USD) are not stellar
+// assets and return ErrInvalidParameter.
+func ParseQuoteAsset(id string) (AssetMinimal, error) {
+	parts := strings.Split(id, ":")
+	if len(parts) == 2 && parts[0] == "stellar" && parts[1] == "native" {
+		return NewAssetMinimal("", "")
+	}
+	if len(parts) != 3 || parts[0] != "stellar" {
+		return AssetMinimal{}, ErrInvalidParameter{Key: "asset"}
+	}
+	if _, err := NewAddressStr(parts[2]); err != nil {
+		return AssetMinimal{}, ErrInvalidParameter{Key: "asset"}
+	}
+	return NewAssetMinimal(parts[1], parts[2])
+}
+
+// QuoteDeliveryMethod is a way of delivering an off chain asset to or
+// from the anchor.
+type QuoteDeliveryMethod struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// QuoteAssetInfo describes an asset the anchor will quote.
+type QuoteAssetInfo struct {
+	Asset               string                `json:"asset"`
+	SellDeliveryMethods []QuoteDeliveryMethod `json:"sell_delivery_methods,omitempty"`
+	BuyDeliveryMethods  []QuoteDeliveryMethod `json:"buy_delivery_methods,omitempty"`
+	CountryCodes        []string              `json:"country_codes,omitempty"`
+}
+
+// QuoteInfo is the response from the SEP38 /info endpoint.
+type QuoteInfo struct {
+	Assets []QuoteAssetInfo `json:"assets"`
+}
+
+// QuoteInfo returns the assets the anchor will quote.
+func (a *Anchor) QuoteInfo() (QuoteInfo, error) {
+	var info QuoteInfo
+	if err := a.get(AnchorSEP38, "/info", nil, a.token != "", &info); err != nil {
+		return QuoteInfo{}, err
+	}
+	return info, nil
+}
+
+// QuoteBuyAsset is an indicative price for buying an asset from the
+// SEP38 /prices endpoint.
+type QuoteBuyAsset struct {
+	Asset    string `json:"asset"`
+	Price    string `json:"price"`
+	Decimals int    `json:"decimals"`
+}
+
+// PriceRat returns Price as a big.Rat.  SEP38 prices are in units of the
+// sell asset per unit of the buy asset.
+func (b QuoteBuyAsset) PriceRat() (*big.Rat, error) {
+	return parseExchangeRate(b.Price)
+}
+
+// QuotePrices returns indicative prices for all the assets that can be
+// bought with sellAmount of sellAsset.
+func (a *Anchor) QuotePrices(sellAsset, sellAmount string) ([]QuoteBuyAsset, error) {
+	params := url.Values{}
+	params.Set("sell_asset", sellAsset)
+	params.Set("sell_amount", sellAmount)
+	var res struct {
+		BuyAssets []QuoteBuyAsset `json:"buy_assets"`
+	}
+	if err := a.get(AnchorSEP38, "/prices", params, a.token != "", &res); err != nil {
+		return nil, err
+	}
+	return res.BuyAssets, nil
+}
+
+// QuoteRequest contains the parameters for a SEP38 price or quote.
+// Exactly one of SellAmount and BuyAmount should be set.
+type QuoteRequest struct {
+	SellAsset          string
+	BuyAsset           string
+	SellAmount         string
+	BuyAmount          string
+	SellDeliveryMethod string
+	BuyDeliveryMethod  string
+	CountryCode        string
+	// Context is sep6, sep24 or sep31.
+	Context string
+	// ExpireAfter asks for a quote that doesn't expire before it
+	// (firm quotes only).
+	ExpireAfter time.Time
+}
+
+func (r QuoteRequest) validate() error {
+	if r.SellAsset == "" {
+		return ErrMissingParameter{Key: "sell_asset"}
+	}
+	if r.BuyAsset == "" {
+		return ErrMissingParameter{Key: "buy_asset"}
+	}
+	if (r.SellAmount == "") == (r.BuyAmount == "") {
+		return ErrInvalidParameter{Key: "sell_amount"}
+	}
+	return nil
+}
+
+func (r QuoteRequest) values() url.Values {
+	v := url.Values{}
+	v.Set("sell_asset", r.SellAsset)
+	v.Set("buy_asset", r.BuyAsset)
+	setIfNotEmpty(v, "sell_amount", r.SellAmount)
+	setIfNotEmpty(v, "buy_amount", r.BuyAmount)
+	setIfNotEmpty(v, "sell_delivery_method", r.SellDeliveryMethod)
+	setIfNotEmpty(v, "buy_delivery_method", r.BuyDeliveryMethod)
+	setIfNotEmpty(v, "country_code", r.CountryCode)
+	setIfNotEmpty(v, "context", r.Context)
+	return v
+}
+
+// QuoteFee is the fee included in a SEP38 price or quote.
+type QuoteFee struct {
+	Total string `json:"total"`
+	Asset string `json:"asset"`
+}
+
+// Quote is an indicative price from /price or a firm quote from /quote.
+// Firm quotes have an ID and ExpiresAt.
+type Quote struct {
+	ID         string    `json:"id,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+	TotalPrice string    `json:"total_price"`
+	Price      string    `json:"price"`
+	SellAsset  string    `json:"sell_asset,omitempty"`
+	SellAmount string    `json:"sell_amount"`
+	BuyAsset   string    `json:"buy_asset,omitempty"`
+	BuyAmount  string    `json:"buy_amount"`
+	Fee        QuoteFee  `json:"fee"`
+}
+
+// Firm returns true if q is a firm quote.
+func (q Quote) Firm() bool {
+	return q.ID != ""
+}
+
+// Expired returns true if q is a firm quote that expired before now.
+func (q Quote) Expired(now time.Time) bool {
+	return q.Firm() && !q.ExpiresAt.IsZero() && !now.Before(q.ExpiresAt)
+}
+
+// TotalPriceRat returns TotalPrice (sell asset per unit of buy asset,
+// including fees) as a big.Rat.
+func (q Quote) TotalPriceRat() (*big.Rat, error) {
+	return parseExchangeRate(q.TotalPrice)
+}
+
+// Rate returns the number of units of the buy asset received per unit
+// of the sell asset, fees included.  It can be compared directly with
+// the rate of a path payment (see FullPathRate).
+func (q Quote) Rate() (*big.Rat, error) {
+	return amountsRate(q.SellAmount, q.BuyAmount, "sell_amount", "buy_amount")
+}
+
+// QuotePrice returns an indicative price for r.
+func (a *Anchor) QuotePrice(r QuoteRequest) (Quote, error) {
+	if err := r.validate(); err != nil {
+		return Quote{}, err
+	}
+	var q Quote
+	if err := a.get(AnchorSEP38, "/price", r.values(), a.token != "", &q); err != nil {
+		return Quote{}, err
+	}
+	q.SellAsset, q.BuyAsset = r.SellAsset, r.BuyAsset
+	return q, nil
+}
+
+// RequestQuote asks the anchor for a firm quote.  It requires a token
+// from Authenticate.
+func (a *Anchor) RequestQuote(r QuoteRequest) (Quote, error) {
+	if err := r.validate(); err != nil {
+		return Quote{}, err
+	}
+	if a.token == "" {
+		return Quote{}, ErrAnchorAuthRequired
+	}
+	server, err := a.transferServer(AnchorSEP38)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	fields := make(map[string]string)
+	for key, value := range r.values() {
+		fields[key] = value[0]
+	}
+	if !r.ExpireAfter.IsZero() {
+		fields["expire_after"] = r.ExpireAfter.UTC().Format(time.RFC3339)
+	}
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return Quote{}, err
+	}
+	req, err := http.NewRequest(http.MethodPost, server+"/quote", bytes.NewReader(body))
+	if err != nil {
+		return Quote{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.token)
+
+	var q Quote
+	if err := doAnchorRequest(a.client, req, &q); err != nil {
+		return Quote{}, err
+	}
+	if !q.Firm() {
+		return Quote{}, errors.New("anchor quote has no id")
+	}
+	return q, nil
+}
+
+// GetQuote returns a firm quote by ID.  It requires a token from
+// Authenticate.
+func (a *Anchor) GetQuote(id string) (Quote, error) {
+	if id == "" {
+		return Quote{}, ErrMissingParameter{Key: "id"}
+	}
+	var q Quote
+	if err := a.get(AnchorSEP38, "/quote/"+url.PathEscape(id), nil, true, &q); err != nil {
+		return Quote{}, err
+	}
+	return q, nil
+}
+
+// FullPathRate returns the number of units of the destination asset
+// received per unit of the source asset for a path payment.
+func FullPathRate(p FullPath) (*big.Rat, error) {
+	return amountsRate(p.SourceAmount, p.DestinationAmount, "source_amount", "destination_amount")
+}
+
+// amountsRate returns buy / sell.  Zero or negative sell amounts and
+// negative buy amounts are an ErrInvalidParameter for their key.
+func amountsRate(sell, buy, sellKey, buyKey string) (*big.Rat, error) {
+	sellRat, err := ParseAmount(sell)
+	if err != nil || sellRat.Sign() <= 0 {
+		return nil, ErrInvalidParameter{Key: sellKey}
+	}
+	buyRat, err := ParseAmount(buy)
+	if err != nil || buyRat.Sign() < 0 {
+		return nil, ErrInvalidParameter{Key: buyKey}
+	}
+	return new(big.Rat).Quo(buyRat, sellRat), nil
+}
+
+// QuoteComparison compares an anchor quote with the best on chain path
+// payment for the same pair of assets.
+type QuoteComparison struct {
+	Quote     Quote
+	QuoteRate *big.Rat
+	// Path is the best path for the pair, nil if there were none.
+	Path     *FullPath
+	PathRate *big.Rat
+	// QuoteBetter is true if the quote gives more of the buy asset per
+	// unit of the sell asset than the best path (or there is no path).
+	QuoteBetter bool
+	// Difference is QuoteRate / PathRate - 1, nil if there is no path.
+	Difference *big.Rat
+}
+
+// CompareQuote ranks q against the best of paths (for example from
+// FindPaymentPaths) with the same source and destination assets as the
+// quote's sell and buy assets.  Both sides of the quote must be stellar
+// assets.  Paths with a zero amount on either side are skipped.
+func CompareQuote(q Quote, paths []FullPath) (QuoteComparison, error) {
+	sellAsset, err := ParseQuoteAsset(q.SellAsset)
+	if err != nil {
+		return QuoteComparison{}, ErrInvalidParameter{Key: "sell_asset"}
+	}
+	buyAsset, err := ParseQuoteAsset(q.BuyAsset)
+	if err != nil {
+		return QuoteComparison{}, ErrInvalidParameter{Key: "buy_asset"}
+	}
+
+	cmp := QuoteComparison{Quote: q}
+	cmp.QuoteRate, err = q.Rate()
+	if err != nil {
+		return QuoteComparison{}, err
+	}
+
+	for i, p := range paths {
+		if !sameAsset(p.SourceAsset(), sellAsset) || !sameAsset(p.DestinationAsset(), buyAsset) {
+			continue
+		}
+		rate, err := FullPathRate(p)
+		if err == (ErrInvalidParameter{Key: "source_amount"}) {
+			continue
+		}
+		if err != nil {
+			return QuoteComparison{}, fmt.Errorf("path %d: %s", i, err)
+		}
+		if rate.Sign() == 0 {
+			// nothing to compare against
+			continue
+		}
+		if cmp.PathRate == nil || rate.Cmp(cmp.PathRate) > 0 {
+			cmp.Path = &paths[i]
+			cmp.PathRate = rate
+		}
+	}
+
+	if cmp.PathRate == nil {
+		cmp.QuoteBetter = true
+		return cmp, nil
+	}
+	cmp.QuoteBetter = cmp.QuoteRate.Cmp(cmp.PathRate) > 0
+	cmp.Difference = new(big.Rat).Quo(cmp.QuoteRate, cmp.PathRate)
+	cmp.Difference.Sub(cmp.Difference, big.NewRat(1, 1))
+
+	return cmp, nil
+}
+
+// sameAsset returns true if a and b are the same stellar asset.
+func sameAsset(a, b AssetBase) bool {
+	return QuoteAsset(a) == QuoteAsset(b)
+}
@@ -0,0 +1,189 @@
+package stellarnet
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+)
+
+const testQuoteIssuer = "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO"
+
+var testQuoteExpires = time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// quote serves the SEP38 endpoints of fakeAnchor.  It will sell XLM
+// for USDC at 0.1 USDC per XLM with a 1 USDC fee.
+func (f *fakeAnchor) quote(w http.ResponseWriter, r *http.Request, authed bool) {
+	q := r.URL.Query()
+	switch {
+	case r.URL.Path == "/sep38/info":
+		w.Write([]byte(`{"assets": [{"asset": "stellar:native"}, {"asset": "stellar:USDC:` + testQuoteIssuer + `"}, {"asset": "iso4217:USD", "sell_delivery_methods": [{"name": "ACH", "description": "bank transfer"}], "country_codes": ["USA"]}]}`))
+	case r.URL.Path == "/sep38/prices":
+		require.Equal(f.t, "stellar:USDC:"+testQuoteIssuer, q.Get("sell_asset"))
+		w.Write([]byte(`{"buy_assets": [{"asset": "stellar:native", "price": "0.1", "decimals": 7}]}`))
+	case r.URL.Path == "/sep38/price":
+		require.Equal(f.t, "101", q.Get("sell_amount"))
+		require.Equal(f.t, "sep6", q.Get("context"))
+		w.Write([]byte(`{"total_price": "0.101", "price": "0.1", "sell_amount": "101", "buy_amount": "1000", "fee": {"total": "1", "asset": "stellar:USDC:` + testQuoteIssuer + `"}}`))
+	case r.URL.Path == "/sep38/quote" && r.Method == http.MethodPost:
+		if !authed {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "forbidden"}`))
+			return
+		}
+		var body map[string]string
+		require.NoError(f.t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(f.t, "2030-01-02T03:04:05Z", body["expire_after"])
+		require.Equal(f.t, "1000", body["buy_amount"])
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "q1", "expires_at": "2030-01-02T03:04:05Z", "total_price": "0.101", "price": "0.1", "sell_asset": "` + body["sell_asset"] + `", "sell_amount": "101", "buy_asset": "` + body["buy_asset"] + `", "buy_amount": "1000", "fee": {"total": "1", "asset": "` + body["sell_asset"] + `"}}`))
+	case r.URL.Path == "/sep38/quote/q1" && authed:
+		w.Write([]byte(`{"id": "q1", "expires_at": "2030-01-02T03:04:05Z", "total_price": "0.101", "price": "0.1", "sell_asset": "stellar:USDC:` + testQuoteIssuer + `", "sell_amount": "101", "buy_asset": "stellar:native", "buy_amount": "1000", "fee": {"total": "1", "asset": "stellar:USDC:` + testQuoteIssuer + `"}}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "quote not found"}`))
+	}
+}
+
+func TestQuoteAsset(t *testing.T) {
+	usdc, err := NewAssetMinimal("USDC", testQuoteIssuer)
+	require.NoError(t, err)
+	require.Equal(t, "stellar:USDC:"+testQuoteIssuer, QuoteAsset(usdc))
+	require.Equal(t, "stellar:native", QuoteAsset(AssetMinimal{AssetType: "native"}))
+
+	parsed, err := ParseQuoteAsset("stellar:USDC:" + testQuoteIssuer)
+	require.NoError(t, err)
+	require.Equal(t, usdc, parsed)
+	parsed, err = ParseQuoteAsset("stellar:native")
+	require.NoError(t, err)
+	require.Equal(t, "native", parsed.TypeString())
+
+	for _, bad := range []string{"iso4217:USD", "stellar:USDC", "stellar:USDC:GXYZ", "USDC:" + testQuoteIssuer} {
+		_, err = ParseQuoteAsset(bad)
+		require.Equal(t, ErrInvalidParameter{Key: "asset"}, err, bad)
+	}
+}
+
+func TestAnchorQuotes(t *testing.T) {
+	_, doer, done := newFakeAnchor(t)
+	defer done()
+
+	anchor, err := NewAnchor(testAnchorDomain, doer)
+	require.NoError(t, err)
+
+	usdc := "stellar:USDC:" + testQuoteIssuer
+	info, err := anchor.QuoteInfo()
+	require.NoError(t, err)
+	require.Len(t, info.Assets, 3)
+	require.Equal(t, "ACH", info.Assets[2].SellDeliveryMethods[0].Name)
+
+	prices, err := anchor.QuotePrices(usdc, "100")
+	require.NoError(t, err)
+	require.Len(t, prices, 1)
+	price, err := prices[0].PriceRat()
+	require.NoError(t, err)
+	require.Equal(t, big.NewRat(1, 10), price)
+
+	_, err = anchor.QuotePrice(QuoteRequest{SellAsset: usdc, BuyAsset: "stellar:native"})
+	require.Equal(t, ErrInvalidParameter{Key: "sell_amount"}, err)
+	indicative, err := anchor.QuotePrice(QuoteRequest{SellAsset: usdc, BuyAsset: "stellar:native", SellAmount: "101", Context: "sep6"})
+	require.NoError(t, err)
+	require.False(t, indicative.Firm())
+	require.Equal(t, "1000", indicative.BuyAmount)
+	require.Equal(t, usdc, indicative.SellAsset)
+	totalPrice, err := indicative.TotalPriceRat()
+	require.NoError(t, err)
+	require.Equal(t, big.NewRat(101, 1000), totalPrice)
+
+	req := QuoteRequest{SellAsset: usdc, BuyAsset: "stellar:native", BuyAmount: "1000", ExpireAfter: testQuoteExpires}
+	_, err = anchor.RequestQuote(req)
+	require.Equal(t, ErrAnchorAuthRequired, err)
+
+	require.NoError(t, anchor.Authenticate(seedStr(t, keypair.MustRandom())))
+	firm, err := anchor.RequestQuote(req)
+	require.NoError(t, err)
+	require.True(t, firm.Firm())
+	require.Equal(t, "q1", firm.ID)
+	require.True(t, testQuoteExpires.Equal(firm.ExpiresAt))
+	require.False(t, firm.Expired(testQuoteExpires.Add(-time.Second)))
+	require.True(t, firm.Expired(testQuoteExpires))
+	require.Equal(t, "1", firm.Fee.Total)
+
+	again, err := anchor.GetQuote("q1")
+	require.NoError(t, err)
+	require.Equal(t, firm.SellAmount, again.SellAmount)
+
+	_, err = anchor.GetQuote("q2")
+	require.Equal(t, ErrAnchorStatus{StatusCode: http.StatusNotFound, Message: "quote not found"}, err)
+}
+
+func TestCompareQuote(t *testing.T) {
+	quote := Quote{
+		SellAsset:  "stellar:USDC:" + testQuoteIssuer,
+		SellAmount: "101",
+		BuyAsset:   "stellar:native",
+		BuyAmount:  "1000",
+	}
+	path := func(source, dest string) FullPath {
+		return FullPath{
+			SourceAmount:         source,
+			SourceAssetType:      "credit_alphanum4",
+			SourceAssetCode:      "USDC",
+			SourceAssetIssuer:    testQuoteIssuer,
+			DestinationAmount:    dest,
+			DestinationAssetType: "native",
+		}
+	}
+	other := path("1", "100")
+	other.SourceAssetCode = "EURT"
+
+	// the quote is better than both matching paths
+	paths := []FullPath{other, path("105", "1000"), path("102", "1000")}
+	cmp, err := CompareQuote(quote, paths)
+	require.NoError(t, err)
+	require.True(t, cmp.QuoteBetter)
+	require.Equal(t, &paths[2], cmp.Path)
+	require.Equal(t, big.NewRat(1000, 101), cmp.QuoteRate)
+	require.Equal(t, big.NewRat(1000, 102), cmp.PathRate)
+	require.Equal(t, big.NewRat(1, 101), cmp.Difference)
+
+	// a path is better
+	paths = append(paths, path("100", "1000"))
+	cmp, err = CompareQuote(quote, paths)
+	require.NoError(t, err)
+	require.False(t, cmp.QuoteBetter)
+	require.Equal(t, "100", cmp.Path.SourceAmount)
+	require.Equal(t, -1, cmp.Difference.Sign())
+
+	// no paths for the pair
+	cmp, err = CompareQuote(quote, []FullPath{other})
+	require.NoError(t, err)
+	require.True(t, cmp.QuoteBetter)
+	require.Nil(t, cmp.Path)
+	require.Nil(t, cmp.Difference)
+
+	// paths with nothing on one side are skipped
+	cmp, err = CompareQuote(quote, []FullPath{path("0", "1000"), path("100", "0")})
+	require.NoError(t, err)
+	require.True(t, cmp.QuoteBetter)
+	require.Nil(t, cmp.Path)
+	require.Nil(t, cmp.Difference)
+
+	_, err = FullPathRate(path("0", "1000"))
+	require.Equal(t, ErrInvalidParameter{Key: "source_amount"}, err)
+
+	zero := quote
+	zero.SellAmount = "0"
+	_, err = zero.Rate()
+	require.Equal(t, ErrInvalidParameter{Key: "sell_amount"}, err)
+	_, err = CompareQuote(zero, paths)
+	require.Equal(t, ErrInvalidParameter{Key: "sell_amount"}, err)
+
+	quote.BuyAsset = "iso4217:USD"
+	_, err = CompareQuote(quote, paths)
+	require.Equal(t, ErrInvalidParameter{Key: "buy_asset"}, err)
+}
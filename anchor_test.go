@@ -0,0 +1,287 @@
+package stellarnet
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+const testAnchorDomain = "testanchor.com"
+
+// fakeAnchor is a SEP6/SEP10/SEP24 anchor that serves testAnchorDomain.
+type fakeAnchor struct {
+	sync.Mutex
+	t       *testing.T
+	signer  *keypair.Full
+	account *keypair.Full
+	polls   int
+}
+
+func (f *fakeAnchor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	if r.URL.Path == "/.well-known/stellar.toml" {
+		fmt.Fprintf(w, "NETWORK_PASSPHRASE=%q\nTRANSFER_SERVER=\"https://%s/sep6\"\nTRANSFER_SERVER_SEP0024=\"https://%s/sep24/\"\nWEB_AUTH_ENDPOINT=\"https://%s/auth\"\nSIGNING_KEY=%q\nANCHOR_QUOTE_SERVER=\"https://%s/sep38\"\n",
+			NetworkPassphrase(), testAnchorDomain, testAnchorDomain, testAnchorDomain, f.signer.Address(), testAnchorDomain)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/auth" {
+		f.auth(w, r)
+		return
+	}
+
+	authed := r.Header.Get("Authorization") == "Bearer test-token"
+	if strings.HasPrefix(r.URL.Path, "/sep38/") {
+		f.quote(w, r, authed)
+		return
+	}
+	q := r.URL.Query()
+	switch r.URL.Path {
+	case "/sep6/info", "/sep24/info":
+		w.Write([]byte(`{"deposit": {"USD": {"enabled": true, "min_amount": 10, "fee_fixed": 1.5, "fields": {"email_address": {"description": "your email", "optional": true}}}}, "withdraw": {"USD": {"enabled": true, "types": {"bank_account": {"fields": {"dest": {"description": "account number"}}}}}}, "transaction": {"enabled": true, "authentication_required": true}}`))
+	case "/sep6/deposit":
+		if !authed {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"type": "non_interactive_customer_info_needed", "fields": ["first_name", "last_name"]}`))
+			return
+		}
+		require.Equal(f.t, "hash", q.Get("memo_type"))
+		w.Write([]byte(`{"how": "bank account 1234", "id": "dep1", "eta": 3600, "min_amount": 10}`))
+	case "/sep6/withdraw":
+		if q.Get("asset_code") != "USD" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "unknown asset"}`))
+			return
+		}
+		fmt.Fprintf(w, `{"account_id": %q, "memo_type": "id", "memo": "123", "id": "wd1"}`, f.account.Address())
+	case "/sep24/transactions/withdraw/interactive", "/sep24/transactions/deposit/interactive":
+		if !authed || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "forbidden"}`))
+			return
+		}
+		require.Equal(f.t, "USD", r.PostFormValue("asset_code"))
+		w.Write([]byte(`{"type": "interactive_customer_info_needed", "url": "https://testanchor.com/flow?id=wd24", "id": "wd24"}`))
+	case "/sep24/transaction", "/sep6/transaction":
+		if !authed {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "forbidden"}`))
+			return
+		}
+		if q.Get("id") != "wd24" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "not found"}`))
+			return
+		}
+		f.polls++
+		status := AnchorStatusIncomplete
+		if f.polls > 2 {
+			status = AnchorStatusPendingUserTransferStart
+		}
+		fmt.Fprintf(w, `{"transaction": {"id": "wd24", "kind": "withdrawal", "status": %q, "amount_in": "25.0", "withdraw_anchor_account": %q, "withdraw_memo": "AQIDBAUGBwgJCgsMDQ4PEBESExQVFhcYGRobHB0eHyA=", "withdraw_memo_type": "hash"}}`,
+			status, f.account.Address())
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeAnchor) auth(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		require.Equal(f.t, testAnchorDomain, r.URL.Query().Get("home_domain"))
+		tx, err := txnbuild.BuildChallengeTx(f.signer.Seed(), r.URL.Query().Get("account"), testAnchorDomain, testAnchorDomain, NetworkPassphrase(), time.Minute)
+		require.NoError(f.t, err)
+		challenge, err := tx.Base64()
+		require.NoError(f.t, err)
+		json.NewEncoder(w).Encode(map[string]string{"transaction": challenge, "network_passphrase": NetworkPassphrase()})
+		return
+	}
+
+	var body struct {
+		Transaction string `json:"transaction"`
+	}
+	require.NoError(f.t, json.NewDecoder(r.Body).Decode(&body))
+	tx, clientAccountID, _, err := txnbuild.ReadChallengeTx(body.Transaction, f.signer.Address(), NetworkPassphrase(), testAnchorDomain, []string{testAnchorDomain})
+	require.NoError(f.t, err)
+	_, err = txnbuild.VerifyChallengeTxSigners(body.Transaction, f.signer.Address(), NetworkPassphrase(), testAnchorDomain, []string{testAnchorDomain}, clientAccountID)
+	require.NoError(f.t, err)
+	require.NotNil(f.t, tx)
+	w.Write([]byte(`{"token": "test-token"}`))
+}
+
+// anchorDoer sends all requests for testAnchorDomain to a local server.
+type anchorDoer struct {
+	client *http.Client
+	host   string
+}
+
+func (d *anchorDoer) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == testAnchorDomain {
+		req.URL.Host = d.host
+	}
+	return d.client.Do(req)
+}
+
+func newFakeAnchor(t *testing.T) (*fakeAnchor, *anchorDoer, func()) {
+	fake := &fakeAnchor{t: t, signer: keypair.MustRandom(), account: keypair.MustRandom()}
+	server := httptest.NewTLSServer(fake)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	return fake, &anchorDoer{client: server.Client(), host: u.Host}, server.Close
+}
+
+func TestAnchorSEP6(t *testing.T) {
+	fake, doer, done := newFakeAnchor(t)
+	defer done()
+
+	anchor, err := NewAnchor(testAnchorDomain, doer)
+	require.NoError(t, err)
+	require.Equal(t, "https://testanchor.com/sep6", anchor.Toml.TransferServer)
+	require.Equal(t, fake.signer.Address(), anchor.Toml.SigningKey)
+
+	info, err := anchor.Info(AnchorSEP6)
+	require.NoError(t, err)
+	require.True(t, info.Deposit["USD"].Enabled)
+	require.Equal(t, "10", info.Deposit["USD"].MinAmount.String())
+	require.Equal(t, "1.5", info.Deposit["USD"].FeeFixed.String())
+	require.True(t, info.Deposit["USD"].Fields["email_address"].Optional)
+	require.Contains(t, info.Withdraw["USD"].Types, "bank_account")
+	require.True(t, info.Transaction.AuthenticationRequired)
+
+	user := keypair.MustRandom()
+	depositReq := AnchorDepositRequest{AssetCode: "USD", Account: addressStr(t, user), Memo: NewMemoHash(MemoHash{1, 2, 3})}
+	_, err = anchor.Deposit(depositReq)
+	require.Equal(t, ErrAnchorCustomerInfoNeeded{Type: "non_interactive_customer_info_needed", Fields: []string{"first_name", "last_name"}}, err)
+
+	_, err = anchor.Transaction(AnchorSEP6, "wd24")
+	require.Equal(t, ErrAnchorAuthRequired, err)
+
+	require.NoError(t, anchor.Authenticate(seedStr(t, user)))
+	require.Equal(t, "test-token", anchor.Token())
+
+	deposit, err := anchor.Deposit(depositReq)
+	require.NoError(t, err)
+	require.Equal(t, "bank account 1234", deposit.How)
+	require.Equal(t, "dep1", deposit.ID)
+	require.Equal(t, 3600, deposit.ETA)
+
+	withdraw, err := anchor.Withdraw(AnchorWithdrawRequest{AssetCode: "USD", Type: "bank_account", Dest: "1234"})
+	require.NoError(t, err)
+	payment, err := withdraw.Payment()
+	require.NoError(t, err)
+	require.Equal(t, AddressStr(fake.account.Address()), payment.Account)
+	require.Equal(t, MemoTypeID, payment.Memo.Type)
+	require.Equal(t, uint64(123), *payment.Memo.ID)
+
+	_, err = anchor.Withdraw(AnchorWithdrawRequest{AssetCode: "EUR"})
+	require.Equal(t, ErrAnchorStatus{StatusCode: http.StatusBadRequest, Message: "unknown asset"}, err)
+}
+
+func TestAnchorSEP24(t *testing.T) {
+	fake, doer, done := newFakeAnchor(t)
+	defer done()
+
+	anchor, err := NewAnchor(testAnchorDomain, doer)
+	require.NoError(t, err)
+
+	user := keypair.MustRandom()
+	req := AnchorWithdrawRequest{AssetCode: "USD", Account: addressStr(t, user), Amount: "25"}
+	_, err = anchor.WithdrawInteractive(req)
+	require.Equal(t, ErrAnchorAuthRequired, err)
+
+	require.NoError(t, anchor.Authenticate(seedStr(t, user)))
+	res, err := anchor.WithdrawInteractive(req)
+	require.NoError(t, err)
+	require.Equal(t, "wd24", res.ID)
+	require.Equal(t, "https://testanchor.com/flow?id=wd24", res.URL)
+
+	_, err = anchor.DepositInteractive(AnchorDepositRequest{AssetCode: "USD", Account: addressStr(t, user)})
+	require.NoError(t, err)
+
+	tx, err := anchor.WaitForTransaction(context.Background(), AnchorSEP24, res.ID, time.Millisecond, AnchorStatusPendingUserTransferStart)
+	require.NoError(t, err)
+	require.Equal(t, AnchorStatusPendingUserTransferStart, tx.Status)
+	require.Equal(t, 3, fake.polls)
+
+	payment, err := tx.WithdrawPayment()
+	require.NoError(t, err)
+	require.Equal(t, AddressStr(fake.account.Address()), payment.Account)
+	require.Equal(t, MemoTypeHash, payment.Memo.Type)
+	require.Equal(t, byte(1), payment.Memo.Hash[0])
+	require.Equal(t, byte(32), payment.Memo.Hash[31])
+
+	issuer := keypair.MustRandom()
+	asset, err := NewAssetMinimal("USD", issuer.Address())
+	require.NoError(t, err)
+	sig, err := WithdrawPaymentTransaction(seedStr(t, user), payment, asset, tx.AmountIn.String(), &testSeqnoProv{seqno: 10}, nil, txnbuild.MinBaseFee)
+	require.NoError(t, err)
+	var txEnv xdr.TransactionEnvelope
+	require.NoError(t, xdr.SafeUnmarshalBase64(sig.Signed, &txEnv))
+	require.Equal(t, payment.Account.String(), txEnv.Operations()[0].Body.PaymentOp.Destination.Address())
+	memo := txEnv.Memo()
+	require.Equal(t, *payment.Memo.Hash, MemoHash(*memo.Hash))
+
+	// polling stops when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = anchor.WaitForTransaction(ctx, AnchorSEP24, res.ID, time.Millisecond, AnchorStatusCompleted)
+	require.Equal(t, context.Canceled, err)
+
+	_, err = anchor.Transaction(AnchorSEP24, "missing")
+	require.Equal(t, ErrAnchorStatus{StatusCode: http.StatusNotFound, Message: "not found"}, err)
+}
+
+func TestAnchorToml(t *testing.T) {
+	_, doer, done := newFakeAnchor(t)
+	defer done()
+
+	_, err := NewAnchor("not a domain", doer)
+	require.Equal(t, ErrInvalidParameter{Key: "domain"}, err)
+
+	anchor, err := NewAnchor(testAnchorDomain, doer)
+	require.NoError(t, err)
+	anchor.Toml.TransferServerSEP24 = ""
+	_, err = anchor.Info(AnchorSEP24)
+	require.Equal(t, ErrAnchorNotSupported{Domain: testAnchorDomain, Key: "TRANSFER_SERVER_SEP0024"}, err)
+	require.True(t, strings.HasSuffix(anchor.Toml.WebAuthEndpoint, "/auth"))
+}
+
+func TestAnchorMemo(t *testing.T) {
+	var h MemoHash
+	h[0], h[31] = 1, 2
+	encoded := base64.StdEncoding.EncodeToString(h[:])
+
+	for _, memoType := range []string{"return", "RETURN", "Return"} {
+		m, err := anchorMemo(encoded, memoType)
+		require.NoError(t, err)
+		require.Equal(t, NewMemoReturn(h), m, memoType)
+	}
+	m, err := anchorMemo(encoded, "HASH")
+	require.NoError(t, err)
+	require.Equal(t, NewMemoHash(h), m)
+	_, err = anchorMemo("short", "return")
+	require.Equal(t, ErrInvalidParameter{Key: "memo"}, err)
+
+	for _, memo := range []*Memo{NewMemoText("hi"), NewMemoID(7), NewMemoHash(h), NewMemoReturn(h)} {
+		v := url.Values{}
+		require.NoError(t, setAnchorMemo(v, "memo", "memo_type", memo))
+		back, err := anchorMemo(v.Get("memo"), v.Get("memo_type"))
+		require.NoError(t, err)
+		require.Equal(t, memo, back)
+	}
+}
@@ -241,19 +241,23 @@ func AssetList(cursor string, limit int, order string) (res []AssetSummary, next
 
 	assets := make([]AssetSummary, len(page.Embedded.Records))
 	for i, r := range page.Embedded.Records {
-		assets[i] = AssetSummary{
-			UnverifiedWellKnownLink: r.Links.WellKnown.Href,
-			AssetType:               r.AssetType,
-			AssetCode:               r.AssetCode,
-			AssetIssuer:             r.AssetIssuer,
-			Amount:                  r.Amount,
-			NumAccounts:             r.NumAccounts,
-		}
+		assets[i] = assetEmbedSummary(r)
 	}
 
 	return assets, nextCursor, nil
 }
 
+func assetEmbedSummary(r AssetEmbed) AssetSummary {
+	return AssetSummary{
+		UnverifiedWellKnownLink: r.Links.WellKnown.Href,
+		AssetType:               r.AssetType,
+		AssetCode:               r.AssetCode,
+		AssetIssuer:             r.AssetIssuer,
+		Amount:                  r.Amount,
+		NumAccounts:             r.NumAccounts,
+	}
+}
+
 func makeXDRChangeTrustAsset(assetCode string, issuerID AddressStr) (xdr.ChangeTrustAsset, error) {
 	if len(assetCode) == 0 && len(issuerID) == 0 {
 		return xdr.NewChangeTrustAsset(xdr.AssetTypeAssetTypeNative, nil)
@@ -269,7 +273,7 @@ func makeXDRChangeTrustAsset(assetCode string, issuerID AddressStr) (xdr.ChangeT
 		asset := xdr.AlphaNum4{Issuer: issuer}
 		copy(asset.AssetCode[:], []byte(assetCode[0:x]))
 		return xdr.NewChangeTrustAsset(xdr.AssetTypeAssetTypeCreditAlphanum4, asset)
-	case x >= 5 && x <= 1:
+	case x >= 5 && x <= 12:
 		asset := xdr.AlphaNum12{Issuer: issuer}
 		copy(asset.AssetCode[:], []byte(assetCode[0:x]))
 		return xdr.NewChangeTrustAsset(xdr.AssetTypeAssetTypeCreditAlphanum12, asset)
@@ -424,9 +428,30 @@ func XDRAssetAmountSummary(amt xdr.Int64, asset xdr.Asset) string {
 
 // XDRChangeTrustAssetSummary returns a string summary of an xdr.ChangeTrustAsset.
 func XDRChangeTrustAssetSummary(x xdr.ChangeTrustAsset) string {
+	if x.Type == xdr.AssetTypeAssetTypePoolShare {
+		return xdrPoolShareSummary(x)
+	}
 	a, err := ChangeTrustXDRToAssetMinimal(x)
 	if err != nil {
 		return "invalid asset"
 	}
 	return AssetBaseSummary(a)
 }
+
+// xdrPoolShareSummary returns a summary of a pool share change trust
+// asset.
+func xdrPoolShareSummary(x xdr.ChangeTrustAsset) string {
+	params, ok := x.GetLiquidityPool()
+	if !ok {
+		return "invalid asset"
+	}
+	cp, ok := params.GetConstantProduct()
+	if !ok {
+		return "invalid asset"
+	}
+	id, err := xdr.NewPoolId(cp.AssetA, cp.AssetB, cp.Fee)
+	if err != nil {
+		return "invalid asset"
+	}
+	return fmt.Sprintf("liquidity pool %x (%s, %s)", id[:], XDRAssetSummary(cp.AssetA), XDRAssetSummary(cp.AssetB))
+}
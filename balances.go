@@ -0,0 +1,126 @@
+package stellarnet
+
+import (
+	"math"
+
+	horizonProtocol "github.com/stellar/go/protocols/horizon"
+)
+
+// SpendableBalance is how much of a balance an account can send and
+// receive.
+type SpendableBalance struct {
+	Asset           AssetMinimal
+	LiquidityPoolID string
+	Balance         string
+	// Spendable is the balance minus selling liabilities and, for
+	// lumens, the minimum balance.  It is zero if the trustline isn't
+	// authorized.  Pool shares can't be sent, only withdrawn.
+	Spendable string
+	// Receivable is how much more the account can receive: the
+	// trustline limit minus the balance and buying liabilities.  It is
+	// zero if the trustline isn't authorized.
+	Receivable                      string
+	BuyingLiabilities               string
+	SellingLiabilities              string
+	Authorized                      bool
+	AuthorizedToMaintainLiabilities bool
+}
+
+// MinimumBalance returns the minimum lumen balance of acct given the
+// base reserve in stroops: two base reserves for the account, one for
+// each subentry and one for each entry it sponsors, less one for each
+// of its entries someone else sponsors.
+func MinimumBalance(acct *horizonProtocol.Account, reserve int64) int64 {
+	entries := 2 + int64(acct.SubentryCount) + int64(acct.NumSponsoring) - int64(acct.NumSponsored)
+	if entries < 0 {
+		entries = 0
+	}
+	return reserve * entries
+}
+
+// SpendableBalances returns the spendable balances of acct given the
+// base reserve in stroops.
+func SpendableBalances(acct *horizonProtocol.Account, reserve int64) ([]SpendableBalance, error) {
+	minimum := MinimumBalance(acct, reserve)
+	res := make([]SpendableBalance, len(acct.Balances))
+	for i, b := range acct.Balances {
+		balance, err := parseOptionalAmount(b.Balance)
+		if err != nil {
+			return nil, err
+		}
+		buying, err := parseOptionalAmount(b.BuyingLiabilities)
+		if err != nil {
+			return nil, err
+		}
+		selling, err := parseOptionalAmount(b.SellingLiabilities)
+		if err != nil {
+			return nil, err
+		}
+
+		s := SpendableBalance{
+			Balance:            StringFromStellarAmount(balance),
+			BuyingLiabilities:  StringFromStellarAmount(buying),
+			SellingLiabilities: StringFromStellarAmount(selling),
+			Authorized:         true,
+		}
+		var spendable, receivable int64
+		switch {
+		case b.Type == "native":
+			s.Asset = AssetMinimal{AssetType: "native"}
+			spendable = balance - selling - minimum
+			receivable = math.MaxInt64 - balance - buying
+		case b.LiquidityPoolId != "":
+			s.Asset = AssetMinimal{AssetType: b.Type}
+			s.LiquidityPoolID = b.LiquidityPoolId
+			limit, err := parseOptionalAmount(b.Limit)
+			if err != nil {
+				return nil, err
+			}
+			receivable = limit - balance
+		default:
+			s.Asset = AssetMinimal{AssetType: b.Type, AssetCode: b.Code, AssetIssuer: b.Issuer}
+			if b.IsAuthorized != nil {
+				s.Authorized = *b.IsAuthorized
+			}
+			if b.IsAuthorizedToMaintainLiabilities != nil {
+				s.AuthorizedToMaintainLiabilities = *b.IsAuthorizedToMaintainLiabilities
+			}
+			if s.Authorized {
+				limit, err := parseOptionalAmount(b.Limit)
+				if err != nil {
+					return nil, err
+				}
+				spendable = balance - selling
+				receivable = limit - balance - buying
+			}
+		}
+		if spendable < 0 {
+			spendable = 0
+		}
+		if receivable < 0 {
+			receivable = 0
+		}
+		s.Spendable = StringFromStellarAmount(spendable)
+		s.Receivable = StringFromStellarAmount(receivable)
+		res[i] = s
+	}
+	return res, nil
+}
+
+// SpendableBalances returns how much of each of the account's balances
+// it can send and receive, using the base reserve of the latest ledger.
+func (a *Account) SpendableBalances() ([]SpendableBalance, error) {
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+	return SpendableBalances(a.internal, networkParamsOrDefault().BaseReserve)
+}
+
+// parseOptionalAmount parses a stellar amount that horizon can leave
+// out, like liabilities.
+func parseOptionalAmount(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return ParseStellarAmount(s)
+}
@@ -0,0 +1,80 @@
+package stellarnet
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpendableBalances(t *testing.T) {
+	kp := keypair.MustRandom()
+	issuer := keypair.MustRandom().Address()
+	ledgers := true
+	_, restore := withFakeHorizon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ledgers":
+			if !ledgers {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"status": 404, "title": "Resource Missing"}`))
+				return
+			}
+			require.Equal(t, "desc", r.URL.Query().Get("order"))
+			fmt.Fprint(w, `{"_embedded": {"records": [{"sequence": 7, "base_fee_in_stroops": 100, "base_reserve_in_stroops": 10000000}]}}`)
+		case "/accounts/" + kp.Address():
+			fmt.Fprintf(w, `{"id": %q, "account_id": %q, "sequence": "1", "subentry_count": 3, "num_sponsoring": 1, "num_sponsored": 2, "balances": [
+				{"balance": "100.0000000", "limit": "1000.0000000", "buying_liabilities": "50.0000000", "selling_liabilities": "30.0000000",
+					"asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": %q, "is_authorized": true},
+				{"balance": "20.0000000", "limit": "1000.0000000", "asset_type": "credit_alphanum4", "asset_code": "EUR", "asset_issuer": %q,
+					"is_authorized": false, "is_authorized_to_maintain_liabilities": true},
+				{"balance": "10.0000000", "buying_liabilities": "0.0000000", "selling_liabilities": "2.5000000", "asset_type": "native"}]}`,
+				kp.Address(), kp.Address(), issuer, issuer)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer restore()
+
+	reserve, err := BaseReserve()
+	require.NoError(t, err)
+	require.Equal(t, "1.0000000", reserve)
+
+	acct := NewAccount(addressStr(t, kp))
+	details, err := acct.Details()
+	require.NoError(t, err)
+	require.Equal(t, "1.0000000", details.BaseReserve)
+	// (2 + 3 subentries + 1 sponsoring - 2 sponsored) * 1 XLM
+	require.Equal(t, "4.0000000", details.MinimumBalance)
+	require.Equal(t, "3.5000000", details.Available)
+	require.Len(t, details.Spendable, 3)
+
+	usd := details.Spendable[0]
+	require.Equal(t, AssetMinimal{AssetType: "credit_alphanum4", AssetCode: "USD", AssetIssuer: issuer}, usd.Asset)
+	require.Equal(t, "70.0000000", usd.Spendable)
+	require.Equal(t, "850.0000000", usd.Receivable)
+	require.True(t, usd.Authorized)
+
+	eur := details.Spendable[1]
+	require.Equal(t, "0.0000000", eur.Spendable)
+	require.Equal(t, "0.0000000", eur.Receivable)
+	require.False(t, eur.Authorized)
+	require.True(t, eur.AuthorizedToMaintainLiabilities)
+
+	native := details.Spendable[2]
+	require.Equal(t, "native", native.Asset.AssetType)
+	require.Equal(t, "3.5000000", native.Spendable)
+	require.Equal(t, "2.5000000", native.SellingLiabilities)
+
+	// without the latest ledger, once the cached parameters expire, the
+	// default base reserve is used
+	ledgers = false
+	networkParamsCache.Lock()
+	networkParamsCache.expires = time.Time{}
+	networkParamsCache.Unlock()
+	available, err := acct.AvailableBalanceXLM()
+	require.NoError(t, err)
+	require.Equal(t, "5.5000000", available)
+}
@@ -0,0 +1,232 @@
+package stellarnet
+
+import (
+	"strings"
+
+	"github.com/stellar/go/protocols/horizon/base"
+	"github.com/stellar/go/protocols/horizon/effects"
+)
+
+// EffectIterator iterates over effects.
+type EffectIterator struct {
+	*historyIterator
+}
+
+func newEffectIterator(path string, opts HistoryOptions) *EffectIterator {
+	return &EffectIterator{newHistoryIterator(path, opts,
+		func() historyPage { return &EffectsPage{} },
+		func(p historyPage, i int) bool {
+			return opts.pastTime(p.(*EffectsPage).Embedded.Records[i].CreatedAt)
+		})}
+}
+
+// EffectIterator returns an iterator over the account's effects.
+func (a *Account) EffectIterator(opts HistoryOptions) *EffectIterator {
+	return newEffectIterator("/accounts/"+a.address.String()+"/effects", opts)
+}
+
+// OperationEffectIterator returns an iterator over the effects of an
+// operation.
+func OperationEffectIterator(operationID string, opts HistoryOptions) *EffectIterator {
+	return newEffectIterator("/operations/"+operationID+"/effects", opts)
+}
+
+// TxEffectIterator returns an iterator over the effects of all the
+// operations in a transaction.
+func TxEffectIterator(txID string, opts HistoryOptions) *EffectIterator {
+	txID, err := CheckTxID(txID)
+	if err != nil {
+		return &EffectIterator{&historyIterator{err: err, done: true}}
+	}
+	return newEffectIterator("/transactions/"+txID+"/effects", opts)
+}
+
+// Next advances to the next effect.
+func (it *EffectIterator) Next() bool { return it.next() }
+
+// Err returns the error that stopped the iterator, if any.
+func (it *EffectIterator) Err() error { return it.err }
+
+// Effect returns the current effect.
+func (it *EffectIterator) Effect() Effect {
+	return it.page.(*EffectsPage).Embedded.Records[it.index]
+}
+
+// Cursor returns the paging token of the current effect.
+func (it *EffectIterator) Cursor() string {
+	if it.page == nil {
+		return it.opts.Cursor
+	}
+	return it.Effect().PagingToken
+}
+
+// OperationEffects returns all the effects of an operation, in order.
+func OperationEffects(operationID string) ([]Effect, error) {
+	return collectEffects(OperationEffectIterator(operationID, HistoryOptions{Order: "asc"}))
+}
+
+// TxEffects returns all the effects of a transaction, in order.
+func TxEffects(txID string) ([]Effect, error) {
+	return collectEffects(TxEffectIterator(txID, HistoryOptions{Order: "asc"}))
+}
+
+func collectEffects(it *EffectIterator) ([]Effect, error) {
+	var all []Effect
+	for it.Next() {
+		all = append(all, it.Effect())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// BalanceChange is the change in an account's balance of an asset.
+// Delta is a signed amount string.
+type BalanceChange struct {
+	Account string
+	Asset   AssetMinimal
+	Delta   string
+}
+
+// EffectBalanceChanges adds up the balance changes in a list of effects,
+// for example from TxEffects.  There is one BalanceChange per account and
+// asset, in the order they first appear, and changes that add up to zero
+// are included.  The effects of an operation must all be in the list.
+//
+// Effects don't include transaction fees.  Liquidity pool shares aren't
+// included either, only the assets deposited into and withdrawn from
+// pools.
+func EffectBalanceChanges(all []Effect) ([]BalanceChange, error) {
+	// The source account of a path payment has an account_debited
+	// effect and trade effects for the offers the payment crossed on
+	// the way.  The debit is the real change, so those trades are skipped.
+	type opAccount struct {
+		op, account string
+	}
+	debited := make(map[opAccount]bool)
+	for _, e := range all {
+		if d, ok := e.Details.(effects.AccountDebited); ok {
+			debited[opAccount{op: effectOperationID(e), account: d.Account}] = true
+		}
+	}
+
+	var changes balanceChanges
+	for _, e := range all {
+		var err error
+		switch d := e.Details.(type) {
+		case effects.AccountCreated:
+			err = changes.add(d.Account, AssetMinimal{AssetType: "native"}, d.StartingBalance, 1)
+		case effects.AccountCredited:
+			err = changes.add(d.Account, effectAsset(d.Asset), d.Amount, 1)
+		case effects.AccountDebited:
+			err = changes.add(d.Account, effectAsset(d.Asset), d.Amount, -1)
+		case effects.Trade:
+			if debited[opAccount{op: effectOperationID(e), account: d.Account}] {
+				continue
+			}
+			// each side of a trade has its own effect
+			err = changes.add(d.Account, effectAsset(base.Asset{Type: d.BoughtAssetType, Code: d.BoughtAssetCode, Issuer: d.BoughtAssetIssuer}), d.BoughtAmount, 1)
+			if err == nil {
+				err = changes.add(d.Account, effectAsset(base.Asset{Type: d.SoldAssetType, Code: d.SoldAssetCode, Issuer: d.SoldAssetIssuer}), d.SoldAmount, -1)
+			}
+		case effects.LiquidityPoolDeposited:
+			for _, r := range d.ReservesDeposited {
+				if err = changes.addCanonical(d.Account, r.Asset, r.Amount, -1); err != nil {
+					break
+				}
+			}
+		case effects.LiquidityPoolWithdrew:
+			for _, r := range d.ReservesReceived {
+				if err = changes.addCanonical(d.Account, r.Asset, r.Amount, 1); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return changes.list(), nil
+}
+
+// effectOperationID returns the ID of the operation that had the
+// effect.  Effect IDs are the operation ID and the effect's index.
+func effectOperationID(e Effect) string {
+	return strings.SplitN(e.ID, "-", 2)[0]
+}
+
+// effectAsset converts a horizon asset to an AssetMinimal.
+func effectAsset(a base.Asset) AssetMinimal {
+	if a.Type == "native" {
+		return AssetMinimal{AssetType: "native"}
+	}
+	return AssetMinimal{AssetType: a.Type, AssetCode: a.Code, AssetIssuer: a.Issuer}
+}
+
+// balanceChanges adds up amounts per account and asset.
+type balanceChanges struct {
+	keys   []balanceChangeKey
+	deltas map[balanceChangeKey]int64
+}
+
+type balanceChangeKey struct {
+	account string
+	asset   AssetMinimal
+}
+
+// add adds sign * amount to the account's balance of asset.
+func (b *balanceChanges) add(account string, asset AssetMinimal, amount string, sign int64) error {
+	v, err := ParseStellarAmount(amount)
+	if err != nil {
+		return err
+	}
+	b.addInt(account, asset, sign*v)
+	return nil
+}
+
+// addCanonical is add for an asset in horizon's "native" or
+// "CODE:ISSUER" form.
+func (b *balanceChanges) addCanonical(account, asset, amount string, sign int64) error {
+	a, err := canonicalAssetMinimal(asset)
+	if err != nil {
+		return err
+	}
+	return b.add(account, a, amount, sign)
+}
+
+func (b *balanceChanges) addInt(account string, asset AssetMinimal, v int64) {
+	if b.deltas == nil {
+		b.deltas = make(map[balanceChangeKey]int64)
+	}
+	key := balanceChangeKey{account: account, asset: asset}
+	if _, ok := b.deltas[key]; !ok {
+		b.keys = append(b.keys, key)
+	}
+	b.deltas[key] += v
+}
+
+func (b *balanceChanges) list() []BalanceChange {
+	var all []BalanceChange
+	for _, key := range b.keys {
+		all = append(all, BalanceChange{
+			Account: key.account,
+			Asset:   key.asset,
+			Delta:   StringFromStellarAmount(b.deltas[key]),
+		})
+	}
+	return all
+}
+
+// canonicalAssetMinimal parses an asset in horizon's "native" or
+// "CODE:ISSUER" form.
+func canonicalAssetMinimal(s string) (AssetMinimal, error) {
+	if s == "native" {
+		return AssetMinimal{AssetType: "native"}, nil
+	}
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return AssetMinimal{}, ErrInvalidParameter{Key: "asset"}
+	}
+	return NewAssetMinimal(parts[0], parts[1])
+}
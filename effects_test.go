@@ -0,0 +1,97 @@
+package stellarnet
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stellar/go/protocols/horizon/effects"
+	"github.com/stretchr/testify/require"
+)
+
+const testEffectsIssuer = "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX"
+
+// testEffects are the effects of a path payment of XLM to USD that
+// crossed one offer, followed by a trust line and an unknown effect.
+var testEffects = []string{
+	`"account": "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", "type": "account_debited", "type_i": 3, "asset_type": "native", "amount": "10.0000000"`,
+	`"account": "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", "type": "account_credited", "type_i": 2, "asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": "` + testEffectsIssuer + `", "amount": "2.5000000"`,
+	`"account": "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", "type": "trade", "type_i": 33, "seller": "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX", "offer_id": "7", "sold_amount": "10.0000000", "sold_asset_type": "native", "bought_amount": "2.5000000", "bought_asset_type": "credit_alphanum4", "bought_asset_code": "USD", "bought_asset_issuer": "` + testEffectsIssuer + `"`,
+	`"account": "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX", "type": "trade", "type_i": 33, "seller": "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", "offer_id": "7", "sold_amount": "2.5000000", "sold_asset_type": "credit_alphanum4", "sold_asset_code": "USD", "sold_asset_issuer": "` + testEffectsIssuer + `", "bought_amount": "10.0000000", "bought_asset_type": "native"`,
+	`"account": "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", "type": "trustline_created", "type_i": 20, "asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": "` + testEffectsIssuer + `", "limit": "922337203685.4775807"`,
+	`"account": "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", "type": "something_new", "type_i": 999`,
+}
+
+func TestTxEffects(t *testing.T) {
+	fake, done := withHistoryHorizon(t)
+	defer done()
+
+	all, err := TxEffects(testTxHash(1))
+	require.NoError(t, err)
+	require.Len(t, all, len(testEffects))
+	require.Len(t, fake.requests, 1)
+
+	debit, ok := all[0].Details.(effects.AccountDebited)
+	require.True(t, ok)
+	require.Equal(t, "10.0000000", debit.Amount)
+	require.Equal(t, "10.0000000", all[0].Amount)
+	trade, ok := all[2].Details.(effects.Trade)
+	require.True(t, ok)
+	require.Equal(t, int64(7), trade.OfferID)
+	trustline, ok := all[4].Details.(effects.TrustlineCreated)
+	require.True(t, ok)
+	require.Equal(t, "USD", trustline.Code)
+	require.Equal(t, "something_new", all[5].Details.GetType())
+
+	changes, err := EffectBalanceChanges(all)
+	require.NoError(t, err)
+	usd := AssetMinimal{AssetType: "credit_alphanum4", AssetCode: "USD", AssetIssuer: testEffectsIssuer}
+	xlm := AssetMinimal{AssetType: "native"}
+	require.Equal(t, []BalanceChange{
+		{Account: "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", Asset: xlm, Delta: "-10.0000000"},
+		{Account: "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", Asset: usd, Delta: "2.5000000"},
+		{Account: testEffectsIssuer, Asset: xlm, Delta: "10.0000000"},
+		{Account: testEffectsIssuer, Asset: usd, Delta: "-2.5000000"},
+	}, changes)
+
+	// without a debit in the operation, both sides of a trade count
+	changes, err = EffectBalanceChanges(all[2:3])
+	require.NoError(t, err)
+	require.Equal(t, []BalanceChange{
+		{Account: "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", Asset: usd, Delta: "2.5000000"},
+		{Account: "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", Asset: xlm, Delta: "-10.0000000"},
+	}, changes)
+
+	all, err = OperationEffects("12345")
+	require.NoError(t, err)
+	require.Len(t, all, len(testEffects))
+
+	it := NewAccount(testHistoryAccount).EffectIterator(HistoryOptions{MaxRecords: 2})
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.Effect().PagingToken)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []string{"6", "5"}, ids)
+	require.Equal(t, "5", it.Cursor())
+
+	_, err = TxEffects("not a hash")
+	require.Error(t, err)
+}
+
+func TestEffectsPageDecode(t *testing.T) {
+	// unknown types and records that don't decode into their horizon
+	// type don't fail the rest of the page
+	var page EffectsPage
+	require.NoError(t, json.Unmarshal([]byte(`{"_embedded": {"records": [
+		{"id": "1", `+testEffects[5]+`},
+		{"id": "2", "type": "trade", "type_i": 33, "offer_id": "not a number"},
+		{"id": "3", `+testEffects[0]+`}]}}`), &page))
+	records := page.Embedded.Records
+	require.Len(t, records, 3)
+	require.Equal(t, "something_new", records[0].Details.GetType())
+	require.Equal(t, "2", records[1].ID)
+	require.Equal(t, "trade", records[1].Type)
+	require.Nil(t, records[1].Details)
+	_, ok := records[2].Details.(effects.AccountDebited)
+	require.True(t, ok)
+}
@@ -49,6 +49,10 @@ var ErrNoOps = errors.New("no operations in tx")
 // ErrAssetAlreadyExists means an asset cannot be created because it already exists
 var ErrAssetAlreadyExists = errors.New("asset already exists")
 
+// ErrInsufficientLiquidity is returned if an order book doesn't have
+// enough offers (or a liquidity pool enough reserves) to fill an amount.
+var ErrInsufficientLiquidity = errors.New("not enough liquidity in the order book")
+
 // Error provides a hopefully user-friendly default in Error()
 // but with some details that might actually help debug in Verbose().
 type Error struct {
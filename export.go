@@ -0,0 +1,288 @@
+package stellarnet
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/stellar/go/protocols/horizon/base"
+	"github.com/stellar/go/protocols/horizon/operations"
+)
+
+// ExportFormat is the output format of ExportHistory.
+type ExportFormat int
+
+const (
+	// ExportCSV writes a CSV line per row, after a header line when
+	// the export isn't resumed from a cursor.
+	ExportCSV ExportFormat = iota
+	// ExportJSONLines writes a JSON object per line.
+	ExportJSONLines
+)
+
+// ExportOptions controls ExportHistory.
+type ExportOptions struct {
+	Format ExportFormat
+	// Start and End limit the export to operations created in
+	// [Start, End).  Zero values mean no limit.
+	Start time.Time
+	End   time.Time
+	// Cursor is the cursor returned by a previous export, to resume
+	// after the rows it wrote.
+	Cursor string
+	// PageSize is the number of operations requested per page.
+	PageSize int
+}
+
+// ExportRow is a normalized operation in an account's history.  A path
+// payment from the account to itself is two rows with the same
+// OperationID: the asset it sent, then the asset it received.
+//
+// Amount is signed: negative when the operation took the asset out of
+// the account.  Fee is the fee the account paid for the transaction and
+// is only on the first row of each transaction.
+type ExportRow struct {
+	Time         time.Time `json:"time"`
+	TxHash       string    `json:"tx_hash"`
+	OperationID  string    `json:"operation_id"`
+	Type         string    `json:"type"`
+	Successful   bool      `json:"successful"`
+	Counterparty string    `json:"counterparty,omitempty"`
+	Asset        string    `json:"asset,omitempty"`
+	Amount       string    `json:"amount,omitempty"`
+	Fee          string    `json:"fee,omitempty"`
+	Memo         string    `json:"memo,omitempty"`
+}
+
+var exportCSVHeader = []string{"time", "tx_hash", "operation_id", "type", "successful", "counterparty", "asset", "amount", "fee", "memo"}
+
+func (r ExportRow) csvRecord() []string {
+	return []string{
+		r.Time.UTC().Format(time.RFC3339),
+		r.TxHash,
+		r.OperationID,
+		r.Type,
+		fmt.Sprintf("%t", r.Successful),
+		r.Counterparty,
+		r.Asset,
+		r.Amount,
+		r.Fee,
+		r.Memo,
+	}
+}
+
+// ExportHistory writes the account's history, oldest first, as rows of
+// operations in opts.Format.  It includes the operations of failed
+// transactions, since their fees were charged.  It returns the cursor
+// after the last row written, which resumes the export when passed back
+// in opts.Cursor, also after an error.
+//
+// Rows are written a transaction at a time, so a resumed export never
+// splits a transaction.  Fees of transactions where the account only
+// paid the fee (fee bumps of other accounts' transactions) aren't
+// included.  Without a cursor, the export finds opts.Start by paging
+// back from the latest operation, so the cost of finding it depends on
+// the amount of history after opts.Start.
+func (a *Account) ExportHistory(w io.Writer, opts ExportOptions) (cursor string, err error) {
+	var write func(ExportRow) error
+	var flush func() error
+	switch opts.Format {
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		if opts.Cursor == "" {
+			if err := cw.Write(exportCSVHeader); err != nil {
+				return "", err
+			}
+		}
+		write = func(r ExportRow) error { return cw.Write(r.csvRecord()) }
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case ExportJSONLines:
+		enc := json.NewEncoder(w)
+		write = func(r ExportRow) error { return enc.Encode(r) }
+		flush = func() error { return nil }
+	default:
+		return "", ErrInvalidParameter{Key: "format"}
+	}
+
+	address := a.address.String()
+	cursor = opts.Cursor
+	var rows []ExportRow
+	var lastToken string
+	// writeTx writes the rows of the current transaction.
+	writeTx := func() error {
+		for _, r := range rows {
+			if err := write(r); err != nil {
+				return err
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			cursor = lastToken
+		}
+		rows = rows[:0]
+		return nil
+	}
+
+	start := opts.Cursor
+	if start == "" && !opts.Start.IsZero() {
+		if start, err = a.exportStartCursor(opts); err != nil {
+			return "", err
+		}
+	}
+
+	it := newOperationIterator("/accounts/"+address+"/operations?join=transactions&include_failed=true",
+		HistoryOptions{Cursor: start, Order: "asc", PageSize: opts.PageSize})
+	for it.Next() {
+		op := it.Operation()
+		if !opts.End.IsZero() && !op.CreatedAt.Before(opts.End) {
+			break
+		}
+		if op.CreatedAt.Before(opts.Start) {
+			continue
+		}
+		if op.Transaction == nil {
+			return cursor, fmt.Errorf("operation %s has no transaction", op.ID)
+		}
+		if len(rows) > 0 && rows[0].TxHash != op.TransactionHash {
+			if err := writeTx(); err != nil {
+				return cursor, err
+			}
+		}
+		opRows, err := exportRows(address, op, len(rows) == 0)
+		if err != nil {
+			return cursor, err
+		}
+		rows = append(rows, opRows...)
+		lastToken = op.PagingToken
+	}
+	if err := it.Err(); err != nil {
+		return cursor, err
+	}
+	if err := writeTx(); err != nil {
+		return cursor, err
+	}
+	return cursor, nil
+}
+
+// exportStartCursor returns the paging token of the latest operation
+// created before opts.Start, or "" if there is none, walking the history
+// in descending order.
+func (a *Account) exportStartCursor(opts ExportOptions) (string, error) {
+	it := newOperationIterator("/accounts/"+a.address.String()+"/operations?include_failed=true",
+		HistoryOptions{Order: "desc", PageSize: opts.PageSize})
+	for it.Next() {
+		if op := it.Operation(); op.CreatedAt.Before(opts.Start) {
+			return op.PagingToken, nil
+		}
+	}
+	return "", it.Err()
+}
+
+// exportRows normalizes op into ExportRows from the point of view of
+// address.  first is true for the first row of the transaction.
+func exportRows(address string, op Operation, first bool) ([]ExportRow, error) {
+	tx := op.Transaction
+	row := ExportRow{
+		Time:        op.CreatedAt,
+		TxHash:      op.TransactionHash,
+		OperationID: op.ID,
+		Type:        op.Type,
+		Successful:  tx.Successful,
+		Memo:        tx.Memo,
+	}
+	if first && tx.FeeAccount == address {
+		row.Fee = StringFromStellarAmount(tx.FeeCharged)
+	}
+
+	// amount sets the asset and the signed amount of the row.
+	amount := func(a AssetBase, amt string, out bool) {
+		row.Asset = AssetBaseSummary(a)
+		row.Amount = amt
+		if out {
+			row.Amount = "-" + amt
+		}
+	}
+	asset := func(a base.Asset) AssetBase {
+		return AssetMinimal{AssetType: a.Type, AssetCode: a.Code, AssetIssuer: a.Issuer}
+	}
+	// other returns the other side of an operation between from and to.
+	other := func(from, to string) (string, bool) {
+		if from == address {
+			return to, true
+		}
+		return from, false
+	}
+	// received is the credit row of a path payment to the account
+	// itself.
+	var received *ExportRow
+	pathPayment := func(from, to string, source AssetBase, sourceAmount string, dest AssetBase, destAmount string) {
+		var out bool
+		if row.Counterparty, out = other(from, to); !out {
+			amount(dest, destAmount, false)
+			return
+		}
+		if to == address {
+			credit := row
+			credit.Fee = ""
+			credit.Asset = AssetBaseSummary(dest)
+			credit.Amount = destAmount
+			received = &credit
+		}
+		amount(source, sourceAmount, true)
+	}
+
+	switch d := op.Details.(type) {
+	case operations.CreateAccount:
+		var out bool
+		row.Counterparty, out = other(d.Funder, d.Account)
+		amount(AssetMinimal{AssetType: "native"}, d.StartingBalance, out)
+	case operations.Payment:
+		var out bool
+		row.Counterparty, out = other(d.From, d.To)
+		amount(asset(d.Asset), d.Amount, out)
+	case operations.PathPayment:
+		pathPayment(d.From, d.To, asset(base.Asset{Type: d.SourceAssetType, Code: d.SourceAssetCode, Issuer: d.SourceAssetIssuer}), d.SourceAmount,
+			asset(d.Asset), d.Amount)
+	case operations.PathPaymentStrictSend:
+		pathPayment(d.From, d.To, asset(base.Asset{Type: d.SourceAssetType, Code: d.SourceAssetCode, Issuer: d.SourceAssetIssuer}), d.SourceAmount,
+			asset(d.Asset), d.Amount)
+	case operations.AccountMerge:
+		var out bool
+		row.Counterparty, out = other(d.Account, d.Into)
+		if tx.Successful {
+			amt, err := AccountMergeAmount(op.ID)
+			if err != nil {
+				return nil, err
+			}
+			amount(AssetMinimal{AssetType: "native"}, amt, out)
+		}
+	case operations.CreateClaimableBalance:
+		if op.SourceAccount == address {
+			a, err := canonicalAssetMinimal(d.Asset)
+			if err != nil {
+				return nil, err
+			}
+			amount(a, d.Amount, true)
+		}
+	case operations.Clawback:
+		var out bool
+		row.Counterparty, out = other(d.From, op.SourceAccount)
+		amount(asset(d.Asset), d.Amount, out)
+	}
+	if !tx.Successful {
+		// nothing moved
+		row.Amount = ""
+		received = nil
+	}
+	if received != nil {
+		return []ExportRow{row, *received}, nil
+	}
+	return []ExportRow{row}, nil
+}
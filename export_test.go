@@ -0,0 +1,222 @@
+package stellarnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testExportAccount = "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO"
+	testExportOther   = "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB"
+	testExportIssuer  = "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX"
+)
+
+// testExportOps are the account's operations: tx 1 creates the account,
+// tx 2 has a payment in and a path payment out, tx 3 failed, tx 4 merges
+// the account.  Each transaction is on a different day.
+var testExportOps = []struct {
+	tx     int
+	fields string
+}{
+	{1, `"type": "create_account", "type_i": 0, "source_account": "` + testExportOther + `", "funder": "` + testExportOther + `", "account": "` + testExportAccount + `", "starting_balance": "100.0000000"`},
+	{2, `"type": "payment", "type_i": 1, "source_account": "` + testExportOther + `", "from": "` + testExportOther + `", "to": "` + testExportAccount + `", "asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": "` + testExportIssuer + `", "amount": "5.0000000"`},
+	{2, `"type": "path_payment_strict_receive", "type_i": 2, "source_account": "` + testExportAccount + `", "from": "` + testExportAccount + `", "to": "` + testExportOther + `", "asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": "` + testExportIssuer + `", "amount": "1.0000000", "source_amount": "4.0000000", "source_max": "5.0000000", "source_asset_type": "native"`},
+	{3, `"type": "payment", "type_i": 1, "source_account": "` + testExportAccount + `", "from": "` + testExportAccount + `", "to": "` + testExportOther + `", "asset_type": "native", "amount": "1000.0000000"`},
+	{4, `"type": "account_merge", "type_i": 8, "source_account": "` + testExportAccount + `", "account": "` + testExportAccount + `", "into": "` + testExportOther + `"`},
+}
+
+var testExportStart = time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+
+type exportHorizon struct {
+	url string
+	// failAfter makes requests for pages after this cursor fail
+	failAfter int
+	requests  []string
+}
+
+func (h *exportHorizon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if r.URL.Path == "/operations/5/effects" {
+		fmt.Fprint(w, `{"_embedded": {"records": [
+			{"id": "5-1", "type": "account_debited", "account": "`+testExportAccount+`", "asset_type": "native", "amount": "95.9999700"},
+			{"id": "5-2", "type": "account_credited", "account": "`+testExportOther+`", "asset_type": "native", "amount": "95.9999700"}]}}`)
+		return
+	}
+	h.requests = append(h.requests, r.URL.RawQuery)
+	desc := q.Get("order") == "desc"
+	if r.URL.Path != "/accounts/"+testExportAccount+"/operations" || q.Get("include_failed") != "true" || (q.Get("join") != "transactions" && !desc) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status": 404, "title": "Resource Missing"}`))
+		return
+	}
+	cursor, _ := strconv.Atoi(q.Get("cursor"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if h.failAfter > 0 && cursor >= h.failAfter {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"status": 500, "title": "Internal Server Error"}`))
+		return
+	}
+	var records []string
+	last := cursor
+	for i := range testExportOps {
+		id := i + 1
+		if desc {
+			id = len(testExportOps) - i
+			if cursor > 0 && id >= cursor || len(records) == limit {
+				continue
+			}
+		} else if id <= cursor || len(records) == limit {
+			continue
+		}
+		op := testExportOps[id-1]
+		created := testExportStart.AddDate(0, 0, op.tx).Format(time.RFC3339)
+		memo := ""
+		if op.tx == 2 {
+			memo = `"memo_type": "text", "memo": "invoice 7",`
+		}
+		records = append(records, fmt.Sprintf(`{"id": "%d", "paging_token": "%d", "created_at": %q, "transaction_hash": %q, %s,
+			"transaction": {"hash": %q, "successful": %t, "fee_account": %q, "fee_charged": "%d", %s "created_at": %q}}`,
+			id, id, created, testTxHash(op.tx), op.fields, testTxHash(op.tx), op.tx != 3, testExportAccount, 100*op.tx, memo, created))
+		last = id
+	}
+	q.Set("cursor", strconv.Itoa(last))
+	next := h.url + r.URL.Path + "?" + q.Encode()
+	fmt.Fprintf(w, `{"_links": {"next": {"href": %q}}, "_embedded": {"records": [%s]}}`, next, strings.Join(records, ","))
+}
+
+func withExportHorizon(t *testing.T, h *exportHorizon) func() {
+	url, restore := withFakeHorizon(t, h)
+	h.url = url
+	return restore
+}
+
+func TestExportHistoryCSV(t *testing.T) {
+	done := withExportHorizon(t, &exportHorizon{})
+	defer done()
+
+	var buf bytes.Buffer
+	cursor, err := NewAccount(testExportAccount).ExportHistory(&buf, ExportOptions{Format: ExportCSV, PageSize: 2})
+	require.NoError(t, err)
+	require.Equal(t, "5", cursor)
+	require.Equal(t, `time,tx_hash,operation_id,type,successful,counterparty,asset,amount,fee,memo
+2020-03-02T12:00:00Z,`+testTxHash(1)+`,1,create_account,true,`+testExportOther+`,XLM,100.0000000,0.0000100,
+2020-03-03T12:00:00Z,`+testTxHash(2)+`,2,payment,true,`+testExportOther+`,USD/`+testExportIssuer+`,5.0000000,0.0000200,invoice 7
+2020-03-03T12:00:00Z,`+testTxHash(2)+`,3,path_payment_strict_receive,true,`+testExportOther+`,XLM,-4.0000000,,invoice 7
+2020-03-04T12:00:00Z,`+testTxHash(3)+`,4,payment,false,`+testExportOther+`,XLM,,0.0000300,
+2020-03-05T12:00:00Z,`+testTxHash(4)+`,5,account_merge,true,`+testExportOther+`,XLM,-95.9999700,0.0000400,
+`, buf.String())
+}
+
+func TestExportHistoryJSONLines(t *testing.T) {
+	h := &exportHorizon{}
+	done := withExportHorizon(t, h)
+	defer done()
+
+	// date range
+	var buf bytes.Buffer
+	acct := NewAccount(testExportAccount)
+	cursor, err := acct.ExportHistory(&buf, ExportOptions{
+		Format: ExportJSONLines,
+		Start:  testExportStart.AddDate(0, 0, 2),
+		End:    testExportStart.AddDate(0, 0, 4),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "4", cursor)
+	// the start is found walking back from the latest operation, and
+	// the export resumes after the last operation before it
+	require.Len(t, h.requests, 2)
+	require.Contains(t, h.requests[0], "order=desc")
+	require.Contains(t, h.requests[1], "cursor=1")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	var row ExportRow
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &row))
+	require.Equal(t, ExportRow{
+		Time:         testExportStart.AddDate(0, 0, 2),
+		TxHash:       testTxHash(2),
+		OperationID:  "3",
+		Type:         "path_payment_strict_receive",
+		Successful:   true,
+		Counterparty: testExportOther,
+		Asset:        "XLM",
+		Amount:       "-4.0000000",
+		Memo:         "invoice 7",
+	}, row)
+
+	// an error part way through a transaction only writes whole
+	// transactions, and the cursor resumes after them
+	h.failAfter = 2
+	buf.Reset()
+	cursor, err = acct.ExportHistory(&buf, ExportOptions{Format: ExportJSONLines, PageSize: 2})
+	require.Error(t, err)
+	require.Equal(t, "1", cursor)
+	require.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), 1)
+
+	h.failAfter = 0
+	buf.Reset()
+	cursor, err = acct.ExportHistory(&buf, ExportOptions{Format: ExportJSONLines, Cursor: cursor})
+	require.NoError(t, err)
+	require.Equal(t, "5", cursor)
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &row))
+	require.Equal(t, "2", row.OperationID)
+	require.Equal(t, "0.0000200", row.Fee)
+
+	_, err = acct.ExportHistory(&buf, ExportOptions{Format: 7})
+	require.Equal(t, ErrInvalidParameter{Key: "format"}, err)
+}
+
+func TestExportRowLargeFee(t *testing.T) {
+	// fee bumps can charge more than fits in an int32
+	op := Operation{
+		ID:          "1",
+		Type:        "bump_sequence",
+		Transaction: &TransactionEmbed{},
+	}
+	op.Transaction.Successful = true
+	op.Transaction.FeeAccount = testExportAccount
+	op.Transaction.FeeCharged = 30000000000
+	rows, err := exportRows(testExportAccount, op, true)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, "3000.0000000", rows[0].Fee)
+}
+
+func TestExportRowsSelfPathPayment(t *testing.T) {
+	// converting XLM to USD in the account is both a debit and a credit
+	var op Operation
+	require.NoError(t, json.Unmarshal([]byte(`{"id": "9", "transaction_hash": "`+testTxHash(9)+`",
+		"type": "path_payment_strict_send", "type_i": 13, "source_account": "`+testExportAccount+`",
+		"from": "`+testExportAccount+`", "to": "`+testExportAccount+`",
+		"asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": "`+testExportIssuer+`", "amount": "2.5000000",
+		"source_amount": "10.0000000", "destination_min": "2.0000000", "source_asset_type": "native",
+		"transaction": {"successful": true, "fee_account": "`+testExportAccount+`", "fee_charged": "100"}}`), &op))
+	rows, err := exportRows(testExportAccount, op, true)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.Equal(t, "9", rows[0].OperationID)
+	require.Equal(t, "XLM", rows[0].Asset)
+	require.Equal(t, "-10.0000000", rows[0].Amount)
+	require.Equal(t, "0.0000100", rows[0].Fee)
+	require.Equal(t, "9", rows[1].OperationID)
+	require.Equal(t, testExportAccount, rows[1].Counterparty)
+	require.Equal(t, "USD/"+testExportIssuer, rows[1].Asset)
+	require.Equal(t, "2.5000000", rows[1].Amount)
+	require.Equal(t, "", rows[1].Fee)
+
+	// nothing moved in a failed transaction
+	op.Transaction.Successful = false
+	rows, err = exportRows(testExportAccount, op, true)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, "", rows[0].Amount)
+}
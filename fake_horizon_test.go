@@ -0,0 +1,23 @@
+package stellarnet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stellar/go/clients/horizonclient"
+)
+
+// withFakeHorizon points the package horizon client at a test server
+// running handler.  It returns the server's URL and a function that
+// restores the previous client and shuts the server down.
+func withFakeHorizon(t *testing.T, handler http.Handler) (string, func()) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	prevClient, prevNetwork := HorizonClient(), Network()
+	SetClientAndNetwork(&horizonclient.Client{HorizonURL: server.URL, HTTP: http.DefaultClient}, prevNetwork)
+	return server.URL, func() {
+		SetClientAndNetwork(prevClient, prevNetwork)
+		server.Close()
+	}
+}
@@ -3,6 +3,8 @@ package stellarnet
 import (
 	"errors"
 	"strconv"
+	"sync"
+	"time"
 )
 
 // FeeStats returns NumericFeeStats given a FeeStatFetcher.
@@ -111,6 +113,28 @@ func (f FeeStatsResponse) Convert() (x NumericFeeStats, err error) {
 		return x, err
 	}
 
+	maxFees := []struct {
+		dest *uint64
+		src  string
+	}{
+		{&s.MinMaxFee, f.MaxFee.Min}, {&s.MaxMaxFee, f.MaxFee.Max}, {&s.ModeMaxFee, f.MaxFee.Mode},
+		{&s.P10MaxFee, f.MaxFee.P10}, {&s.P20MaxFee, f.MaxFee.P20}, {&s.P30MaxFee, f.MaxFee.P30},
+		{&s.P40MaxFee, f.MaxFee.P40}, {&s.P50MaxFee, f.MaxFee.P50}, {&s.P60MaxFee, f.MaxFee.P60},
+		{&s.P70MaxFee, f.MaxFee.P70}, {&s.P80MaxFee, f.MaxFee.P80}, {&s.P90MaxFee, f.MaxFee.P90},
+		{&s.P95MaxFee, f.MaxFee.P95}, {&s.P99MaxFee, f.MaxFee.P99},
+	}
+	for _, m := range maxFees {
+		// older horizons and other FeeStatFetchers can leave out
+		// max_fee, so it stays zero.
+		if m.src == "" {
+			continue
+		}
+		*m.dest, err = strconv.ParseUint(m.src, 10, 64)
+		if err != nil {
+			return x, err
+		}
+	}
+
 	return s, nil
 }
 
@@ -137,6 +161,33 @@ type NumericFeeStats struct {
 	P90AcceptedFee      uint64
 	P95AcceptedFee      uint64
 	P99AcceptedFee      uint64
+
+	// The max fees are what transactions offered to pay, as opposed to
+	// the accepted fees they were charged.
+	MinMaxFee  uint64
+	MaxMaxFee  uint64
+	ModeMaxFee uint64
+	P10MaxFee  uint64
+	P20MaxFee  uint64
+	P30MaxFee  uint64
+	P40MaxFee  uint64
+	P50MaxFee  uint64
+	P60MaxFee  uint64
+	P70MaxFee  uint64
+	P80MaxFee  uint64
+	P90MaxFee  uint64
+	P95MaxFee  uint64
+	P99MaxFee  uint64
+}
+
+// congestedCapacityUsage is the ledger capacity usage at which the
+// network is considered congested.
+const congestedCapacityUsage = 0.9
+
+// Congested returns true if ledgers are nearly full or transactions
+// are being charged more than the base fee (surge pricing).
+func (s NumericFeeStats) Congested() bool {
+	return s.LedgerCapacityUsage >= congestedCapacityUsage || s.MinAcceptedFee > s.LastLedgerBaseFee
 }
 
 // HorizonFeeStatFetcher is a FeeStatFetcher that uses a live horizon
@@ -159,3 +210,106 @@ func (h *HorizonFeeStatFetcher) FeeStatFetch() (FeeStatsResponse, error) {
 
 	return resp, nil
 }
+
+// FeeStatsSnapshot is the fee stats at one point in time.
+type FeeStatsSnapshot struct {
+	Time  time.Time
+	Stats NumericFeeStats
+}
+
+// CachingFeeStatFetcher is a FeeStatFetcher that caches the stats of
+// another fetcher and keeps a window of recent snapshots.
+type CachingFeeStatFetcher struct {
+	mu      sync.Mutex
+	fetcher FeeStatFetcher
+	ttl     time.Duration
+	window  int
+	now     func() time.Time
+
+	last    FeeStatsResponse
+	expires time.Time
+	history []FeeStatsSnapshot
+}
+
+// NewCachingFeeStatFetcher makes a CachingFeeStatFetcher that fetches
+// from f (HorizonFeeStatFetcher if nil) at most once per ttl and keeps
+// the snapshots of the last window ledgers it saw.
+func NewCachingFeeStatFetcher(f FeeStatFetcher, ttl time.Duration, window int) *CachingFeeStatFetcher {
+	if f == nil {
+		f = &HorizonFeeStatFetcher{}
+	}
+	if window < 1 {
+		window = 1
+	}
+	return &CachingFeeStatFetcher{fetcher: f, ttl: ttl, window: window, now: time.Now}
+}
+
+// FeeStatFetch implements FeeStatFetcher.
+func (c *CachingFeeStatFetcher) FeeStatFetch() (FeeStatsResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if now.Before(c.expires) {
+		return c.last, nil
+	}
+	resp, err := c.fetcher.FeeStatFetch()
+	if err != nil {
+		return FeeStatsResponse{}, err
+	}
+	stats, err := resp.Convert()
+	if err != nil {
+		return FeeStatsResponse{}, err
+	}
+	c.last = resp
+	c.expires = now.Add(c.ttl)
+	if n := len(c.history); n == 0 || c.history[n-1].Stats.LastLedger != stats.LastLedger {
+		c.history = append(c.history, FeeStatsSnapshot{Time: now, Stats: stats})
+		if len(c.history) > c.window {
+			c.history = c.history[len(c.history)-c.window:]
+		}
+	}
+	return resp, nil
+}
+
+// History returns the snapshots in the window, oldest first.
+func (c *CachingFeeStatFetcher) History() []FeeStatsSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]FeeStatsSnapshot(nil), c.history...)
+}
+
+// FeeTrend summarizes how fees changed over a window of snapshots.
+type FeeTrend struct {
+	Snapshots            int
+	Oldest               NumericFeeStats
+	Newest               NumericFeeStats
+	AverageCapacityUsage float64
+	// Congested is true if the newest snapshot is congested.
+	Congested bool
+	// Surging is true if the median accepted or offered fee at least
+	// doubled over the window, or the network became congested.
+	Surging bool
+}
+
+// Trend returns the FeeTrend of the snapshots in the window.
+func (c *CachingFeeStatFetcher) Trend() FeeTrend {
+	history := c.History()
+	if len(history) == 0 {
+		return FeeTrend{}
+	}
+	oldest, newest := history[0].Stats, history[len(history)-1].Stats
+	trend := FeeTrend{
+		Snapshots: len(history),
+		Oldest:    oldest,
+		Newest:    newest,
+		Congested: newest.Congested(),
+	}
+	for _, h := range history {
+		trend.AverageCapacityUsage += h.Stats.LedgerCapacityUsage
+	}
+	trend.AverageCapacityUsage /= float64(len(history))
+	trend.Surging = (trend.Congested && !oldest.Congested()) ||
+		(oldest.P50AcceptedFee > 0 && newest.P50AcceptedFee >= 2*oldest.P50AcceptedFee) ||
+		(oldest.P50MaxFee > 0 && newest.P50MaxFee >= 2*oldest.P50MaxFee)
+	return trend
+}
@@ -0,0 +1,333 @@
+package stellarnet
+
+import (
+	"encoding/hex"
+	"net/url"
+	"sync"
+
+	perrors "github.com/pkg/errors"
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+// FeeStrategy picks the base fee (per operation, in stroops) of a
+// transaction.
+type FeeStrategy interface {
+	BaseFee() (uint64, error)
+}
+
+var feeStrategyLock sync.Mutex
+var feeStrategy FeeStrategy
+
+// SetFeeStrategy sets the FeeStrategy that NewBaseTx, and so all the
+// transaction builders, consult when their baseFee is zero.  With no
+// strategy (the default), they pay txnbuild.MinBaseFee.
+func SetFeeStrategy(s FeeStrategy) {
+	feeStrategyLock.Lock()
+	defer feeStrategyLock.Unlock()
+	feeStrategy = s
+}
+
+// strategyBaseFee returns the base fee from the FeeStrategy, or
+// txnbuild.MinBaseFee if there is no strategy or it fails.
+func strategyBaseFee() uint64 {
+	feeStrategyLock.Lock()
+	s := feeStrategy
+	feeStrategyLock.Unlock()
+	if s == nil {
+		return txnbuild.MinBaseFee
+	}
+	fee, err := s.BaseFee()
+	if err != nil {
+		return txnbuild.MinBaseFee
+	}
+	return fee
+}
+
+// FixedFee is a FeeStrategy that always pays the same base fee.
+type FixedFee uint64
+
+// BaseFee implements FeeStrategy.
+func (f FixedFee) BaseFee() (uint64, error) {
+	return uint64(f), nil
+}
+
+// PercentileFee is a FeeStrategy that pays a percentile of the fees
+// accepted in recent ledgers.
+type PercentileFee struct {
+	// Percentile is rounded up to one of the percentiles horizon
+	// reports: 10, 20, ..., 90, 95 or 99.
+	Percentile int
+	// Fetcher defaults to HorizonFeeStatFetcher.
+	Fetcher FeeStatFetcher
+}
+
+// BaseFee implements FeeStrategy.
+func (p PercentileFee) BaseFee() (uint64, error) {
+	stats, err := FeeStats(defaultFeeStatFetcher(p.Fetcher))
+	if err != nil {
+		return 0, err
+	}
+	return stats.AcceptedFeePercentile(p.Percentile), nil
+}
+
+// CapacityFee is a FeeStrategy that pays more as ledgers fill up.
+// Below Low capacity usage it pays the last ledger's base fee, above
+// High it pays the 99th percentile of accepted fees, and in between a
+// percentile that rises with the usage.
+type CapacityFee struct {
+	// Low defaults to 0.5.
+	Low float64
+	// High defaults to 0.95.
+	High float64
+	// Fetcher defaults to HorizonFeeStatFetcher.
+	Fetcher FeeStatFetcher
+}
+
+// BaseFee implements FeeStrategy.
+func (c CapacityFee) BaseFee() (uint64, error) {
+	stats, err := FeeStats(defaultFeeStatFetcher(c.Fetcher))
+	if err != nil {
+		return 0, err
+	}
+	low, high := c.Low, c.High
+	if low <= 0 {
+		low = 0.5
+	}
+	if high <= low {
+		high = 0.95
+	}
+	switch usage := stats.LedgerCapacityUsage; {
+	case usage <= low:
+		return stats.LastLedgerBaseFee, nil
+	case usage >= high:
+		return stats.P99AcceptedFee, nil
+	default:
+		return stats.AcceptedFeePercentile(10 + int((usage-low)/(high-low)*89)), nil
+	}
+}
+
+// CappedFee is a FeeStrategy that limits another strategy's fee to Max.
+type CappedFee struct {
+	Strategy FeeStrategy
+	Max      uint64
+}
+
+// BaseFee implements FeeStrategy.
+func (c CappedFee) BaseFee() (uint64, error) {
+	fee, err := c.Strategy.BaseFee()
+	if err != nil {
+		return 0, err
+	}
+	if fee > c.Max {
+		fee = c.Max
+	}
+	return fee, nil
+}
+
+func defaultFeeStatFetcher(f FeeStatFetcher) FeeStatFetcher {
+	if f == nil {
+		return &HorizonFeeStatFetcher{}
+	}
+	return f
+}
+
+// AcceptedFeePercentile returns the accepted fee at percentile p,
+// rounded up to one of the percentiles horizon reports.
+func (s NumericFeeStats) AcceptedFeePercentile(p int) uint64 {
+	switch {
+	case p <= 10:
+		return s.P10AcceptedFee
+	case p <= 20:
+		return s.P20AcceptedFee
+	case p <= 30:
+		return s.P30AcceptedFee
+	case p <= 40:
+		return s.P40AcceptedFee
+	case p <= 50:
+		return s.P50AcceptedFee
+	case p <= 60:
+		return s.P60AcceptedFee
+	case p <= 70:
+		return s.P70AcceptedFee
+	case p <= 80:
+		return s.P80AcceptedFee
+	case p <= 90:
+		return s.P90AcceptedFee
+	case p <= 95:
+		return s.P95AcceptedFee
+	default:
+		return s.P99AcceptedFee
+	}
+}
+
+// FeeBumpTransaction wraps the signed transaction inner in a fee bump
+// transaction that feeSource pays baseFee per operation for (the fee
+// bump counts as an operation).  If baseFee is zero, the FeeStrategy is
+// consulted.
+func FeeBumpTransaction(feeSource SeedStr, inner string, baseFee uint64) (SignResult, error) {
+	var innerEnv xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(inner, &innerEnv); err != nil {
+		return SignResult{}, err
+	}
+	if innerEnv.Type != xdr.EnvelopeTypeEnvelopeTypeTx || innerEnv.V1 == nil {
+		return SignResult{}, ErrInvalidParameter{Key: "inner"}
+	}
+	if baseFee == 0 {
+		baseFee = strategyBaseFee()
+	}
+	if baseFee < txnbuild.MinBaseFee {
+		baseFee = txnbuild.MinBaseFee
+	}
+	kp, err := keypair.Parse(feeSource.SecureNoLogString())
+	if err != nil {
+		return SignResult{}, err
+	}
+	var source xdr.MuxedAccount
+	if err := source.SetAddress(kp.Address()); err != nil {
+		return SignResult{}, err
+	}
+
+	tx := xdr.FeeBumpTransaction{
+		FeeSource: source,
+		Fee:       xdr.Int64(baseFee * uint64(len(innerEnv.V1.Tx.Operations)+1)),
+		InnerTx: xdr.FeeBumpTransactionInnerTx{
+			Type: xdr.EnvelopeTypeEnvelopeTypeTx,
+			V1:   innerEnv.V1,
+		},
+	}
+	hash, err := network.HashFeeBumpTransaction(tx, NetworkPassphrase())
+	if err != nil {
+		return SignResult{}, err
+	}
+	sig, err := kp.SignDecorated(hash[:])
+	if err != nil {
+		return SignResult{}, err
+	}
+	env := xdr.TransactionEnvelope{
+		Type: xdr.EnvelopeTypeEnvelopeTypeTxFeeBump,
+		FeeBump: &xdr.FeeBumpTransactionEnvelope{
+			Tx:         tx,
+			Signatures: []xdr.DecoratedSignature{sig},
+		},
+	}
+	signed, err := xdr.MarshalBase64(env)
+	if err != nil {
+		return SignResult{}, err
+	}
+	return SignResult{
+		Seqno:  uint64(innerEnv.V1.Tx.SeqNum),
+		Signed: signed,
+		TxHash: hex.EncodeToString(hash[:]),
+	}, nil
+}
+
+// FeeEscalation configures SubmitWithFeeBumps.
+type FeeEscalation struct {
+	// FeeSource pays for the fee bumps.
+	FeeSource SeedStr
+	// StartFee is the base fee of the first fee bump.  It defaults to
+	// Factor times the inner transaction's base fee.
+	StartFee uint64
+	// Factor multiplies the base fee of each fee bump.  It defaults
+	// to 10, the minimum for stellar-core to replace a transaction
+	// that is already queued.
+	Factor uint64
+	// MaxFee is the highest base fee to pay.
+	MaxFee uint64
+}
+
+// SubmitWithFeeBumps submits signed and, as long as it is stuck
+// (horizon times out waiting for it to get into a ledger, or the fee is
+// too low), resubmits it in fee bump transactions with increasing fees
+// up to esc.MaxFee.  If the transaction makes it into a ledger while a
+// fee bump is being submitted, its result is returned.
+func SubmitWithFeeBumps(signed string, esc FeeEscalation) (SubmitResult, error) {
+	if esc.FeeSource == "" {
+		return SubmitResult{}, ErrMissingParameter{Key: "fee_source"}
+	}
+	if esc.MaxFee == 0 {
+		return SubmitResult{}, ErrMissingParameter{Key: "max_fee"}
+	}
+	factor := esc.Factor
+	if factor < 2 {
+		factor = 10
+	}
+	var env xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(signed, &env); err != nil {
+		return SubmitResult{}, err
+	}
+	innerHash, err := network.HashTransactionInEnvelope(env, NetworkPassphrase())
+	if err != nil {
+		return SubmitResult{}, err
+	}
+	fee := esc.StartFee
+	if fee == 0 && len(env.Operations()) > 0 {
+		fee = uint64(env.Fee()) / uint64(len(env.Operations())) * factor
+	}
+
+	res, err := Submit(signed)
+	for attempt := 1; err != nil && submitStuck(err); attempt++ {
+		if fee > esc.MaxFee {
+			fee = esc.MaxFee
+		}
+		bump, berr := FeeBumpTransaction(esc.FeeSource, signed, fee)
+		if berr != nil {
+			return SubmitResult{}, berr
+		}
+		res, err = submitOnce(bump.Signed)
+		res.Attempt = attempt
+		if err == nil {
+			return res, nil
+		}
+		if tx, terr := TxDetails(hex.EncodeToString(innerHash[:])); terr == nil {
+			return SubmitResult{Ledger: tx.Ledger, TxID: tx.Hash, Attempt: attempt, ResultXDR: tx.ResultXdr}, nil
+		}
+		if fee == esc.MaxFee {
+			break
+		}
+		fee *= factor
+	}
+	return res, err
+}
+
+// submitOnce submits signed without Submit's retries.
+func submitOnce(signed string) (SubmitResult, error) {
+	resp, err := Client().SubmitTransactionXDR(signed)
+	if err != nil {
+		return SubmitResult{}, errMap(err)
+	}
+	return SubmitResult{Ledger: resp.Ledger, TxID: resp.Hash, ResultXDR: resp.ResultXdr}, nil
+}
+
+// submitStuck returns true if err means a submitted transaction didn't
+// get into a ledger because of its fee or horizon timed out waiting
+// for it.
+func submitStuck(err error) bool {
+	var herr *horizonclient.Error
+	switch xerr := perrors.Cause(err).(type) {
+	case Error:
+		if uerr, ok := perrors.Cause(xerr.OriginalError).(*url.Error); ok && uerr.Timeout() {
+			return true
+		}
+		herr = xerr.HorizonError
+	case *horizonclient.Error:
+		herr = xerr
+	case *url.Error:
+		return xerr.Timeout()
+	}
+	if herr == nil {
+		return false
+	}
+	if herr.Problem.Status == 504 {
+		return true
+	}
+	codes, err := herr.ResultCodes()
+	if err != nil {
+		return false
+	}
+	return codes.TransactionCode == "tx_insufficient_fee"
+}
@@ -0,0 +1,144 @@
+package stellarnet
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+type testFeeStatFetcher struct {
+	usage string
+}
+
+func (f testFeeStatFetcher) FeeStatFetch() (FeeStatsResponse, error) {
+	return FeeStatsResponse{
+		LastLedger:          "7",
+		LastLedgerBaseFee:   "100",
+		LedgerCapacityUsage: f.usage,
+		FeeCharged: FeeStatsSubResponse{Min: "100", Max: "5000", Mode: "100",
+			P10: "100", P20: "110", P30: "120", P40: "130", P50: "140", P60: "150",
+			P70: "160", P80: "170", P90: "180", P95: "190", P99: "500"},
+	}, nil
+}
+
+func TestFeeStrategies(t *testing.T) {
+	fee, err := FixedFee(250).BaseFee()
+	require.NoError(t, err)
+	require.Equal(t, uint64(250), fee)
+
+	fetcher := testFeeStatFetcher{usage: "0.3"}
+	for percentile, expected := range map[int]uint64{0: 100, 10: 100, 45: 140, 90: 180, 95: 190, 96: 500} {
+		fee, err = PercentileFee{Percentile: percentile, Fetcher: fetcher}.BaseFee()
+		require.NoError(t, err)
+		require.Equal(t, expected, fee, "percentile %d", percentile)
+	}
+
+	for usage, expected := range map[string]uint64{"0.3": 100, "0.5": 100, "0.7": 140, "0.9": 180, "0.97": 500} {
+		fee, err = CapacityFee{Fetcher: testFeeStatFetcher{usage: usage}}.BaseFee()
+		require.NoError(t, err)
+		require.Equal(t, expected, fee, "usage %s", usage)
+	}
+
+	fee, err = CappedFee{Strategy: CapacityFee{Fetcher: testFeeStatFetcher{usage: "0.99"}}, Max: 300}.BaseFee()
+	require.NoError(t, err)
+	require.Equal(t, uint64(300), fee)
+}
+
+// feeBumpHorizon is a fake horizon that answers transaction submissions
+// from responses in order.
+type feeBumpHorizon struct {
+	sync.Mutex
+	responses []string
+	envelopes []xdr.TransactionEnvelope
+}
+
+func (h *feeBumpHorizon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Lock()
+	defer h.Unlock()
+	switch {
+	case r.URL.Path == "/ledgers":
+		fmt.Fprint(w, `{"_embedded": {"records": [{"sequence": 7, "base_fee_in_stroops": 100, "base_reserve_in_stroops": 5000000}]}}`)
+	case r.Method == "POST" && r.URL.Path == "/transactions":
+		var env xdr.TransactionEnvelope
+		if err := xdr.SafeUnmarshalBase64(r.FormValue("tx"), &env); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		h.envelopes = append(h.envelopes, env)
+		response := h.responses[0]
+		h.responses = h.responses[1:]
+		switch response {
+		case "timeout":
+			w.WriteHeader(http.StatusGatewayTimeout)
+			fmt.Fprint(w, `{"type": "https://stellar.org/horizon-errors/timeout", "title": "Timeout", "status": 504}`)
+		case "insufficient_fee":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"type": "https://stellar.org/horizon-errors/transaction_failed", "title": "Transaction Failed", "status": 400,
+				"extras": {"result_codes": {"transaction": "tx_insufficient_fee"}}}`)
+		default:
+			fmt.Fprintf(w, `{"hash": %q, "ledger": 8}`, response)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"status": 404, "title": "Resource Missing"}`)
+	}
+}
+
+func TestFeeBumpEscalation(t *testing.T) {
+	fake := &feeBumpHorizon{responses: []string{"timeout", "insufficient_fee", "bumped"}}
+	_, restore := withFakeHorizon(t, fake)
+	defer restore()
+
+	source := keypair.MustRandom()
+	payer := keypair.MustRandom()
+
+	// the fee strategy picks the fee when baseFee is zero
+	SetFeeStrategy(FixedFee(300))
+	tx := NewBaseTx(addressStr(t, source), &testSeqnoProv{seqno: 100}, 0)
+	tx.AddPaymentOp(addressStr(t, payer), "1")
+	env, err := tx.Envelope()
+	require.NoError(t, err)
+	require.Equal(t, xdr.Uint32(300), env.V1.Tx.Fee)
+	SetFeeStrategy(nil)
+
+	tx = NewBaseTx(addressStr(t, source), &testSeqnoProv{seqno: 100}, 0)
+	tx.AddPaymentOp(addressStr(t, payer), "1")
+	inner, err := tx.Sign(seedStr(t, source))
+	require.NoError(t, err)
+
+	bump, err := FeeBumpTransaction(seedStr(t, payer), inner.Signed, 200)
+	require.NoError(t, err)
+	var bumpEnv xdr.TransactionEnvelope
+	require.NoError(t, xdr.SafeUnmarshalBase64(bump.Signed, &bumpEnv))
+	require.True(t, bumpEnv.IsFeeBump())
+	require.Equal(t, payer.Address(), bumpEnv.FeeBumpAccount().ToAccountId().Address())
+	require.Equal(t, int64(400), bumpEnv.FeeBumpFee())
+	require.Equal(t, inner.Seqno, bump.Seqno)
+	require.NotEqual(t, inner.TxHash, bump.TxHash)
+
+	_, err = SubmitWithFeeBumps(inner.Signed, FeeEscalation{FeeSource: seedStr(t, payer)})
+	require.Equal(t, ErrMissingParameter{Key: "max_fee"}, err)
+
+	res, err := SubmitWithFeeBumps(inner.Signed, FeeEscalation{FeeSource: seedStr(t, payer), MaxFee: 5000})
+	require.NoError(t, err)
+	require.Equal(t, "bumped", res.TxID)
+	require.Equal(t, 2, res.Attempt)
+	require.Len(t, fake.envelopes, 3)
+	require.False(t, fake.envelopes[0].IsFeeBump())
+	require.Equal(t, int64(2000), fake.envelopes[1].FeeBumpFee())
+	require.Equal(t, int64(10000), fake.envelopes[2].FeeBumpFee())
+
+	// it gives up at the maximum fee
+	fake.envelopes = nil
+	fake.responses = []string{"timeout", "insufficient_fee"}
+	_, err = SubmitWithFeeBumps(inner.Signed, FeeEscalation{FeeSource: seedStr(t, payer), MaxFee: 500})
+	require.Error(t, err)
+	require.True(t, submitStuck(err))
+	require.Len(t, fake.envelopes, 2)
+	require.Equal(t, int64(1000), fake.envelopes[1].FeeBumpFee())
+}
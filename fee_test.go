@@ -1,9 +1,12 @@
 package stellarnet
 
 import (
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/keybase/stellarnet/testclient"
+	"github.com/stretchr/testify/require"
 )
 
 // TestFeeStats makes sure that the horizon fetcher
@@ -31,4 +34,86 @@ func TestFeeStats(t *testing.T) {
 	if stats.P95AcceptedFee == 0 {
 		t.Error("p95 accepted fee: 0, expected non-zero")
 	}
+	if stats.P95MaxFee == 0 {
+		t.Error("p95 max fee: 0, expected non-zero")
+	}
+}
+
+func TestFeeStatsWithoutMaxFee(t *testing.T) {
+	// testFeeStatFetcher leaves out max_fee, like older horizons
+	stats, err := FeeStats(testFeeStatFetcher{usage: "0.5"})
+	require.NoError(t, err)
+	require.Equal(t, uint64(140), stats.P50AcceptedFee)
+	require.Equal(t, uint64(0), stats.P50MaxFee)
+	require.Equal(t, uint64(0), stats.MaxMaxFee)
+
+	// a malformed max_fee is still an error
+	resp, err := testFeeStatFetcher{usage: "0.5"}.FeeStatFetch()
+	require.NoError(t, err)
+	resp.MaxFee.P50 = "x"
+	_, err = resp.Convert()
+	require.Error(t, err)
+}
+
+// sequenceFeeStatFetcher returns a ledger's stats per call, with the
+// median accepted fee of each ledger from fees.
+type sequenceFeeStatFetcher struct {
+	fees  []string
+	calls int
+}
+
+func (f *sequenceFeeStatFetcher) FeeStatFetch() (FeeStatsResponse, error) {
+	resp, _ := testFeeStatFetcher{usage: "0.2"}.FeeStatFetch()
+	resp.LastLedger = strconv.Itoa(100 + f.calls)
+	resp.FeeCharged.P50 = f.fees[f.calls]
+	resp.MaxFee = FeeStatsSubResponse{Min: "100", Max: "9000", Mode: "100",
+		P10: "100", P20: "100", P30: "200", P40: "200", P50: "300", P60: "300",
+		P70: "400", P80: "400", P90: "1000", P95: "2000", P99: "9000"}
+	if f.calls == len(f.fees)-1 {
+		resp.LedgerCapacityUsage = "0.95"
+	}
+	f.calls++
+	return resp, nil
+}
+
+func TestCachingFeeStatFetcher(t *testing.T) {
+	source := &sequenceFeeStatFetcher{fees: []string{"100", "120", "150", "250"}}
+	cache := NewCachingFeeStatFetcher(source, 5*time.Second, 3)
+	now := time.Unix(1600000000, 0)
+	cache.now = func() time.Time { return now }
+
+	stats, err := FeeStats(cache)
+	require.NoError(t, err)
+	require.Equal(t, int32(100), stats.LastLedger)
+	require.Equal(t, uint64(300), stats.P50MaxFee)
+	require.Equal(t, uint64(9000), stats.MaxMaxFee)
+	require.False(t, stats.Congested())
+
+	// cached until the ttl passes
+	_, err = FeeStats(cache)
+	require.NoError(t, err)
+	require.Equal(t, 1, source.calls)
+	require.Len(t, cache.History(), 1)
+	require.False(t, cache.Trend().Surging)
+
+	for i := 0; i < 3; i++ {
+		now = now.Add(5 * time.Second)
+		_, err = FeeStats(cache)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 4, source.calls)
+
+	// the window keeps the last three ledgers
+	history := cache.History()
+	require.Len(t, history, 3)
+	require.Equal(t, int32(101), history[0].Stats.LastLedger)
+	require.Equal(t, now, history[2].Time)
+
+	trend := cache.Trend()
+	require.Equal(t, 3, trend.Snapshots)
+	require.Equal(t, uint64(120), trend.Oldest.P50AcceptedFee)
+	require.Equal(t, uint64(250), trend.Newest.P50AcceptedFee)
+	require.InDelta(t, 0.45, trend.AverageCapacityUsage, 0.0001)
+	require.True(t, trend.Congested)
+	require.True(t, trend.Surging)
 }
@@ -0,0 +1,430 @@
+package stellarnet
+
+import (
+	"errors"
+
+	"github.com/stellar/go/keypair"
+	horizonProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// The steps of an asset issuance, in the order they run.
+const (
+	IssuanceCreateIssuer      = "create_issuer"
+	IssuanceCreateDistributor = "create_distributor"
+	IssuanceIssuerOptions     = "issuer_options"
+	IssuanceTrustline         = "trustline"
+	IssuanceAuthorize         = "authorize"
+	IssuanceIssue             = "issue"
+	IssuanceOffer             = "offer"
+	IssuanceLockIssuer        = "lock_issuer"
+)
+
+// defaultIssuanceFunding is the starting balance of the issuer and
+// distributor accounts.
+const defaultIssuanceFunding = "5"
+
+// IssuanceConfig configures IssueAsset.
+type IssuanceConfig struct {
+	// Source creates the issuer and distributor accounts and pays the
+	// fees.
+	Source      SeedStr
+	Issuer      *keypair.Full
+	Distributor *keypair.Full
+	AssetCode   string
+	// Amount is issued to the distributor.  It is also the limit of
+	// the distributor's trustline.
+	Amount string
+	// HomeDomain is set on the issuer if not empty.
+	HomeDomain string
+	// XLMPrice is the price of an offer by the distributor to sell
+	// Amount for lumens.  Empty means no offer.
+	XLMPrice string
+	// Funding is the starting balance of the new accounts.  Default
+	// is 5 XLM.
+	Funding string
+	// Flags are set on the issuer before the distributor's trustline
+	// is created.  If they include AccountAuthRequired, the issuer
+	// authorizes the distributor's trustline.
+	Flags AccountFlags
+	// LockIssuer sets the issuer's master key weight to zero when
+	// everything else is done, so no more of the asset can be issued.
+	LockIssuer bool
+	MemoText   string
+	BaseFee    uint64
+}
+
+func (c IssuanceConfig) funding() string {
+	if c.Funding == "" {
+		return defaultIssuanceFunding
+	}
+	return c.Funding
+}
+
+// IssuanceStep is a step of an asset issuance.
+type IssuanceStep struct {
+	Name string
+	// Done is true if the step had already happened on the network
+	// when the plan was made.
+	Done bool
+	// TxID is the transaction that did the step, if it was done by
+	// IssueAsset.
+	TxID string
+}
+
+// IssuancePlan is the steps of an asset issuance and which of them are
+// already done.
+type IssuancePlan struct {
+	Config      IssuanceConfig
+	Asset       AssetMinimal
+	Issuer      AddressStr
+	Distributor AddressStr
+	Steps       []IssuanceStep
+}
+
+// Pending returns the steps that aren't done.
+func (p *IssuancePlan) Pending() []IssuanceStep {
+	var pending []IssuanceStep
+	for _, s := range p.Steps {
+		if !s.Done {
+			pending = append(pending, s)
+		}
+	}
+	return pending
+}
+
+// IssuanceReport is the result of IssueAsset.
+type IssuanceReport struct {
+	Asset       AssetMinimal
+	Issuer      AddressStr
+	Distributor AddressStr
+	Steps       []IssuanceStep
+	// TxIDs are the transactions submitted, in order.
+	TxIDs []string
+	// Complete is true if all the steps are done.
+	Complete bool
+}
+
+// PlanAssetIssuance checks which steps of the issuance described by cfg
+// have already happened on the network.
+func PlanAssetIssuance(cfg IssuanceConfig) (*IssuancePlan, error) {
+	if cfg.Source == "" {
+		return nil, ErrMissingParameter{Key: "source"}
+	}
+	if cfg.Issuer == nil {
+		return nil, ErrMissingParameter{Key: "issuer"}
+	}
+	if cfg.Distributor == nil {
+		return nil, ErrMissingParameter{Key: "distributor"}
+	}
+	if cfg.AssetCode == "" {
+		return nil, ErrMissingParameter{Key: "asset_code"}
+	}
+	if n, err := ParseStellarAmount(cfg.Amount); err != nil || n <= 0 {
+		return nil, ErrInvalidParameter{Key: "amount"}
+	}
+	if n, err := ParseStellarAmount(cfg.funding()); err != nil || n <= 0 {
+		return nil, ErrInvalidParameter{Key: "funding"}
+	}
+	issuer, err := NewAddressStr(cfg.Issuer.Address())
+	if err != nil {
+		return nil, err
+	}
+	distributor, err := NewAddressStr(cfg.Distributor.Address())
+	if err != nil {
+		return nil, err
+	}
+	asset, err := NewAssetMinimal(cfg.AssetCode, issuer.String())
+	if err != nil {
+		return nil, err
+	}
+
+	issuerAcct, err := loadIssuanceAccount(issuer)
+	if err != nil {
+		return nil, err
+	}
+	distAcct, err := loadIssuanceAccount(distributor)
+	if err != nil {
+		return nil, err
+	}
+	var line *horizonProtocol.Balance
+	if distAcct != nil {
+		for i, b := range distAcct.Balances {
+			if b.Code == asset.AssetCode && b.Issuer == asset.AssetIssuer {
+				line = &distAcct.Balances[i]
+			}
+		}
+	}
+	// the distributor's balance can't tell whether the asset was
+	// issued or offered, since it could have sold or sent it on.
+	var issued, offered bool
+	if issuerAcct != nil && line != nil {
+		if issued, err = issuancePaid(issuer, distributor, asset); err != nil {
+			return nil, err
+		}
+	}
+	if issued && cfg.XLMPrice != "" {
+		if offered, err = issuanceOffered(distributor, asset); err != nil {
+			return nil, err
+		}
+		if n, err := ParseStellarAmount(line.Balance); !offered && err == nil && n == 0 {
+			// the offer was taken, or there is nothing left to offer
+			offered = true
+		}
+	}
+
+	plan := &IssuancePlan{Config: cfg, Asset: asset, Issuer: issuer, Distributor: distributor}
+	add := func(name string, done bool) {
+		plan.Steps = append(plan.Steps, IssuanceStep{Name: name, Done: done})
+	}
+	add(IssuanceCreateIssuer, issuerAcct != nil)
+	add(IssuanceCreateDistributor, distAcct != nil)
+	if cfg.HomeDomain != "" || cfg.Flags != 0 {
+		done := issuerAcct != nil &&
+			(cfg.HomeDomain == "" || issuerAcct.HomeDomain == cfg.HomeDomain) &&
+			horizonAccountFlags(issuerAcct.Flags)&cfg.Flags == cfg.Flags
+		add(IssuanceIssuerOptions, done)
+	}
+	add(IssuanceTrustline, line != nil)
+	if cfg.Flags&AccountAuthRequired != 0 {
+		add(IssuanceAuthorize, line != nil && line.IsAuthorized != nil && *line.IsAuthorized)
+	}
+	add(IssuanceIssue, issued)
+	if cfg.XLMPrice != "" {
+		add(IssuanceOffer, offered)
+	}
+	if cfg.LockIssuer {
+		add(IssuanceLockIssuer, issuerAcct != nil && masterWeight(issuerAcct) == 0)
+	}
+
+	if issuerAcct != nil && masterWeight(issuerAcct) == 0 {
+		for _, s := range plan.Pending() {
+			if s.Name != IssuanceTrustline && s.Name != IssuanceOffer && s.Name != IssuanceCreateDistributor {
+				return nil, errors.New("issuer is locked and can't sign the remaining steps")
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// IssueAsset creates a new asset as described by cfg.  Steps that
+// already happened on the network are skipped, so calling it again with
+// the same config resumes an issuance that failed.  The remaining steps
+// are batched into as few transactions as possible: one to create the
+// accounts and one for everything else.
+//
+// The report is returned with any error and says which steps are done.
+func IssueAsset(cfg IssuanceConfig) (*IssuanceReport, error) {
+	plan, err := PlanAssetIssuance(cfg)
+	if err != nil {
+		return nil, err
+	}
+	report := &IssuanceReport{
+		Asset:       plan.Asset,
+		Issuer:      plan.Issuer,
+		Distributor: plan.Distributor,
+		Steps:       plan.Steps,
+	}
+
+	for _, batch := range plan.batches() {
+		sig, err := plan.signBatch(batch)
+		if err != nil {
+			return report, err
+		}
+		res, err := Submit(sig.Signed)
+		if err != nil {
+			return report, err
+		}
+		report.TxIDs = append(report.TxIDs, res.TxID)
+		for _, i := range batch {
+			report.Steps[i].TxID = res.TxID
+		}
+	}
+
+	report.Complete = true
+	return report, nil
+}
+
+// batches returns the indexes of the pending steps grouped into
+// transactions.  Operations from the new accounts can't be in the
+// transaction that creates them.
+func (p *IssuancePlan) batches() [][]int {
+	var create, rest []int
+	for i, s := range p.Steps {
+		if s.Done {
+			continue
+		}
+		switch s.Name {
+		case IssuanceCreateIssuer, IssuanceCreateDistributor:
+			create = append(create, i)
+		default:
+			rest = append(rest, i)
+		}
+	}
+	var batches [][]int
+	if len(create) > 0 {
+		batches = append(batches, create)
+	}
+	if len(rest) > 0 {
+		batches = append(batches, rest)
+	}
+	return batches
+}
+
+// signBatch builds and signs the transaction for the steps at indexes
+// batch.
+func (p *IssuancePlan) signBatch(batch []int) (SignResult, error) {
+	cfg := p.Config
+	source, err := cfg.Source.Address()
+	if err != nil {
+		return SignResult{}, err
+	}
+	issuerSeed, err := NewSeedStr(cfg.Issuer.Seed())
+	if err != nil {
+		return SignResult{}, err
+	}
+	distSeed, err := NewSeedStr(cfg.Distributor.Seed())
+	if err != nil {
+		return SignResult{}, err
+	}
+
+	t := NewBaseTx(source, Client(), cfg.BaseFee)
+	signers := []SeedStr{cfg.Source}
+	var issuerSigns, distSigns bool
+	for _, i := range batch {
+		switch p.Steps[i].Name {
+		case IssuanceCreateIssuer:
+			t.AddCreateAccountOp(p.Issuer, cfg.funding())
+		case IssuanceCreateDistributor:
+			t.AddCreateAccountOp(p.Distributor, cfg.funding())
+		case IssuanceIssuerOptions:
+			if cfg.HomeDomain != "" {
+				t.AddHomeDomainOp(cfg.HomeDomain)
+				t.setOpSource(p.Issuer)
+			}
+			if cfg.Flags != 0 {
+				t.AddSetAccountFlagsOp(cfg.Flags, 0)
+				t.setOpSource(p.Issuer)
+			}
+			issuerSigns = true
+		case IssuanceTrustline:
+			t.AddCreateTrustlineOp(cfg.AssetCode, p.Issuer, cfg.Amount)
+			t.setOpSource(p.Distributor)
+			distSigns = true
+		case IssuanceAuthorize:
+			t.AddTrustlineAuthorizationOp(p.Distributor, p.Asset, TrustlineAuthorize)
+			t.setOpSource(p.Issuer)
+			issuerSigns = true
+		case IssuanceIssue:
+			asset, err := assetBaseToXDR(p.Asset)
+			if err != nil {
+				return SignResult{}, err
+			}
+			t.AddAssetPaymentOp(p.Distributor, asset, cfg.Amount)
+			t.setOpSource(p.Issuer)
+			issuerSigns = true
+		case IssuanceOffer:
+			selling, err := assetBaseToXDR(p.Asset)
+			if err != nil {
+				return SignResult{}, err
+			}
+			t.AddOfferOp(selling, xdr.Asset{Type: xdr.AssetTypeAssetTypeNative}, cfg.Amount, cfg.XLMPrice)
+			t.setOpSource(p.Distributor)
+			distSigns = true
+		case IssuanceLockIssuer:
+			t.addLockOp()
+			t.setOpSource(p.Issuer)
+			issuerSigns = true
+		}
+	}
+	if cfg.MemoText != "" {
+		t.AddMemoText(cfg.MemoText)
+	}
+	if issuerSigns {
+		signers = append(signers, issuerSeed)
+	}
+	if distSigns {
+		signers = append(signers, distSeed)
+	}
+	if t.err != nil {
+		return SignResult{}, errMap(t.err)
+	}
+	return t.sign(signers...)
+}
+
+// addLockOp adds a set_options operation that sets the master key
+// weight to zero.
+func (t *Tx) addLockOp() {
+	if t.skipAddOp() {
+		return
+	}
+	w := xdr.Uint32(0)
+	t.addOp(xdr.OperationTypeSetOptions, xdr.SetOptionsOp{MasterWeight: &w})
+}
+
+// issuancePaid returns true if issuer has paid asset to distributor.
+func issuancePaid(issuer, distributor AddressStr, asset AssetMinimal) (bool, error) {
+	it := NewAccount(issuer).PaymentIterator(HistoryOptions{Order: "asc"})
+	for it.Next() {
+		p := it.Payment()
+		if p.Base.Type == "payment" && p.From == issuer.String() && p.To == distributor.String() &&
+			p.Asset.Code == asset.AssetCode && p.Asset.Issuer == asset.AssetIssuer {
+			return true, nil
+		}
+	}
+	return false, it.Err()
+}
+
+// issuanceOffered returns true if distributor has an open offer selling
+// asset for lumens.
+func issuanceOffered(distributor AddressStr, asset AssetMinimal) (bool, error) {
+	it := NewAccount(distributor).OfferIterator(HistoryOptions{Order: "asc"})
+	for it.Next() {
+		o := it.Offer()
+		if o.Selling.Code == asset.AssetCode && o.Selling.Issuer == asset.AssetIssuer && o.Buying.Type == "native" {
+			return true, nil
+		}
+	}
+	return false, it.Err()
+}
+
+// loadIssuanceAccount returns the account at address, or nil if it
+// doesn't exist.
+func loadIssuanceAccount(address AddressStr) (*horizonProtocol.Account, error) {
+	a := NewAccount(address)
+	if err := a.load(); err != nil {
+		if err == ErrSourceAccountNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return a.internal, nil
+}
+
+func horizonAccountFlags(f horizonProtocol.AccountFlags) AccountFlags {
+	var flags AccountFlags
+	if f.AuthRequired {
+		flags |= AccountAuthRequired
+	}
+	if f.AuthRevocable {
+		flags |= AccountAuthRevocable
+	}
+	if f.AuthImmutable {
+		flags |= AccountAuthImmutable
+	}
+	if f.AuthClawbackEnabled {
+		flags |= AccountAuthClawbackEnabled
+	}
+	return flags
+}
+
+// masterWeight returns the weight of the account's master key.
+func masterWeight(a *horizonProtocol.Account) int32 {
+	for _, s := range a.Signers {
+		if s.Key == a.AccountID {
+			return s.Weight
+		}
+	}
+	return 0
+}
@@ -0,0 +1,181 @@
+package stellarnet
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+// issuanceHorizon is a fake horizon with the accounts in accounts that
+// accepts every transaction.  payments and offers are the records of
+// the accounts' payments and offers.
+type issuanceHorizon struct {
+	sync.Mutex
+	accounts  map[string]string
+	payments  map[string]string
+	offers    map[string]string
+	envelopes []xdr.TransactionEnvelope
+}
+
+func (h *issuanceHorizon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Lock()
+	defer h.Unlock()
+	if r.Method == "POST" && r.URL.Path == "/transactions" {
+		var env xdr.TransactionEnvelope
+		if err := xdr.SafeUnmarshalBase64(r.FormValue("tx"), &env); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		h.envelopes = append(h.envelopes, env)
+		fmt.Fprintf(w, `{"hash": "tx%d", "ledger": %d}`, len(h.envelopes), 100+len(h.envelopes))
+		return
+	}
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/accounts/"), "/")
+	if account, ok := h.accounts[path[0]]; ok {
+		switch {
+		case len(path) == 1:
+			fmt.Fprint(w, account)
+		case path[1] == "payments":
+			fmt.Fprintf(w, `{"_embedded": {"records": [%s]}}`, h.payments[path[0]])
+		case path[1] == "offers":
+			fmt.Fprintf(w, `{"_embedded": {"records": [%s]}}`, h.offers[path[0]])
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte(`{"status": 404, "title": "Resource Missing"}`))
+}
+
+func issuanceAccount(address string, extra string) string {
+	return fmt.Sprintf(`{"id": %q, "account_id": %q, "sequence": "100", "signers": [{"key": %q, "weight": 1, "type": "ed25519_public_key"}],
+		"balances": [{"balance": "5.0000000", "asset_type": "native"}]%s}`, address, address, address, extra)
+}
+
+func opSources(t *testing.T, env xdr.TransactionEnvelope) []string {
+	var sources []string
+	for _, op := range env.Operations() {
+		if op.SourceAccount == nil {
+			sources = append(sources, "")
+			continue
+		}
+		sources = append(sources, op.SourceAccount.ToAccountId().Address())
+	}
+	return sources
+}
+
+func TestIssueAsset(t *testing.T) {
+	source := keypair.MustRandom()
+	issuer := keypair.MustRandom()
+	dist := keypair.MustRandom()
+	fake := &issuanceHorizon{accounts: map[string]string{
+		source.Address(): issuanceAccount(source.Address(), ""),
+	}, payments: map[string]string{}, offers: map[string]string{}}
+	_, restore := withFakeHorizon(t, fake)
+	defer restore()
+
+	cfg := IssuanceConfig{
+		Source:      seedStr(t, source),
+		Issuer:      issuer,
+		Distributor: dist,
+		AssetCode:   "GOLD",
+		Amount:      "1000",
+		HomeDomain:  "example.com",
+		XLMPrice:    "2",
+		Funding:     "3",
+		Flags:       AccountAuthRequired | AccountAuthRevocable,
+		LockIssuer:  true,
+		MemoText:    "issue GOLD",
+	}
+	report, err := IssueAsset(cfg)
+	require.NoError(t, err)
+	require.True(t, report.Complete)
+	require.Equal(t, []string{"tx1", "tx2"}, report.TxIDs)
+	require.Equal(t, AssetMinimal{AssetType: "credit_alphanum4", AssetCode: "GOLD", AssetIssuer: issuer.Address()}, report.Asset)
+	var names []string
+	for _, s := range report.Steps {
+		names = append(names, s.Name)
+		require.False(t, s.Done)
+		require.NotEmpty(t, s.TxID)
+	}
+	require.Equal(t, []string{IssuanceCreateIssuer, IssuanceCreateDistributor, IssuanceIssuerOptions, IssuanceTrustline,
+		IssuanceAuthorize, IssuanceIssue, IssuanceOffer, IssuanceLockIssuer}, names)
+
+	require.Len(t, fake.envelopes, 2)
+	create := fake.envelopes[0]
+	require.Equal(t, []string{"", ""}, opSources(t, create))
+	require.Equal(t, xdr.Int64(30000000), create.Operations()[0].Body.MustCreateAccountOp().StartingBalance)
+	require.Len(t, create.Signatures(), 1)
+	require.Equal(t, "issue GOLD", create.Memo().MustText())
+
+	rest := fake.envelopes[1]
+	require.Equal(t, []string{issuer.Address(), issuer.Address(), dist.Address(), issuer.Address(), issuer.Address(), dist.Address(), issuer.Address()}, opSources(t, rest))
+	require.Len(t, rest.Signatures(), 3)
+	require.Equal(t, xdr.Uint32(0), *rest.Operations()[6].Body.MustSetOptionsOp().MasterWeight)
+
+	// resume after the trustline was created
+	fake.envelopes = nil
+	fake.accounts[issuer.Address()] = issuanceAccount(issuer.Address(),
+		`, "home_domain": "example.com", "flags": {"auth_required": true, "auth_revocable": true}`)
+	fake.accounts[dist.Address()] = strings.Replace(issuanceAccount(dist.Address(), ""), `"balances": [`,
+		fmt.Sprintf(`"balances": [{"balance": "0.0000000", "limit": "1000.0000000", "asset_type": "credit_alphanum4", "asset_code": "GOLD", "asset_issuer": %q, "is_authorized": true}, `, issuer.Address()), 1)
+	plan, err := PlanAssetIssuance(cfg)
+	require.NoError(t, err)
+	var pending []string
+	for _, s := range plan.Pending() {
+		pending = append(pending, s.Name)
+	}
+	require.Equal(t, []string{IssuanceIssue, IssuanceOffer, IssuanceLockIssuer}, pending)
+
+	report, err = IssueAsset(cfg)
+	require.NoError(t, err)
+	require.Equal(t, []string{"tx1"}, report.TxIDs)
+	require.Len(t, fake.envelopes, 1)
+	require.Equal(t, []string{issuer.Address(), dist.Address(), issuer.Address()}, opSources(t, fake.envelopes[0]))
+	require.Equal(t, "", report.Steps[0].TxID)
+	require.True(t, report.Steps[0].Done)
+
+	// resume after the issue, when the distributor has sold everything
+	// or sent it on.  Neither is issued or offered again.
+	trustline := func(balance string) {
+		fake.accounts[dist.Address()] = strings.Replace(issuanceAccount(dist.Address(), ""), `"balances": [`,
+			fmt.Sprintf(`"balances": [{"balance": %q, "limit": "1000.0000000", "asset_type": "credit_alphanum4", "asset_code": "GOLD", "asset_issuer": %q, "is_authorized": true}, `, balance, issuer.Address()), 1)
+	}
+	trustline("0.0000000")
+	fake.payments[issuer.Address()] = fmt.Sprintf(`{"id": "1", "paging_token": "1", "type": "create_account", "type_i": 0},
+		{"id": "2", "paging_token": "2", "type": "payment", "type_i": 1, "from": %q, "to": %q, "asset_type": "credit_alphanum4", "asset_code": "GOLD", "asset_issuer": %q, "amount": "1000.0000000"}`,
+		issuer.Address(), dist.Address(), issuer.Address())
+	pendingSteps := func() []string {
+		plan, err := PlanAssetIssuance(cfg)
+		require.NoError(t, err)
+		var pending []string
+		for _, s := range plan.Pending() {
+			pending = append(pending, s.Name)
+		}
+		return pending
+	}
+	require.Equal(t, []string{IssuanceLockIssuer}, pendingSteps())
+
+	// with the tokens still there, the offer is done only if it's open
+	trustline("1000.0000000")
+	require.Equal(t, []string{IssuanceOffer, IssuanceLockIssuer}, pendingSteps())
+	fake.offers[dist.Address()] = fmt.Sprintf(`{"id": "7", "paging_token": "7", "seller": %q, "amount": "1000.0000000", "price": "2.0000000",
+		"selling": {"asset_type": "credit_alphanum4", "asset_code": "GOLD", "asset_issuer": %q}, "buying": {"asset_type": "native"}}`,
+		dist.Address(), issuer.Address())
+	require.Equal(t, []string{IssuanceLockIssuer}, pendingSteps())
+
+	// a locked issuer can't issue
+	delete(fake.payments, issuer.Address())
+	fake.accounts[issuer.Address()] = strings.Replace(fake.accounts[issuer.Address()], `"weight": 1`, `"weight": 0`, 1)
+	_, err = PlanAssetIssuance(cfg)
+	require.Error(t, err)
+
+	cfg.Amount = "0"
+	_, err = PlanAssetIssuance(cfg)
+	require.Equal(t, ErrInvalidParameter{Key: "amount"}, err)
+}
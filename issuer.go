@@ -0,0 +1,301 @@
+package stellarnet
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+// AccountFlags are the authorization flags of an issuing account.
+type AccountFlags uint32
+
+const (
+	// AccountAuthRequired means trustlines to the account's assets
+	// must be authorized by the issuer before they can hold them.
+	AccountAuthRequired = AccountFlags(xdr.AccountFlagsAuthRequiredFlag)
+	// AccountAuthRevocable means the issuer can revoke authorization.
+	AccountAuthRevocable = AccountFlags(xdr.AccountFlagsAuthRevocableFlag)
+	// AccountAuthImmutable means the flags can never be changed again
+	// and the account can't be merged.
+	AccountAuthImmutable = AccountFlags(xdr.AccountFlagsAuthImmutableFlag)
+	// AccountAuthClawbackEnabled means new trustlines to the account's
+	// assets can be clawed back.  It requires AccountAuthRevocable.
+	AccountAuthClawbackEnabled = AccountFlags(xdr.AccountFlagsAuthClawbackEnabledFlag)
+)
+
+// TrustlineFlags are the flags an issuer sets on a trustline to one of
+// its assets.
+type TrustlineFlags uint32
+
+const (
+	// TrustlineAuthorized lets the trustline hold, send and receive
+	// the asset.
+	TrustlineAuthorized = TrustlineFlags(xdr.TrustLineFlagsAuthorizedFlag)
+	// TrustlineAuthorizedToMaintainLiabilities lets the trustline keep
+	// its balance and offers, but not send, receive or create offers.
+	TrustlineAuthorizedToMaintainLiabilities = TrustlineFlags(xdr.TrustLineFlagsAuthorizedToMaintainLiabilitiesFlag)
+	// TrustlineClawbackEnabled means the issuer can claw back the
+	// trustline's balance.  It can only be cleared.
+	TrustlineClawbackEnabled = TrustlineFlags(xdr.TrustLineFlagsTrustlineClawbackEnabledFlag)
+)
+
+// TrustlineAuthorization is the authorization state an issuer gives a
+// trustline.
+type TrustlineAuthorization int
+
+const (
+	// TrustlineRevoke removes all authorization.  The trustline's
+	// offers are removed.
+	TrustlineRevoke TrustlineAuthorization = iota
+	// TrustlineAuthorize fully authorizes the trustline.
+	TrustlineAuthorize
+	// TrustlineAuthorizeToMaintainLiabilities only lets the trustline
+	// maintain its balance and offers.
+	TrustlineAuthorizeToMaintainLiabilities
+)
+
+// flags returns the trustline flags to set and clear for a.
+func (a TrustlineAuthorization) flags() (set, clear TrustlineFlags, err error) {
+	switch a {
+	case TrustlineRevoke:
+		return 0, TrustlineAuthorized | TrustlineAuthorizedToMaintainLiabilities, nil
+	case TrustlineAuthorize:
+		return TrustlineAuthorized, TrustlineAuthorizedToMaintainLiabilities, nil
+	case TrustlineAuthorizeToMaintainLiabilities:
+		return TrustlineAuthorizedToMaintainLiabilities, TrustlineAuthorized, nil
+	default:
+		return 0, 0, ErrInvalidParameter{Key: "authorization"}
+	}
+}
+
+// AddSetAccountFlagsOp adds a set_options operation that sets and
+// clears the source account's authorization flags.
+func (t *Tx) AddSetAccountFlagsOp(set, clear AccountFlags) {
+	if t.skipAddOp() {
+		return
+	}
+
+	if set&clear != 0 {
+		t.err = errors.New("cannot set and clear the same account flag")
+		return
+	}
+	if set&AccountAuthClawbackEnabled != 0 && clear&AccountAuthRevocable != 0 {
+		t.err = errors.New("clawback requires the auth revocable flag")
+		return
+	}
+
+	var op xdr.SetOptionsOp
+	if set != 0 {
+		s := xdr.Uint32(set)
+		op.SetFlags = &s
+	}
+	if clear != 0 {
+		c := xdr.Uint32(clear)
+		op.ClearFlags = &c
+	}
+
+	t.addOp(xdr.OperationTypeSetOptions, op)
+}
+
+// AddSetTrustlineFlagsOp adds a set_trust_line_flags operation that
+// sets and clears the flags of trustor's trustline to asset.  The
+// transaction source must be the asset's issuer.
+func (t *Tx) AddSetTrustlineFlagsOp(trustor AddressStr, asset AssetBase, set, clear TrustlineFlags) {
+	if t.skipAddOp() {
+		return
+	}
+
+	if set&clear != 0 {
+		t.err = errors.New("cannot set and clear the same trustline flag")
+		return
+	}
+	if set&TrustlineClawbackEnabled != 0 {
+		t.err = errors.New("trustline clawback can only be cleared")
+		return
+	}
+
+	var op xdr.SetTrustLineFlagsOp
+	op.Trustor, t.err = trustor.AccountID()
+	if t.err != nil {
+		return
+	}
+	op.Asset, t.err = issuedAssetToXDR(asset)
+	if t.err != nil {
+		return
+	}
+	op.SetFlags = xdr.Uint32(set)
+	op.ClearFlags = xdr.Uint32(clear)
+
+	t.addOp(xdr.OperationTypeSetTrustLineFlags, op)
+}
+
+// AddTrustlineAuthorizationOp adds a set_trust_line_flags operation
+// that changes the authorization of trustor's trustline to asset.
+func (t *Tx) AddTrustlineAuthorizationOp(trustor AddressStr, asset AssetBase, auth TrustlineAuthorization) {
+	if t.skipAddOp() {
+		return
+	}
+
+	set, clear, err := auth.flags()
+	if err != nil {
+		t.err = err
+		return
+	}
+	t.AddSetTrustlineFlagsOp(trustor, asset, set, clear)
+}
+
+// AddAllowTrustOp adds an allow_trust operation that changes the
+// authorization of trustor's trustline to the source account's asset
+// assetCode.  New code should use AddTrustlineAuthorizationOp, which
+// can't clear the clawback flag by mistake.
+func (t *Tx) AddAllowTrustOp(trustor AddressStr, assetCode string, auth TrustlineAuthorization) {
+	if t.skipAddOp() {
+		return
+	}
+
+	set, _, err := auth.flags()
+	if err != nil {
+		t.err = err
+		return
+	}
+
+	var op xdr.AllowTrustOp
+	op.Trustor, t.err = trustor.AccountID()
+	if t.err != nil {
+		return
+	}
+	x := len(assetCode)
+	switch {
+	case x >= 1 && x <= 4:
+		var code xdr.AssetCode4
+		copy(code[:], assetCode)
+		op.Asset, t.err = xdr.NewAssetCode(xdr.AssetTypeAssetTypeCreditAlphanum4, code)
+	case x >= 5 && x <= 12:
+		var code xdr.AssetCode12
+		copy(code[:], assetCode)
+		op.Asset, t.err = xdr.NewAssetCode(xdr.AssetTypeAssetTypeCreditAlphanum12, code)
+	default:
+		t.err = errors.New("invalid assetCode length")
+	}
+	if t.err != nil {
+		return
+	}
+	op.Authorize = xdr.Uint32(set)
+
+	t.addOp(xdr.OperationTypeAllowTrust, op)
+}
+
+// AddClawbackOp adds a clawback operation that burns amt of asset
+// from the from account.  The transaction source must be the asset's
+// issuer and from's trustline must have clawback enabled.
+func (t *Tx) AddClawbackOp(from AddressStr, asset AssetBase, amt string) {
+	if t.skipAddOp() {
+		return
+	}
+
+	var op xdr.ClawbackOp
+	op.Amount, t.err = amount.Parse(amt)
+	if t.err != nil {
+		return
+	}
+	if op.Amount <= 0 {
+		t.err = errors.New("clawback amount must be greater than zero")
+		return
+	}
+	op.From, t.err = from.MuxedAccount()
+	if t.err != nil {
+		return
+	}
+	op.Asset, t.err = issuedAssetToXDR(asset)
+	if t.err != nil {
+		return
+	}
+
+	t.addOp(xdr.OperationTypeClawback, op)
+}
+
+// AddClawbackClaimableBalanceOp adds a clawback_claimable_balance
+// operation for the claimable balance with hex ID balanceID.
+func (t *Tx) AddClawbackClaimableBalanceOp(balanceID string) {
+	if t.skipAddOp() {
+		return
+	}
+
+	var op xdr.ClawbackClaimableBalanceOp
+	if err := xdr.SafeUnmarshalHex(strings.TrimSpace(balanceID), &op.BalanceId); err != nil {
+		t.err = ErrInvalidParameter{Key: "balance_id"}
+		return
+	}
+
+	t.addOp(xdr.OperationTypeClawbackClaimableBalance, op)
+}
+
+// issuedAssetToXDR is assetBaseToXDR for operations that don't accept
+// the native asset.
+func issuedAssetToXDR(a AssetBase) (xdr.Asset, error) {
+	x, err := assetBaseToXDR(a)
+	if err != nil {
+		return xdr.Asset{}, err
+	}
+	if x.Type == xdr.AssetTypeAssetTypeNative {
+		return xdr.Asset{}, ErrInvalidParameter{Key: "asset"}
+	}
+	return x, nil
+}
+
+// SetAccountFlagsTransaction returns a signed transaction that sets and
+// clears from's authorization flags.
+func SetAccountFlagsTransaction(from SeedStr, set, clear AccountFlags, seqnoProvider SequenceProvider, timeBounds *txnbuild.Timebounds, baseFee uint64) (SignResult, error) {
+	t, err := newBaseTxSeed(from, seqnoProvider, baseFee)
+	if err != nil {
+		return SignResult{}, err
+	}
+	t.AddSetAccountFlagsOp(set, clear)
+	t.AddBuiltTimeBounds(timeBounds)
+
+	return t.Sign(from)
+}
+
+// TrustlineAuthorizationTransaction returns a signed transaction from
+// the issuer of asset that changes the authorization of trustor's
+// trustline to it.
+func TrustlineAuthorizationTransaction(issuer SeedStr, trustor AddressStr, asset AssetBase, auth TrustlineAuthorization, seqnoProvider SequenceProvider, timeBounds *txnbuild.Timebounds, baseFee uint64) (SignResult, error) {
+	t, err := newBaseTxSeed(issuer, seqnoProvider, baseFee)
+	if err != nil {
+		return SignResult{}, err
+	}
+	t.AddTrustlineAuthorizationOp(trustor, asset, auth)
+	t.AddBuiltTimeBounds(timeBounds)
+
+	return t.Sign(issuer)
+}
+
+// ClawbackTransaction returns a signed transaction from the issuer of
+// asset that claws back amt of it from the from account.
+func ClawbackTransaction(issuer SeedStr, from AddressStr, asset AssetBase, amt string, seqnoProvider SequenceProvider, timeBounds *txnbuild.Timebounds, baseFee uint64) (SignResult, error) {
+	t, err := newBaseTxSeed(issuer, seqnoProvider, baseFee)
+	if err != nil {
+		return SignResult{}, err
+	}
+	t.AddClawbackOp(from, asset, amt)
+	t.AddBuiltTimeBounds(timeBounds)
+
+	return t.Sign(issuer)
+}
+
+// ClawbackClaimableBalanceTransaction returns a signed transaction from
+// the issuer of a claimable balance's asset that claws back the
+// balance.
+func ClawbackClaimableBalanceTransaction(issuer SeedStr, balanceID string, seqnoProvider SequenceProvider, timeBounds *txnbuild.Timebounds, baseFee uint64) (SignResult, error) {
+	t, err := newBaseTxSeed(issuer, seqnoProvider, baseFee)
+	if err != nil {
+		return SignResult{}, err
+	}
+	t.AddClawbackClaimableBalanceOp(balanceID)
+	t.AddBuiltTimeBounds(timeBounds)
+
+	return t.Sign(issuer)
+}
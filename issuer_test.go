@@ -0,0 +1,74 @@
+package stellarnet
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuerOps(t *testing.T) {
+	issuer := keypair.MustRandom()
+	holder := keypair.MustRandom()
+	usd := AssetMinimal{AssetCode: "USD", AssetIssuer: issuer.Address()}
+	balanceID := "00000000" + strings.Repeat("ab", 32)
+
+	tx := NewBaseTx(addressStr(t, issuer), &testSeqnoProv{seqno: 100}, txnbuild.MinBaseFee)
+	tx.AddSetAccountFlagsOp(AccountAuthRequired|AccountAuthRevocable|AccountAuthClawbackEnabled, 0)
+	tx.AddTrustlineAuthorizationOp(addressStr(t, holder), usd, TrustlineAuthorize)
+	tx.AddTrustlineAuthorizationOp(addressStr(t, holder), usd, TrustlineAuthorizeToMaintainLiabilities)
+	tx.AddTrustlineAuthorizationOp(addressStr(t, holder), usd, TrustlineRevoke)
+	tx.AddSetTrustlineFlagsOp(addressStr(t, holder), usd, 0, TrustlineClawbackEnabled)
+	tx.AddAllowTrustOp(addressStr(t, holder), "USD", TrustlineAuthorize)
+	tx.AddAllowTrustOp(addressStr(t, holder), "LONGCODE", TrustlineRevoke)
+	tx.AddClawbackOp(addressStr(t, holder), usd, "12.5")
+	tx.AddClawbackClaimableBalanceOp(balanceID)
+	txEnv, err := tx.Envelope()
+	require.NoError(t, err)
+
+	var summaries []string
+	for _, op := range txEnv.Operations() {
+		summaries = append(summaries, OpSummary(op, false))
+	}
+	usdSummary := "USD/" + issuer.Address()
+	require.Equal(t, []string{
+		"Set account flags 1011",
+		fmt.Sprintf("Update trust line flags of %s for %s: set authorized; clear authorized_to_maintain_liabilities", usdSummary, holder.Address()),
+		fmt.Sprintf("Update trust line flags of %s for %s: set authorized_to_maintain_liabilities; clear authorized", usdSummary, holder.Address()),
+		fmt.Sprintf("Update trust line flags of %s for %s: clear authorized, authorized_to_maintain_liabilities", usdSummary, holder.Address()),
+		fmt.Sprintf("Update trust line flags of %s for %s: clear clawback_enabled", usdSummary, holder.Address()),
+		fmt.Sprintf("Authorize trustline to USD for %s", holder.Address()),
+		fmt.Sprintf("Deauthorize trustline to LONGCODE for %s", holder.Address()),
+		fmt.Sprintf("Claw back 12.5000000 %s from %s", usdSummary, holder.Address()),
+		"Claw back claimable balance " + balanceID,
+	}, summaries)
+	require.Equal(t, xdr.AssetTypeAssetTypeCreditAlphanum12, txEnv.Operations()[6].Body.MustAllowTrustOp().Asset.Type)
+
+	bad := []func(tx *Tx){
+		func(tx *Tx) { tx.AddSetAccountFlagsOp(AccountAuthRevocable, AccountAuthRevocable) },
+		func(tx *Tx) { tx.AddSetAccountFlagsOp(AccountAuthClawbackEnabled, AccountAuthRevocable) },
+		func(tx *Tx) { tx.AddSetTrustlineFlagsOp(addressStr(t, holder), usd, TrustlineClawbackEnabled, 0) },
+		func(tx *Tx) {
+			tx.AddTrustlineAuthorizationOp(addressStr(t, holder), AssetMinimal{}, TrustlineAuthorize)
+		},
+		func(tx *Tx) { tx.AddTrustlineAuthorizationOp(addressStr(t, holder), usd, TrustlineAuthorization(7)) },
+		func(tx *Tx) { tx.AddAllowTrustOp(addressStr(t, holder), "", TrustlineAuthorize) },
+		func(tx *Tx) { tx.AddClawbackOp(addressStr(t, holder), usd, "0") },
+		func(tx *Tx) { tx.AddClawbackClaimableBalanceOp("nope") },
+	}
+	for i, add := range bad {
+		tx := NewBaseTx(addressStr(t, issuer), &testSeqnoProv{seqno: 100}, txnbuild.MinBaseFee)
+		add(tx)
+		_, err := tx.Envelope()
+		require.Error(t, err, "case %d", i)
+	}
+
+	res, err := TrustlineAuthorizationTransaction(seedStr(t, issuer), addressStr(t, holder), usd, TrustlineAuthorize, &testSeqnoProv{seqno: 100}, nil, txnbuild.MinBaseFee)
+	require.NoError(t, err)
+	require.Equal(t, uint64(101), res.Seqno)
+	require.NotEmpty(t, res.Signed)
+}
@@ -0,0 +1,448 @@
+package stellarnet
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	horizonProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/protocols/horizon/operations"
+)
+
+// HistoryOptions controls how the history iterators walk through
+// horizon's pages.
+type HistoryOptions struct {
+	// Cursor is the paging token to start after.  Empty starts at
+	// the beginning (asc) or the end (desc).
+	Cursor string
+	// Order is "asc" or "desc".  Default is "desc".
+	Order string
+	// PageSize is the number of records requested per page.  Default
+	// and max is 200.
+	PageSize int
+	// MaxRecords stops the iterator after this many records.  Zero
+	// means no limit.
+	MaxRecords int
+	// StopTime stops the iterator at the first record past it in
+	// the iteration order: created before StopTime when the order is
+	// desc, after StopTime when the order is asc.
+	StopTime time.Time
+	// StopLedger stops the iterator at the first record past this
+	// ledger in the iteration order, like StopTime.  It only applies
+	// to records that have a ledger (transactions, and operations
+	// loaded with their transactions).
+	StopLedger int32
+}
+
+const historyMaxPageSize = 200
+
+func (o HistoryOptions) order() string {
+	if o.Order == "asc" {
+		return "asc"
+	}
+	return "desc"
+}
+
+func (o HistoryOptions) pageSize() int {
+	if o.PageSize <= 0 || o.PageSize > historyMaxPageSize {
+		return historyMaxPageSize
+	}
+	return o.PageSize
+}
+
+// firstLink returns the link to the first page for path.
+func (o HistoryOptions) firstLink(path string) (string, error) {
+	link, err := horizonLink(Client().HorizonURL, path)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("order", o.order())
+	q.Set("limit", fmt.Sprintf("%d", o.pageSize()))
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// pastTime returns true if t is past StopTime in the iteration order.
+func (o HistoryOptions) pastTime(t time.Time) bool {
+	if o.StopTime.IsZero() {
+		return false
+	}
+	if o.order() == "asc" {
+		return t.After(o.StopTime)
+	}
+	return t.Before(o.StopTime)
+}
+
+// pastLedger returns true if ledger is past StopLedger in the
+// iteration order.
+func (o HistoryOptions) pastLedger(ledger int32) bool {
+	if o.StopLedger == 0 {
+		return false
+	}
+	if o.order() == "asc" {
+		return ledger > o.StopLedger
+	}
+	return ledger < o.StopLedger
+}
+
+// historyPage is implemented by the horizon page types so
+// historyIterator can walk through them.
+type historyPage interface {
+	nextLink() string
+	numRecords() int
+}
+
+func (p *TransactionsPage) nextLink() string { return p.Links.Next.Href }
+func (p *TransactionsPage) numRecords() int  { return len(p.Embedded.Records) }
+func (p *PaymentsPage) nextLink() string     { return p.Links.Next.Href }
+func (p *PaymentsPage) numRecords() int      { return len(p.Embedded.Records) }
+func (p *OperationsPage) nextLink() string   { return p.Links.Next.Href }
+func (p *OperationsPage) numRecords() int    { return len(p.Embedded.Records) }
+func (p *AssetsPage) nextLink() string       { return p.Links.Next.Href }
+func (p *AssetsPage) numRecords() int        { return len(p.Embedded.Records) }
+func (p *EffectsPage) nextLink() string      { return p.Links.Next.Href }
+func (p *EffectsPage) numRecords() int       { return len(p.Embedded.Records) }
+func (p *OffersPage) nextLink() string       { return p.Links.Next.Href }
+func (p *OffersPage) numRecords() int        { return len(p.Embedded.Records) }
+func (p *AccountsPage) nextLink() string     { return p.Links.Next.Href }
+func (p *AccountsPage) numRecords() int      { return len(p.Embedded.Records) }
+
+// historyIterator fetches pages by following their next links.  The
+// typed iterators look at the records of page at index.
+type historyIterator struct {
+	opts    HistoryOptions
+	link    string
+	newPage func() historyPage
+	// stop returns true if the record at index in page is past a stop
+	// condition.
+	stop func(page historyPage, index int) bool
+
+	page  historyPage
+	index int
+	count int
+	err   error
+	done  bool
+}
+
+func newHistoryIterator(path string, opts HistoryOptions, newPage func() historyPage, stop func(historyPage, int) bool) *historyIterator {
+	it := &historyIterator{opts: opts, newPage: newPage, stop: stop}
+	it.link, it.err = opts.firstLink(path)
+	if it.err != nil {
+		it.err = errMap(it.err)
+		it.done = true
+	}
+	return it
+}
+
+// next advances to the next record, fetching the next page if needed.
+// When it returns false, page and index are left at the last record
+// returned so the typed iterators' Cursor still works.
+func (it *historyIterator) next() bool {
+	if it.done {
+		return false
+	}
+	if it.opts.MaxRecords > 0 && it.count >= it.opts.MaxRecords {
+		it.done = true
+		return false
+	}
+
+	page, index := it.page, it.index+1
+	if page == nil || index >= page.numRecords() {
+		if it.link == "" {
+			it.done = true
+			return false
+		}
+		page = it.newPage()
+		if err := getDecodeJSONStrict(it.link, Client().HTTP.Get, page); err != nil {
+			it.err = err
+			it.done = true
+			return false
+		}
+		index = 0
+		it.link = page.nextLink()
+		if page.numRecords() < it.opts.pageSize() {
+			// short page, so this is the last one
+			it.link = ""
+		}
+		if page.numRecords() == 0 {
+			it.done = true
+			return false
+		}
+	}
+
+	if it.stop != nil && it.stop(page, index) {
+		it.done = true
+		return false
+	}
+	it.page, it.index = page, index
+	it.count++
+	return true
+}
+
+// TransactionIterator iterates over an account's transactions.
+//
+//	it := account.TransactionIterator(HistoryOptions{})
+//	for it.Next() {
+//		tx := it.Transaction()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type TransactionIterator struct {
+	*historyIterator
+}
+
+// TransactionIterator returns an iterator over the account's transactions.
+func (a *Account) TransactionIterator(opts HistoryOptions) *TransactionIterator {
+	return &TransactionIterator{newHistoryIterator("/accounts/"+a.address.String()+"/transactions", opts,
+		func() historyPage { return &TransactionsPage{} },
+		func(p historyPage, i int) bool {
+			tx := p.(*TransactionsPage).Embedded.Records[i]
+			return opts.pastTime(tx.LedgerCloseTime) || opts.pastLedger(tx.Ledger)
+		})}
+}
+
+// Next advances to the next transaction.  It returns false when there
+// are no more transactions, a stop condition is reached or there is an
+// error.
+func (it *TransactionIterator) Next() bool { return it.next() }
+
+// Err returns the error that stopped the iterator, if any.
+func (it *TransactionIterator) Err() error { return it.err }
+
+// Transaction returns the current transaction.
+func (it *TransactionIterator) Transaction() TransactionEmbed {
+	return it.page.(*TransactionsPage).Embedded.Records[it.index]
+}
+
+// Cursor returns the paging token of the current transaction (the last
+// one returned).  It can be used as HistoryOptions.Cursor to resume
+// iterating after it.  Before the first record, it is the starting cursor.
+func (it *TransactionIterator) Cursor() string {
+	if it.page == nil {
+		return it.opts.Cursor
+	}
+	return it.Transaction().PT
+}
+
+// PaymentIterator iterates over an account's payments.
+type PaymentIterator struct {
+	*historyIterator
+}
+
+// PaymentIterator returns an iterator over the account's payments
+// (the same records as RecentPayments).
+func (a *Account) PaymentIterator(opts HistoryOptions) *PaymentIterator {
+	return newPaymentIterator("/accounts/"+a.address.String()+"/payments", opts)
+}
+
+// txPaymentIterator returns an iterator over the payments in a
+// transaction.
+func txPaymentIterator(txID string, opts HistoryOptions) *PaymentIterator {
+	return newPaymentIterator("/transactions/"+txID+"/payments", opts)
+}
+
+func newPaymentIterator(path string, opts HistoryOptions) *PaymentIterator {
+	return &PaymentIterator{newHistoryIterator(path, opts,
+		func() historyPage { return &PaymentsPage{} },
+		func(p historyPage, i int) bool {
+			return opts.pastTime(p.(*PaymentsPage).Embedded.Records[i].LedgerCloseTime)
+		})}
+}
+
+// Next advances to the next payment.
+func (it *PaymentIterator) Next() bool { return it.next() }
+
+// Err returns the error that stopped the iterator, if any.
+func (it *PaymentIterator) Err() error { return it.err }
+
+// Payment returns the current payment.
+func (it *PaymentIterator) Payment() operations.Payment {
+	return it.page.(*PaymentsPage).Embedded.Records[it.index]
+}
+
+// Cursor returns the paging token of the current payment.
+func (it *PaymentIterator) Cursor() string {
+	if it.page == nil {
+		return it.opts.Cursor
+	}
+	return it.Payment().PT
+}
+
+// OperationIterator iterates over operations.
+type OperationIterator struct {
+	*historyIterator
+}
+
+func newOperationIterator(path string, opts HistoryOptions) *OperationIterator {
+	return &OperationIterator{newHistoryIterator(path, opts,
+		func() historyPage { return &OperationsPage{} },
+		func(p historyPage, i int) bool {
+			op := p.(*OperationsPage).Embedded.Records[i]
+			if op.Transaction != nil && opts.pastLedger(op.Transaction.Ledger) {
+				return true
+			}
+			return opts.pastTime(op.CreatedAt)
+		})}
+}
+
+// OperationIterator returns an iterator over the account's operations.
+func (a *Account) OperationIterator(opts HistoryOptions) *OperationIterator {
+	return newOperationIterator("/accounts/"+a.address.String()+"/operations", opts)
+}
+
+// TxOperationIterator returns an iterator over the operations in a
+// transaction.  The order defaults to desc like the other iterators,
+// so set it to asc to get the operations in transaction order.
+func TxOperationIterator(txID string, opts HistoryOptions) *OperationIterator {
+	txID, err := CheckTxID(txID)
+	if err != nil {
+		return &OperationIterator{&historyIterator{err: err, done: true}}
+	}
+	return newOperationIterator("/transactions/"+txID+"/operations", opts)
+}
+
+// Next advances to the next operation.
+func (it *OperationIterator) Next() bool { return it.next() }
+
+// Err returns the error that stopped the iterator, if any.
+func (it *OperationIterator) Err() error { return it.err }
+
+// Operation returns the current operation.
+func (it *OperationIterator) Operation() Operation {
+	return it.page.(*OperationsPage).Embedded.Records[it.index]
+}
+
+// Cursor returns the paging token of the current operation.
+func (it *OperationIterator) Cursor() string {
+	if it.page == nil {
+		return it.opts.Cursor
+	}
+	return it.Operation().PagingToken
+}
+
+// AssetIterator iterates over all the assets on the network.
+type AssetIterator struct {
+	*historyIterator
+}
+
+// NewAssetIterator returns an iterator over all the assets (the same
+// records as AssetList).  StopTime and StopLedger don't apply to assets.
+func NewAssetIterator(opts HistoryOptions) *AssetIterator {
+	return &AssetIterator{newHistoryIterator("/assets", opts,
+		func() historyPage { return &AssetsPage{} }, nil)}
+}
+
+// Next advances to the next asset.
+func (it *AssetIterator) Next() bool { return it.next() }
+
+// Err returns the error that stopped the iterator, if any.
+func (it *AssetIterator) Err() error { return it.err }
+
+// Asset returns the current asset.
+func (it *AssetIterator) Asset() AssetSummary {
+	return assetEmbedSummary(it.page.(*AssetsPage).Embedded.Records[it.index])
+}
+
+// Cursor returns the paging token of the current asset.
+func (it *AssetIterator) Cursor() string {
+	if it.page == nil {
+		return it.opts.Cursor
+	}
+	return it.page.(*AssetsPage).Embedded.Records[it.index].PagingToken
+}
+
+// OfferIterator iterates over offers.
+type OfferIterator struct {
+	*historyIterator
+}
+
+// OfferIterator returns an iterator over the account's open offers.
+// StopTime and StopLedger don't apply to offers.
+func (a *Account) OfferIterator(opts HistoryOptions) *OfferIterator {
+	return newOfferIterator("/accounts/"+a.address.String()+"/offers", opts)
+}
+
+func newOfferIterator(path string, opts HistoryOptions) *OfferIterator {
+	return &OfferIterator{newHistoryIterator(path, opts,
+		func() historyPage { return &OffersPage{} }, nil)}
+}
+
+// Next advances to the next offer.
+func (it *OfferIterator) Next() bool { return it.next() }
+
+// Err returns the error that stopped the iterator, if any.
+func (it *OfferIterator) Err() error { return it.err }
+
+// Offer returns the current offer.
+func (it *OfferIterator) Offer() horizonProtocol.Offer {
+	return it.page.(*OffersPage).Embedded.Records[it.index]
+}
+
+// Cursor returns the paging token of the current offer.
+func (it *OfferIterator) Cursor() string {
+	if it.page == nil {
+		return it.opts.Cursor
+	}
+	return it.Offer().PT
+}
+
+// TransactionsAndOps reads all the transactions from it and loads
+// their operations, making at most concurrency operation requests at
+// once.  Set MaxRecords or a stop condition in the iterator's options
+// to bound the number of transactions.
+func TransactionsAndOps(it *TransactionIterator, concurrency int) ([]Transaction, error) {
+	var transactions []Transaction
+	for it.Next() {
+		transactions = append(transactions, Transaction{Internal: it.Transaction()})
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	errs := make([]error, len(transactions))
+	var wg sync.WaitGroup
+	for i := range transactions {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			transactions[i].Operations, errs[i] = txOperations(transactions[i].Internal.ID)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return transactions, nil
+}
+
+// txOperations returns all the operations in a transaction, in order.
+func txOperations(txID string) ([]Operation, error) {
+	var ops []Operation
+	it := TxOperationIterator(txID, HistoryOptions{Order: "asc"})
+	for it.Next() {
+		ops = append(ops, it.Operation())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
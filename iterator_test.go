@@ -0,0 +1,245 @@
+package stellarnet
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testHistoryAccount = "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO"
+
+var testHistoryStart = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testTxHash returns a fake transaction hash for transaction i.
+func testTxHash(i int) string {
+	return fmt.Sprintf("%064x", i)
+}
+
+// historyHorizon is a fake horizon with five transactions (ledgers
+// 101-105, one minute apart), each with i operations, that pages its
+// results like horizon does.
+type historyHorizon struct {
+	sync.Mutex
+	url      string
+	requests []string
+	active   int
+	maxConc  int
+}
+
+func (h *historyHorizon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Lock()
+	h.requests = append(h.requests, r.URL.Path+"?"+r.URL.RawQuery)
+	h.active++
+	if h.active > h.maxConc {
+		h.maxConc = h.active
+	}
+	h.Unlock()
+	defer func() {
+		h.Lock()
+		h.active--
+		h.Unlock()
+	}()
+
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit == 0 {
+		// a small default so the tests page
+		limit = 3
+	}
+	cursor, _ := strconv.Atoi(q.Get("cursor"))
+	asc := q.Get("order") == "asc"
+
+	// ids are the paging tokens
+	var ids []int
+	var record func(id int) string
+	switch {
+	case r.URL.Path == "/accounts/"+testHistoryAccount+"/transactions":
+		ids = []int{1, 2, 3, 4, 5}
+		record = func(id int) string {
+			return fmt.Sprintf(`{"id": %q, "hash": %q, "paging_token": "%d", "ledger": %d, "created_at": %q}`,
+				testTxHash(id), testTxHash(id), id, 100+id, testHistoryStart.Add(time.Duration(id)*time.Minute).Format(time.RFC3339))
+		}
+	case r.URL.Path == "/accounts/"+testHistoryAccount+"/payments":
+		ids = []int{1, 2, 3, 4, 5}
+		record = func(id int) string {
+			return fmt.Sprintf(`{"id": "%d", "paging_token": "%d", "type": "payment", "amount": "%d.0000000", "created_at": %q}`,
+				id, id, id, testHistoryStart.Add(time.Duration(id)*time.Minute).Format(time.RFC3339))
+		}
+	case strings.HasPrefix(r.URL.Path, "/transactions/") && strings.HasSuffix(r.URL.Path, "/payments"):
+		hash := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/transactions/"), "/payments")
+		n, _ := strconv.ParseInt(hash, 16, 64)
+		for i := 1; i <= int(n); i++ {
+			ids = append(ids, int(n)*10+i)
+		}
+		asc = true
+		record = func(id int) string {
+			return fmt.Sprintf(`{"id": "%d", "paging_token": "%d", "type": "payment", "amount": "%d.0000000", "transaction_hash": %q}`, id, id, id%10, hash)
+		}
+	case strings.HasPrefix(r.URL.Path, "/transactions/") && strings.HasSuffix(r.URL.Path, "/operations"):
+		hash := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/transactions/"), "/operations")
+		n, _ := strconv.ParseInt(hash, 16, 64)
+		for i := 1; i <= int(n); i++ {
+			ids = append(ids, int(n)*10+i)
+		}
+		record = func(id int) string {
+			return fmt.Sprintf(`{"id": "%d", "paging_token": "%d", "type": "payment", "transaction_hash": %q}`, id, id, hash)
+		}
+		// let concurrent requests overlap
+		time.Sleep(5 * time.Millisecond)
+	case r.URL.Path == "/accounts/"+testHistoryAccount+"/operations":
+		for n := 1; n <= 5; n++ {
+			for i := 1; i <= n; i++ {
+				ids = append(ids, n*10+i)
+			}
+		}
+		record = func(id int) string {
+			n := id / 10
+			var tx string
+			if q.Get("join") == "transactions" {
+				tx = fmt.Sprintf(`, "transaction": {"id": %q, "hash": %q, "paging_token": "%d", "ledger": %d}`, testTxHash(n), testTxHash(n), n, 100+n)
+			}
+			return fmt.Sprintf(`{"id": "%d", "paging_token": "%d", "type": "payment", "transaction_hash": %q, "created_at": %q%s}`,
+				id, id, testTxHash(n), testHistoryStart.Add(time.Duration(n)*time.Minute).Format(time.RFC3339), tx)
+		}
+	case strings.HasSuffix(r.URL.Path, "/effects"):
+		for i := range testEffects {
+			ids = append(ids, i+1)
+		}
+		record = func(id int) string {
+			return fmt.Sprintf(`{"id": "12345-%d", "paging_token": "%d", "created_at": %q, %s}`,
+				id, id, testHistoryStart.Format(time.RFC3339), testEffects[id-1])
+		}
+	case r.URL.Path == "/assets":
+		ids = []int{1, 2, 3}
+		record = func(id int) string {
+			return fmt.Sprintf(`{"asset_type": "credit_alphanum4", "asset_code": "A%d", "asset_issuer": %q, "paging_token": "A%d_%d", "num_accounts": %d}`,
+				id, testHistoryAccount, id, id, id)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status": 404, "title": "Resource Missing"}`))
+		return
+	}
+
+	if !asc {
+		for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+			ids[i], ids[j] = ids[j], ids[i]
+		}
+	}
+	var records []string
+	last := q.Get("cursor")
+	for _, id := range ids {
+		if q.Get("cursor") != "" && ((asc && id <= cursor) || (!asc && id >= cursor)) {
+			continue
+		}
+		if len(records) == limit {
+			break
+		}
+		records = append(records, record(id))
+		last = strconv.Itoa(id)
+	}
+	next := fmt.Sprintf("%s%s?cursor=%s&limit=%d&order=%s&join=%s", h.url, r.URL.Path, last, limit, q.Get("order"), q.Get("join"))
+	fmt.Fprintf(w, `{"_links": {"next": {"href": %q}}, "_embedded": {"records": [%s]}}`, next, strings.Join(records, ","))
+}
+
+func withHistoryHorizon(t *testing.T) (*historyHorizon, func()) {
+	fake := &historyHorizon{}
+	url, restore := withFakeHorizon(t, fake)
+	fake.url = url
+	return fake, restore
+}
+
+func collectTransactions(t *testing.T, opts HistoryOptions) []int32 {
+	var ledgers []int32
+	it := NewAccount(testHistoryAccount).TransactionIterator(opts)
+	for it.Next() {
+		ledgers = append(ledgers, it.Transaction().Ledger)
+	}
+	require.NoError(t, it.Err())
+	return ledgers
+}
+
+func TestTransactionIterator(t *testing.T) {
+	fake, done := withHistoryHorizon(t)
+	defer done()
+
+	require.Equal(t, []int32{105, 104, 103, 102, 101}, collectTransactions(t, HistoryOptions{PageSize: 2}))
+	// 2 + 2 + 1, the short page is the last one
+	require.Len(t, fake.requests, 3)
+
+	require.Equal(t, []int32{101, 102, 103, 104, 105}, collectTransactions(t, HistoryOptions{Order: "asc", PageSize: 2}))
+	require.Equal(t, []int32{104, 103}, collectTransactions(t, HistoryOptions{Cursor: "5", MaxRecords: 2}))
+	require.Equal(t, []int32{105, 104, 103}, collectTransactions(t, HistoryOptions{StopLedger: 103}))
+	require.Equal(t, []int32{101, 102}, collectTransactions(t, HistoryOptions{Order: "asc", StopLedger: 102}))
+	require.Equal(t, []int32{105, 104}, collectTransactions(t, HistoryOptions{StopTime: testHistoryStart.Add(4 * time.Minute)}))
+
+	// resume from the cursor of the last record seen
+	it := NewAccount(testHistoryAccount).TransactionIterator(HistoryOptions{Order: "asc", MaxRecords: 3})
+	for it.Next() {
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, "3", it.Cursor())
+	require.Equal(t, []int32{104, 105}, collectTransactions(t, HistoryOptions{Order: "asc", Cursor: it.Cursor()}))
+
+	it = NewAccount("GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB").TransactionIterator(HistoryOptions{})
+	require.False(t, it.Next())
+	require.Equal(t, ErrResourceNotFound, it.Err())
+}
+
+func TestPaymentAndAssetIterators(t *testing.T) {
+	_, done := withHistoryHorizon(t)
+	defer done()
+
+	var amounts []string
+	pit := NewAccount(testHistoryAccount).PaymentIterator(HistoryOptions{PageSize: 3, StopTime: testHistoryStart.Add(2 * time.Minute)})
+	for pit.Next() {
+		amounts = append(amounts, pit.Payment().Amount)
+	}
+	require.NoError(t, pit.Err())
+	require.Equal(t, []string{"5.0000000", "4.0000000", "3.0000000", "2.0000000"}, amounts)
+	require.Equal(t, "2", pit.Cursor())
+
+	var codes []string
+	ait := NewAssetIterator(HistoryOptions{Order: "asc", PageSize: 2})
+	for ait.Next() {
+		codes = append(codes, ait.Asset().AssetCode)
+	}
+	require.NoError(t, ait.Err())
+	require.Equal(t, []string{"A1", "A2", "A3"}, codes)
+}
+
+func TestTransactionsAndOps(t *testing.T) {
+	fake, done := withHistoryHorizon(t)
+	defer done()
+
+	var ops []string
+	oit := TxOperationIterator(testTxHash(3), HistoryOptions{Order: "asc", PageSize: 2})
+	for oit.Next() {
+		ops = append(ops, oit.Operation().ID)
+	}
+	require.NoError(t, oit.Err())
+	require.Equal(t, []string{"31", "32", "33"}, ops)
+
+	it := NewAccount(testHistoryAccount).TransactionIterator(HistoryOptions{MaxRecords: 4})
+	txs, err := TransactionsAndOps(it, 2)
+	require.NoError(t, err)
+	require.Len(t, txs, 4)
+	for i, tx := range txs {
+		n := 5 - i
+		require.Equal(t, testTxHash(n), tx.Internal.Hash)
+		require.Len(t, tx.Operations, n)
+		require.Equal(t, fmt.Sprintf("%d1", n), tx.Operations[0].ID)
+		require.Equal(t, testTxHash(n), tx.Operations[0].TransactionHash)
+	}
+	require.True(t, fake.maxConc <= 2)
+
+	oit = TxOperationIterator("not a hash", HistoryOptions{})
+	require.False(t, oit.Next())
+	require.Error(t, oit.Err())
+}
@@ -0,0 +1,380 @@
+package stellarnet
+
+import (
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	horizonProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/support/render/hal"
+)
+
+// OrderBookLevel is the total amount of the offers at a price.
+type OrderBookLevel struct {
+	Price  *big.Rat
+	Amount string
+}
+
+// OrderBookSnapshot is the offers between two assets at one point in time.
+//
+// Asks are offers selling Selling for Buying, best (lowest) price first.
+// Bids are offers buying Selling with Buying, best (highest) price
+// first.  All prices are in units of Buying per unit of Selling.  The
+// amounts of asks are in Selling and the amounts of bids are in Buying,
+// like horizon's order book.
+type OrderBookSnapshot struct {
+	Selling AssetMinimal
+	Buying  AssetMinimal
+	Bids    []OrderBookLevel
+	Asks    []OrderBookLevel
+}
+
+// OrderBook returns the order book between selling and buying, with up
+// to limit price levels on each side.  If limit is zero, horizon's
+// default is used.
+func OrderBook(selling, buying AssetBase, limit int) (*OrderBookSnapshot, error) {
+	v := url.Values{}
+	setAssetValues(v, "selling_", selling)
+	setAssetValues(v, "buying_", buying)
+	if limit > 0 {
+		v.Set("limit", strconv.Itoa(limit))
+	}
+	link, err := horizonLink(Client().HorizonURL, "/order_book?"+v.Encode())
+	if err != nil {
+		return nil, errMap(err)
+	}
+	var summary horizonProtocol.OrderBookSummary
+	if err := getDecodeJSONStrict(link, Client().HTTP.Get, &summary); err != nil {
+		return nil, err
+	}
+
+	book := &OrderBookSnapshot{
+		Selling: assetMinimalFromParts(selling.TypeString(), selling.CodeString(), selling.IssuerString()),
+		Buying:  assetMinimalFromParts(buying.TypeString(), buying.CodeString(), buying.IssuerString()),
+	}
+	book.Bids, err = orderBookLevels(summary.Bids)
+	if err != nil {
+		return nil, err
+	}
+	book.Asks, err = orderBookLevels(summary.Asks)
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+func orderBookLevels(levels []horizonProtocol.PriceLevel) ([]OrderBookLevel, error) {
+	out := make([]OrderBookLevel, len(levels))
+	for i, l := range levels {
+		price, err := priceRat(int64(l.PriceR.N), int64(l.PriceR.D), l.Price)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = OrderBookLevel{Price: price, Amount: l.Amount}
+	}
+	return out, nil
+}
+
+// priceRat returns n/d, or the decimal price if d is zero (when horizon
+// doesn't send the fraction).
+func priceRat(n, d int64, decimal string) (*big.Rat, error) {
+	if d != 0 {
+		return big.NewRat(n, d), nil
+	}
+	return ParseAmount(decimal)
+}
+
+// setAssetValues sets the horizon query parameters for asset a, with
+// names starting with prefix.
+func setAssetValues(v url.Values, prefix string, a AssetBase) {
+	if a.TypeString() == "native" || (a.CodeString() == "" && a.IssuerString() == "") {
+		v.Set(prefix+"asset_type", "native")
+		return
+	}
+	code := strings.TrimSpace(a.CodeString())
+	assetType := a.TypeString()
+	if assetType == "" {
+		assetType, _ = assetCodeToType(code)
+	}
+	v.Set(prefix+"asset_type", assetType)
+	v.Set(prefix+"asset_code", code)
+	v.Set(prefix+"asset_issuer", strings.TrimSpace(a.IssuerString()))
+}
+
+func assetMinimalFromParts(assetType, code, issuer string) AssetMinimal {
+	if assetType == "native" || (code == "" && issuer == "") {
+		return AssetMinimal{AssetType: "native"}
+	}
+	if assetType == "" {
+		assetType, _ = assetCodeToType(code)
+	}
+	return AssetMinimal{AssetType: assetType, AssetCode: code, AssetIssuer: issuer}
+}
+
+// Trade is a trade from horizon.
+type Trade struct {
+	horizonProtocol.Trade
+}
+
+// PriceRat returns the price of the trade in units of the counter asset
+// per unit of the base asset.
+func (t Trade) PriceRat() *big.Rat {
+	if t.Price.D == 0 {
+		return nil
+	}
+	return big.NewRat(t.Price.N, t.Price.D)
+}
+
+// BaseAsset returns the base asset of the trade.
+func (t Trade) BaseAsset() AssetMinimal {
+	return assetMinimalFromParts(t.BaseAssetType, t.BaseAssetCode, t.BaseAssetIssuer)
+}
+
+// CounterAsset returns the counter asset of the trade.
+func (t Trade) CounterAsset() AssetMinimal {
+	return assetMinimalFromParts(t.CounterAssetType, t.CounterAssetCode, t.CounterAssetIssuer)
+}
+
+// TradesPage is a page of trades.
+type TradesPage struct {
+	Links struct {
+		Self hal.Link `json:"self"`
+		Next hal.Link `json:"next"`
+		Prev hal.Link `json:"prev"`
+	} `json:"_links"`
+	Embedded struct {
+		Records []Trade `json:"records"`
+	} `json:"_embedded"`
+}
+
+func (p *TradesPage) nextLink() string { return p.Links.Next.Href }
+func (p *TradesPage) numRecords() int  { return len(p.Embedded.Records) }
+
+// TradeFilter selects the trades returned by Trades.  Account can't be
+// combined with the other fields.  Base and Counter must be set together.
+type TradeFilter struct {
+	Account AddressStr
+	OfferID string
+	Base    AssetBase
+	Counter AssetBase
+}
+
+func (f TradeFilter) path() (string, error) {
+	if f.Account != "" {
+		if f.OfferID != "" || f.Base != nil || f.Counter != nil {
+			return "", ErrInvalidParameter{Key: "account"}
+		}
+		return "/accounts/" + f.Account.String() + "/trades", nil
+	}
+	if (f.Base == nil) != (f.Counter == nil) {
+		return "", ErrInvalidParameter{Key: "counter"}
+	}
+	v := url.Values{}
+	if f.Base != nil {
+		setAssetValues(v, "base_", f.Base)
+		setAssetValues(v, "counter_", f.Counter)
+	}
+	if f.OfferID != "" {
+		v.Set("offer_id", f.OfferID)
+	}
+	if len(v) == 0 {
+		return "/trades", nil
+	}
+	return "/trades?" + v.Encode(), nil
+}
+
+// TradeIterator iterates over trades.
+type TradeIterator struct {
+	*historyIterator
+}
+
+// NewTradeIterator returns an iterator over the trades selected by
+// filter.  StopLedger doesn't apply to trades.
+func NewTradeIterator(filter TradeFilter, opts HistoryOptions) *TradeIterator {
+	path, err := filter.path()
+	if err != nil {
+		return &TradeIterator{&historyIterator{err: err, done: true}}
+	}
+	return &TradeIterator{newHistoryIterator(path, opts,
+		func() historyPage { return &TradesPage{} },
+		func(p historyPage, i int) bool {
+			return opts.pastTime(p.(*TradesPage).Embedded.Records[i].LedgerCloseTime)
+		})}
+}
+
+// Next advances to the next trade.
+func (it *TradeIterator) Next() bool { return it.next() }
+
+// Err returns the error that stopped the iterator, if any.
+func (it *TradeIterator) Err() error { return it.err }
+
+// Trade returns the current trade.
+func (it *TradeIterator) Trade() Trade {
+	return it.page.(*TradesPage).Embedded.Records[it.index]
+}
+
+// Cursor returns the paging token of the current trade.
+func (it *TradeIterator) Cursor() string {
+	if it.page == nil {
+		return it.opts.Cursor
+	}
+	return it.Trade().PT
+}
+
+// Trades returns the trades selected by filter.  Set opts.MaxRecords or
+// opts.StopTime to bound the number of trades.
+func Trades(filter TradeFilter, opts HistoryOptions) ([]Trade, error) {
+	var trades []Trade
+	it := NewTradeIterator(filter, opts)
+	for it.Next() {
+		trades = append(trades, it.Trade())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return trades, nil
+}
+
+// TradeAggregationResolutions are the resolutions horizon supports for
+// trade aggregations.
+var TradeAggregationResolutions = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// TradeAggregationsArg are the arguments to TradeAggregations.
+type TradeAggregationsArg struct {
+	Base    AssetBase
+	Counter AssetBase
+	// Start and End limit the aggregations to trades in [Start, End).
+	// Zero values mean no limit.
+	Start time.Time
+	End   time.Time
+	// Resolution is the length of each bucket, one of
+	// TradeAggregationResolutions.
+	Resolution time.Duration
+	// Offset shifts the buckets, for example to start days at a time
+	// zone's midnight.  It must be whole hours, less than a day and
+	// less than Resolution.
+	Offset time.Duration
+	// Limit is the number of buckets to return.  Zero uses horizon's
+	// default.
+	Limit int
+	// Order is "asc" or "desc".  Default is "asc".
+	Order string
+}
+
+// TradeAggregation is a bucket of trades.  Prices are in units of the
+// counter asset per unit of the base asset.
+type TradeAggregation struct {
+	Time          time.Time
+	TradeCount    int64
+	BaseVolume    string
+	CounterVolume string
+	Average       *big.Rat
+	High          *big.Rat
+	Low           *big.Rat
+	Open          *big.Rat
+	Close         *big.Rat
+}
+
+// TradeAggregationsPage is a page of trade aggregations.
+type TradeAggregationsPage struct {
+	Embedded struct {
+		Records []horizonProtocol.TradeAggregation `json:"records"`
+	} `json:"_embedded"`
+}
+
+// TradeAggregations returns trade aggregations (OHLC buckets) for a pair
+// of assets.
+func TradeAggregations(arg TradeAggregationsArg) ([]TradeAggregation, error) {
+	if arg.Base == nil {
+		return nil, ErrMissingParameter{Key: "base"}
+	}
+	if arg.Counter == nil {
+		return nil, ErrMissingParameter{Key: "counter"}
+	}
+	validResolution := false
+	for _, r := range TradeAggregationResolutions {
+		if arg.Resolution == r {
+			validResolution = true
+		}
+	}
+	if !validResolution {
+		return nil, ErrInvalidParameter{Key: "resolution"}
+	}
+	if arg.Offset < 0 || arg.Offset%time.Hour != 0 || arg.Offset >= 24*time.Hour || (arg.Offset > 0 && arg.Offset >= arg.Resolution) {
+		return nil, ErrInvalidParameter{Key: "offset"}
+	}
+
+	v := url.Values{}
+	setAssetValues(v, "base_", arg.Base)
+	setAssetValues(v, "counter_", arg.Counter)
+	v.Set("resolution", strconv.FormatInt(durationMillis(arg.Resolution), 10))
+	if arg.Offset > 0 {
+		v.Set("offset", strconv.FormatInt(durationMillis(arg.Offset), 10))
+	}
+	if !arg.Start.IsZero() {
+		v.Set("start_time", strconv.FormatInt(timeMillis(arg.Start), 10))
+	}
+	if !arg.End.IsZero() {
+		v.Set("end_time", strconv.FormatInt(timeMillis(arg.End), 10))
+	}
+	if arg.Limit > 0 {
+		v.Set("limit", strconv.Itoa(arg.Limit))
+	}
+	if arg.Order == "desc" {
+		v.Set("order", "desc")
+	} else {
+		v.Set("order", "asc")
+	}
+	link, err := horizonLink(Client().HorizonURL, "/trade_aggregations?"+v.Encode())
+	if err != nil {
+		return nil, errMap(err)
+	}
+	var page TradeAggregationsPage
+	if err := getDecodeJSONStrict(link, Client().HTTP.Get, &page); err != nil {
+		return nil, err
+	}
+
+	out := make([]TradeAggregation, len(page.Embedded.Records))
+	for i, r := range page.Embedded.Records {
+		agg := TradeAggregation{
+			Time:          time.Unix(0, r.Timestamp*int64(time.Millisecond)).UTC(),
+			TradeCount:    r.TradeCount,
+			BaseVolume:    r.BaseVolume,
+			CounterVolume: r.CounterVolume,
+		}
+		if agg.Average, err = ParseAmount(r.Average); err != nil {
+			return nil, err
+		}
+		if agg.High, err = priceRat(r.HighR.N, r.HighR.D, r.High); err != nil {
+			return nil, err
+		}
+		if agg.Low, err = priceRat(r.LowR.N, r.LowR.D, r.Low); err != nil {
+			return nil, err
+		}
+		if agg.Open, err = priceRat(r.OpenR.N, r.OpenR.D, r.Open); err != nil {
+			return nil, err
+		}
+		if agg.Close, err = priceRat(r.CloseR.N, r.CloseR.D, r.Close); err != nil {
+			return nil, err
+		}
+		out[i] = agg
+	}
+	return out, nil
+}
+
+func durationMillis(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+func timeMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
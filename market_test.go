@@ -0,0 +1,159 @@
+package stellarnet
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testMarketIssuer = "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX"
+
+var testMarketUSD = AssetMinimal{AssetType: "credit_alphanum4", AssetCode: "USD", AssetIssuer: testMarketIssuer}
+
+type marketHorizon struct {
+	url      string
+	requests []string
+	// poolUSD is the USD reserve of the XLM/USD liquidity pool, which
+	// only exists if it is set.
+	poolUSD string
+}
+
+func (h *marketHorizon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.requests = append(h.requests, r.URL.Path+"?"+r.URL.RawQuery)
+	q := r.URL.Query()
+	switch r.URL.Path {
+	case "/order_book":
+		if q.Get("selling_asset_type") != "native" || q.Get("buying_asset_code") != "USD" || q.Get("buying_asset_issuer") != testMarketIssuer {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status": 400, "title": "Bad Request"}`))
+			return
+		}
+		fmt.Fprint(w, `{
+			"bids": [{"price_r": {"n": 1, "d": 4}, "price": "0.2500000", "amount": "50.0000000"}],
+			"asks": [{"price_r": {"n": 1, "d": 3}, "price": "0.3333333", "amount": "30.0000000"}, {"price": "0.5000000", "amount": "10.0000000"}],
+			"base": {"asset_type": "native"},
+			"counter": {"asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": "`+testMarketIssuer+`"}}`)
+	case "/trades", "/accounts/GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO/trades":
+		var records []string
+		if q.Get("cursor") == "" {
+			for i := 1; i <= 2; i++ {
+				records = append(records, fmt.Sprintf(`{"id": "%d", "paging_token": "%d", "ledger_close_time": "2020-01-0%dT00:00:00Z",
+					"base_amount": "%d.0000000", "base_asset_type": "native",
+					"counter_amount": "1.0000000", "counter_asset_type": "credit_alphanum4", "counter_asset_code": "USD", "counter_asset_issuer": %q,
+					"price": {"n": "1", "d": "%d"}}`, i, i, i, 3*i, testMarketIssuer, 3*i))
+			}
+		}
+		q.Set("cursor", "2")
+		fmt.Fprintf(w, `{"_links": {"next": {"href": %q}}, "_embedded": {"records": [%s]}}`, h.url+r.URL.Path+"?"+q.Encode(), strings.Join(records, ","))
+	case "/liquidity_pools/a75f810b19fd77af3955da980679db82dacb4264a0062493d08fe935aab4993e":
+		if h.poolUSD == "" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status": 404, "title": "Resource Missing"}`))
+			return
+		}
+		fmt.Fprintf(w, `{"id": %q, "fee_bp": 30, "type": "constant_product", "total_trustlines": "1", "total_shares": "100.0000000",
+			"reserves": [{"asset": "native", "amount": "1000.0000000"}, {"asset": "USD:%s", "amount": %q}]}`,
+			strings.TrimPrefix(r.URL.Path, "/liquidity_pools/"), testMarketIssuer, h.poolUSD)
+	case "/trade_aggregations":
+		fmt.Fprint(w, `{"_embedded": {"records": [{"timestamp": "1577836800000", "trade_count": "2", "base_volume": "9.0000000", "counter_volume": "2.0000000",
+			"avg": "0.2222222", "high": "0.3333333", "high_r": {"N": 1, "D": 3}, "low": "0.1666667", "low_r": {"N": 1, "D": 6},
+			"open": "0.3333333", "open_r": {"N": 1, "D": 3}, "close": "0.1666667", "close_r": {"N": 1, "D": 6}}]}}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status": 404, "title": "Resource Missing"}`))
+	}
+}
+
+func withMarketHorizon(t *testing.T) (*marketHorizon, func()) {
+	fake := &marketHorizon{}
+	url, restore := withFakeHorizon(t, fake)
+	fake.url = url
+	return fake, restore
+}
+
+func TestOrderBook(t *testing.T) {
+	fake, done := withMarketHorizon(t)
+	defer done()
+
+	book, err := OrderBook(AssetMinimal{}, testMarketUSD, 5)
+	require.NoError(t, err)
+	require.Contains(t, fake.requests[0], "limit=5")
+	require.Equal(t, AssetMinimal{AssetType: "native"}, book.Selling)
+	require.Equal(t, testMarketUSD, book.Buying)
+	require.Len(t, book.Bids, 1)
+	require.Equal(t, big.NewRat(1, 4), book.Bids[0].Price)
+	require.Equal(t, "50.0000000", book.Bids[0].Amount)
+	require.Len(t, book.Asks, 2)
+	// the fraction is exact, the decimal is the fallback
+	require.Equal(t, big.NewRat(1, 3), book.Asks[0].Price)
+	require.Equal(t, big.NewRat(1, 2), book.Asks[1].Price)
+
+	_, err = OrderBook(testMarketUSD, AssetMinimal{}, 0)
+	require.Error(t, err)
+}
+
+func TestTrades(t *testing.T) {
+	fake, done := withMarketHorizon(t)
+	defer done()
+
+	trades, err := Trades(TradeFilter{Base: AssetMinimal{}, Counter: testMarketUSD}, HistoryOptions{Order: "asc"})
+	require.NoError(t, err)
+	require.Len(t, trades, 2)
+	require.Equal(t, big.NewRat(1, 6), trades[1].PriceRat())
+	require.Equal(t, AssetMinimal{AssetType: "native"}, trades[1].BaseAsset())
+	require.Equal(t, testMarketUSD, trades[1].CounterAsset())
+	require.Contains(t, fake.requests[0], "base_asset_type=native")
+	require.Contains(t, fake.requests[0], "counter_asset_code=USD")
+
+	trades, err = Trades(TradeFilter{Account: "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO"},
+		HistoryOptions{Order: "asc", StopTime: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	require.Len(t, trades, 1)
+
+	_, err = Trades(TradeFilter{OfferID: "7"}, HistoryOptions{})
+	require.NoError(t, err)
+	require.Contains(t, fake.requests[len(fake.requests)-1], "offer_id=7")
+
+	_, err = Trades(TradeFilter{Account: "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", OfferID: "7"}, HistoryOptions{})
+	require.Equal(t, ErrInvalidParameter{Key: "account"}, err)
+	_, err = Trades(TradeFilter{Base: AssetMinimal{}}, HistoryOptions{})
+	require.Equal(t, ErrInvalidParameter{Key: "counter"}, err)
+}
+
+func TestTradeAggregations(t *testing.T) {
+	fake, done := withMarketHorizon(t)
+	defer done()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	aggs, err := TradeAggregations(TradeAggregationsArg{
+		Base:       AssetMinimal{},
+		Counter:    testMarketUSD,
+		Start:      start,
+		End:        start.Add(24 * time.Hour),
+		Resolution: time.Hour,
+	})
+	require.NoError(t, err)
+	require.Contains(t, fake.requests[0], "resolution=3600000")
+	require.Contains(t, fake.requests[0], "start_time=1577836800000")
+	require.Contains(t, fake.requests[0], "end_time=1577923200000")
+	require.Len(t, aggs, 1)
+	require.Equal(t, start, aggs[0].Time)
+	require.Equal(t, int64(2), aggs[0].TradeCount)
+	require.Equal(t, big.NewRat(1, 3), aggs[0].High)
+	require.Equal(t, big.NewRat(1, 6), aggs[0].Low)
+	require.Equal(t, big.NewRat(1, 3), aggs[0].Open)
+	require.Equal(t, big.NewRat(1, 6), aggs[0].Close)
+	require.Equal(t, big.NewRat(1111111, 5000000), aggs[0].Average)
+
+	_, err = TradeAggregations(TradeAggregationsArg{Base: AssetMinimal{}, Counter: testMarketUSD, Resolution: 2 * time.Hour})
+	require.Equal(t, ErrInvalidParameter{Key: "resolution"}, err)
+	_, err = TradeAggregations(TradeAggregationsArg{Base: AssetMinimal{}, Counter: testMarketUSD, Resolution: time.Hour, Offset: time.Hour})
+	require.Equal(t, ErrInvalidParameter{Key: "offset"}, err)
+	_, err = TradeAggregations(TradeAggregationsArg{Base: AssetMinimal{}, Resolution: time.Hour})
+	require.Equal(t, ErrMissingParameter{Key: "counter"}, err)
+}
@@ -0,0 +1,159 @@
+package stellarnet
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/xdr"
+)
+
+// memoRequiredDataKey is the account data entry that SEP29 uses to
+// flag accounts that need a memo on incoming payments.
+const memoRequiredDataKey = "config.memo_required"
+
+// memoRequiredCacheTTL is how long the result of a memo required
+// lookup is cached.
+const memoRequiredCacheTTL = 10 * time.Minute
+
+// ErrAccountRequiresMemo is returned when a transaction without a memo
+// sends funds to an account that requires one (SEP29).
+type ErrAccountRequiresMemo struct {
+	AccountID AddressStr
+	OpIndex   int
+}
+
+// Error implements error for ErrAccountRequiresMemo.
+func (e ErrAccountRequiresMemo) Error() string {
+	return fmt.Sprintf("destination account %s of operation %d requires a memo", e.AccountID, e.OpIndex)
+}
+
+type memoRequiredEntry struct {
+	required bool
+	expires  time.Time
+}
+
+var memoRequiredLock sync.Mutex
+var memoRequiredCheck = true
+var memoRequiredCache = make(map[AddressStr]memoRequiredEntry)
+
+// SetMemoRequiredCheck turns the SEP29 memo required check in Submit on
+// or off.  It is on by default.
+func SetMemoRequiredCheck(enabled bool) {
+	memoRequiredLock.Lock()
+	defer memoRequiredLock.Unlock()
+	memoRequiredCheck = enabled
+}
+
+func memoRequiredCheckEnabled() bool {
+	memoRequiredLock.Lock()
+	defer memoRequiredLock.Unlock()
+	return memoRequiredCheck
+}
+
+// ClearMemoRequiredCache empties the cache of memo required lookups.
+func ClearMemoRequiredCache() {
+	memoRequiredLock.Lock()
+	defer memoRequiredLock.Unlock()
+	memoRequiredCache = make(map[AddressStr]memoRequiredEntry)
+}
+
+// AccountRequiresMemo returns true if the account has the SEP29
+// config.memo_required data entry set.  Accounts that don't exist
+// don't require a memo.  Results are cached for a short time.
+func AccountRequiresMemo(address AddressStr) (bool, error) {
+	memoRequiredLock.Lock()
+	cached, ok := memoRequiredCache[address]
+	memoRequiredLock.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.required, nil
+	}
+
+	// the data entry endpoint is 404 for accounts without the entry,
+	// and for accounts that don't exist
+	link, err := horizonLink(Client().HorizonURL, "/accounts/"+address.String()+"/data/"+memoRequiredDataKey)
+	if err != nil {
+		return false, errMap(err)
+	}
+	var entry struct {
+		Value string `json:"value"`
+	}
+	var required bool
+	if err := getDecodeJSONStrict(link, Client().HTTP.Get, &entry); err == nil {
+		decoded, err := base64.StdEncoding.DecodeString(entry.Value)
+		required = err == nil && string(decoded) == "1"
+	} else if err != ErrResourceNotFound {
+		return false, err
+	}
+
+	memoRequiredLock.Lock()
+	memoRequiredCache[address] = memoRequiredEntry{required: required, expires: time.Now().Add(memoRequiredCacheTTL)}
+	memoRequiredLock.Unlock()
+
+	return required, nil
+}
+
+// CheckMemoRequired checks that none of the payment, path payment or
+// account merge destinations in a transaction without a memo require
+// one (SEP29).  It returns ErrAccountRequiresMemo naming the first
+// offending operation.
+//
+// Muxed (M...) destinations are skipped since they already identify
+// the recipient.
+func CheckMemoRequired(txEnv xdr.TransactionEnvelope) error {
+	if txEnv.IsFeeBump() {
+		inner := xdr.TransactionEnvelope{Type: xdr.EnvelopeTypeEnvelopeTypeTx, V1: txEnv.FeeBump.Tx.InnerTx.V1}
+		return CheckMemoRequired(inner)
+	}
+	return checkMemoRequired(txEnv.Memo(), txEnv.Operations())
+}
+
+// CheckMemoRequired runs the SEP29 memo required check (see
+// CheckMemoRequired) on the transaction being built.
+func (t *Tx) CheckMemoRequired() error {
+	if t.err != nil {
+		return errMap(t.err)
+	}
+	return checkMemoRequired(t.internal.Memo, t.internal.Operations)
+}
+
+func checkMemoRequired(memo xdr.Memo, ops []xdr.Operation) error {
+	if memo.Type != xdr.MemoTypeMemoNone {
+		return nil
+	}
+
+	checked := make(map[AddressStr]bool)
+	for i, op := range ops {
+		var dest *xdr.MuxedAccount
+		switch op.Body.Type {
+		case xdr.OperationTypePayment:
+			dest = &op.Body.PaymentOp.Destination
+		case xdr.OperationTypePathPaymentStrictReceive:
+			dest = &op.Body.PathPaymentStrictReceiveOp.Destination
+		case xdr.OperationTypePathPaymentStrictSend:
+			dest = &op.Body.PathPaymentStrictSendOp.Destination
+		case xdr.OperationTypeAccountMerge:
+			dest = op.Body.Destination
+		}
+		if dest == nil || dest.Type != xdr.CryptoKeyTypeKeyTypeEd25519 {
+			continue
+		}
+
+		address := AddressStr(dest.Address())
+		if checked[address] {
+			continue
+		}
+		checked[address] = true
+
+		required, err := AccountRequiresMemo(address)
+		if err != nil {
+			return err
+		}
+		if required {
+			return ErrAccountRequiresMemo{AccountID: address, OpIndex: i}
+		}
+	}
+
+	return nil
+}
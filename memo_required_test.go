@@ -0,0 +1,118 @@
+package stellarnet
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+// memoRequiredHorizon is a fake horizon server that knows about two
+// accounts, one of which requires a memo.
+type memoRequiredHorizon struct {
+	sync.Mutex
+	required  string
+	plain     string
+	lookups   int
+	submitted int
+}
+
+func (h *memoRequiredHorizon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Lock()
+	defer h.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/transactions":
+		h.submitted++
+		w.Write([]byte(`{"hash": "abcd", "ledger": 10}`))
+	case strings.HasPrefix(r.URL.Path, "/accounts/") && strings.HasSuffix(r.URL.Path, "/data/config.memo_required"):
+		h.lookups++
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/accounts/"), "/data/config.memo_required")
+		if id == h.required {
+			w.Write([]byte(`{"value": "MQ=="}`))
+			return
+		}
+		// the plain account exists without the entry, the others
+		// don't exist
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status": 404, "title": "Resource Missing"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestCheckMemoRequired(t *testing.T) {
+	fake := &memoRequiredHorizon{
+		required: keypair.MustRandom().Address(),
+		plain:    keypair.MustRandom().Address(),
+	}
+	_, restore := withFakeHorizon(t, fake)
+	defer restore()
+	ClearMemoRequiredCache()
+	defer ClearMemoRequiredCache()
+
+	source := keypair.MustRandom()
+	required := AddressStr(fake.required)
+	plain := AddressStr(fake.plain)
+	missing := addressStr(t, keypair.MustRandom())
+
+	tx := NewBaseTx(addressStr(t, source), &testSeqnoProv{}, txnbuild.MinBaseFee)
+	tx.AddPaymentOp(plain, "1")
+	tx.AddPaymentOp(missing, "1")
+	tx.AddPaymentOp(required, "1")
+	err := tx.CheckMemoRequired()
+	require.Equal(t, ErrAccountRequiresMemo{AccountID: required, OpIndex: 2}, err)
+	require.Equal(t, 3, fake.lookups)
+
+	// lookups are cached
+	err = tx.CheckMemoRequired()
+	require.Equal(t, ErrAccountRequiresMemo{AccountID: required, OpIndex: 2}, err)
+	require.Equal(t, 3, fake.lookups)
+
+	// a memo satisfies the check
+	tx.AddMemoID(new(uint64))
+	require.NoError(t, tx.CheckMemoRequired())
+
+	// account merge
+	tx = NewBaseTx(addressStr(t, source), &testSeqnoProv{}, txnbuild.MinBaseFee)
+	tx.AddAccountMergeOp(required)
+	require.Equal(t, ErrAccountRequiresMemo{AccountID: required, OpIndex: 0}, tx.CheckMemoRequired())
+
+	// muxed destinations are skipped
+	muxed, err := xdr.MuxedAccountFromAccountId(required.String(), 12)
+	require.NoError(t, err)
+	txEnv := xdr.TransactionEnvelope{
+		Type: xdr.EnvelopeTypeEnvelopeTypeTx,
+		V1: &xdr.TransactionV1Envelope{
+			Tx: xdr.Transaction{
+				SourceAccount: xdr.MustMuxedAddress(source.Address()),
+				Operations: []xdr.Operation{
+					{Body: xdr.OperationBody{Type: xdr.OperationTypePayment, PaymentOp: &xdr.PaymentOp{Destination: muxed, Asset: xdr.MustNewNativeAsset(), Amount: 1}}},
+				},
+			},
+		},
+	}
+	require.NoError(t, CheckMemoRequired(txEnv))
+
+	// Submit checks unless it is turned off
+	tx = NewBaseTx(addressStr(t, source), &testSeqnoProv{}, txnbuild.MinBaseFee)
+	tx.AddPaymentOp(required, "1")
+	sig, err := tx.Sign(seedStr(t, source))
+	require.NoError(t, err)
+	_, err = Submit(sig.Signed)
+	require.Equal(t, ErrAccountRequiresMemo{AccountID: required, OpIndex: 0}, err)
+	require.Equal(t, 0, fake.submitted)
+
+	SetMemoRequiredCheck(false)
+	defer SetMemoRequiredCheck(true)
+
+	res, err := Submit(sig.Signed)
+	require.NoError(t, err)
+	require.Equal(t, "abcd", res.TxID)
+	require.Equal(t, 1, fake.submitted)
+}
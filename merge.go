@@ -0,0 +1,552 @@
+package stellarnet
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	horizonProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+// UntrustedAssetAction is what PlanAccountMerge does with a balance of
+// an asset the destination can't receive.
+type UntrustedAssetAction int
+
+const (
+	// MergeUntrustedFail makes PlanAccountMerge return an error.
+	MergeUntrustedFail UntrustedAssetAction = iota
+	// MergeUntrustedSellForXLM sells the balance for lumens with a path
+	// payment, and the lumens are merged.
+	MergeUntrustedSellForXLM
+	// MergeUntrustedClaimableBalance puts the balance in a claimable
+	// balance for the destination.  The destination sponsors it, so it
+	// must exist and sign (MergeOptions.DestinationSeed).
+	MergeUntrustedClaimableBalance
+)
+
+// The actions of a MergePayment.
+const (
+	MergeActionPayment          = "payment"
+	MergeActionPathPayment      = "path_payment"
+	MergeActionClaimableBalance = "claimable_balance"
+)
+
+// defaultMergeSlippage is the fraction below the best path that
+// MergeUntrustedSellForXLM accepts.
+var defaultMergeSlippage = big.NewRat(1, 100)
+
+// mergeMaxOps is the most operations in a transaction.
+const mergeMaxOps = 100
+
+// mergeStartingBalance is the starting balance of a destination that
+// doesn't exist, like AccountMergeTransaction.
+const mergeStartingBalance = "1"
+
+// MergeOptions configures PlanAccountMerge.
+type MergeOptions struct {
+	Untrusted UntrustedAssetAction
+	// Slippage is the fraction below the best path's amount accepted
+	// when selling for lumens.  Default is 1%.
+	Slippage *big.Rat
+	// DestinationSeed signs the transactions that create claimable
+	// balances, which the destination sponsors because a merged account
+	// can't.
+	DestinationSeed SeedStr
+	// MemoText is the memo of every transaction.  Default is the same
+	// as AccountMergeTransaction's.
+	MemoText      string
+	SeqnoProvider SequenceProvider
+	TimeBounds    *txnbuild.Timebounds
+	// BaseFee is raised to the network's minimum (see MinBaseFee).
+	// Zero lets the FeeStrategy pick it.
+	BaseFee uint64
+}
+
+// MergePayment is how a non-native balance leaves the merged account.
+type MergePayment struct {
+	Asset  AssetMinimal
+	Amount string
+	Action string
+	// XLM is the minimum lumens received for a path payment.
+	XLM string
+	// Path is the path of a path payment.
+	Path []AssetBase
+}
+
+// MergePlan is everything that has to happen to merge an account.
+type MergePlan struct {
+	From              AddressStr
+	To                AddressStr
+	CreateDestination bool
+	// RevokedSponsorships is the number of entries of other accounts
+	// the account stops sponsoring.
+	RevokedSponsorships int
+	CanceledOffers      []int64
+	Payments            []MergePayment
+	DeletedTrustlines   []Trustline
+	DeletedData         []string
+	RemovedSigners      []string
+	// Fees is the total fee of Transactions.
+	Fees string
+	// MergeAmount is the (minimum) amount of lumens the merge operation
+	// sends to the destination.
+	MergeAmount string
+	// Transactions are signed and must be submitted in order.
+	Transactions []SignResult
+}
+
+// mergeUnit is operations that have to be in the same transaction.
+type mergeUnit struct {
+	ops int
+	add func(t *Tx)
+	// destSigns is true if the destination must sign.
+	destSigns bool
+}
+
+// PlanAccountMerge plans merging from into to.  Unlike
+// AccountMergeTransaction, it cancels the account's offers, removes its
+// data entries and signers, revokes the sponsorships of entries it
+// sponsors, deals with assets the destination can't receive as opts
+// says, and splits the operations into several transactions if they
+// don't fit in one.
+func PlanAccountMerge(from SeedStr, to AddressStr, opts MergeOptions) (*MergePlan, error) {
+	fromAddr, err := from.Address()
+	if err != nil {
+		return nil, err
+	}
+	if fromAddr == to {
+		return nil, ErrInvalidParameter{Key: "to"}
+	}
+	if opts.SeqnoProvider == nil {
+		opts.SeqnoProvider = Client()
+	}
+	if opts.Slippage == nil {
+		opts.Slippage = defaultMergeSlippage
+	}
+	if opts.MemoText == "" {
+		opts.MemoText = defaultMemo
+	}
+	fromAccount := NewAccount(fromAddr)
+	if err := fromAccount.load(); err != nil {
+		return nil, err
+	}
+	acct := fromAccount.internal
+	toAccount := NewAccount(to)
+	toExists := true
+	if err := toAccount.load(); err == ErrSourceAccountNotFound {
+		toExists = false
+	} else if err != nil {
+		return nil, err
+	}
+
+	plan := &MergePlan{From: fromAddr, To: to, CreateDestination: !toExists}
+	var units []mergeUnit
+	add := func(ops int, destSigns bool, f func(t *Tx)) {
+		units = append(units, mergeUnit{ops: ops, add: f, destSigns: destSigns})
+	}
+	if !toExists {
+		add(1, false, func(t *Tx) { t.AddCreateAccountOp(to, mergeStartingBalance) })
+	}
+
+	// stop sponsoring other accounts' entries
+	if acct.NumSponsoring > 0 {
+		revokes, reserves, err := mergeSponsorshipRevokes(fromAddr)
+		if err != nil {
+			return nil, err
+		}
+		if reserves < acct.NumSponsoring {
+			return nil, fmt.Errorf("account sponsors %d reserves that can't be revoked (claimable balances or data entries)", acct.NumSponsoring-reserves)
+		}
+		for _, r := range revokes {
+			add(1, false, r)
+		}
+		plan.RevokedSponsorships = len(revokes)
+	}
+
+	// cancel offers so nothing is locked in liabilities
+	offers := fromAccount.OfferIterator(HistoryOptions{Order: "asc"})
+	for offers.Next() {
+		o := offers.Offer()
+		selling, err := horizonAssetToXDR(o.Selling)
+		if err != nil {
+			return nil, err
+		}
+		buying, err := horizonAssetToXDR(o.Buying)
+		if err != nil {
+			return nil, err
+		}
+		id := o.ID
+		add(1, false, func(t *Tx) { t.AddCancelOfferOp(selling, buying, id) })
+		plan.CanceledOffers = append(plan.CanceledOffers, id)
+	}
+	if err := offers.Err(); err != nil {
+		return nil, err
+	}
+
+	// move the non-native balances out and delete the trustlines
+	var xlmIn int64
+	// pool share trustlines are deleted before the asset trustlines
+	// they depend on, whatever order horizon lists them in.
+	var poolDeletes, deletes []mergeUnit
+	var poolDeleted, deleted []Trustline
+	for _, b := range acct.Balances {
+		if b.Type == "native" {
+			continue
+		}
+		if b.LiquidityPoolId != "" {
+			if !balanceIsZero(b.Balance) {
+				return nil, fmt.Errorf("withdraw from liquidity pool %s before merging", b.LiquidityPoolId)
+			}
+			pool, err := LiquidityPoolDetails(b.LiquidityPoolId)
+			if err != nil {
+				return nil, err
+			}
+			assets, err := pool.ReserveAssets()
+			if err != nil {
+				return nil, err
+			}
+			if len(assets) != 2 {
+				return nil, fmt.Errorf("liquidity pool %s does not have two reserves", b.LiquidityPoolId)
+			}
+			poolDeletes = append(poolDeletes, mergeUnit{ops: 1, add: func(t *Tx) { t.AddDeletePoolShareTrustlineOp(assets[0], assets[1]) }})
+			poolDeleted = append(poolDeleted, Trustline{Asset: b.Asset, Limit: b.Limit, LiquidityPoolID: b.LiquidityPoolId})
+			continue
+		}
+
+		asset, err := NewAssetMinimal(b.Code, b.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		issuer, err := NewAddressStr(b.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		code := b.Code
+		deletes = append(deletes, mergeUnit{ops: 1, add: func(t *Tx) { t.AddDeleteTrustlineOp(code, issuer) }})
+		deleted = append(deleted, Trustline{Asset: b.Asset, Limit: b.Limit})
+		if balanceIsZero(b.Balance) {
+			continue
+		}
+		if b.IsAuthorized != nil && !*b.IsAuthorized {
+			return nil, fmt.Errorf("cannot move %s:%s balance, the trustline is not authorized", b.Code, b.Issuer)
+		}
+
+		payment := MergePayment{Asset: asset, Amount: b.Balance, Action: MergeActionPayment}
+		if !mergeCanReceive(toAccount.internal, asset, b.Balance) {
+			switch opts.Untrusted {
+			case MergeUntrustedSellForXLM:
+				path, min, err := mergeSellPath(asset, b.Balance, opts.Slippage)
+				if err != nil {
+					return nil, err
+				}
+				payment.Action = MergeActionPathPayment
+				payment.XLM = StringFromStellarAmount(min)
+				payment.Path = path
+				xlmIn += min
+			case MergeUntrustedClaimableBalance:
+				if !toExists {
+					return nil, fmt.Errorf("destination must exist to sponsor a claimable balance of %s:%s", b.Code, b.Issuer)
+				}
+				if opts.DestinationSeed == "" {
+					return nil, ErrMissingParameter{Key: "destination_seed"}
+				}
+				payment.Action = MergeActionClaimableBalance
+			default:
+				return nil, fmt.Errorf("cannot merge %s:%s asset into an account without a trustline", asset.CodeString(), asset.IssuerString())
+			}
+		}
+		plan.Payments = append(plan.Payments, payment)
+		p := payment
+		switch p.Action {
+		case MergeActionPayment:
+			add(1, false, func(t *Tx) {
+				x, err := assetBaseToXDR(p.Asset)
+				if err != nil {
+					t.err = err
+					return
+				}
+				t.AddAssetPaymentOp(to, x, p.Amount)
+			})
+		case MergeActionPathPayment:
+			add(1, false, func(t *Tx) {
+				t.AddPathPaymentStrictSendOp(fromAddr, p.Asset, p.Amount, AssetMinimal{AssetType: "native"}, p.XLM, p.Path)
+			})
+		case MergeActionClaimableBalance:
+			add(3, true, func(t *Tx) {
+				t.AddBeginSponsoringOp(fromAddr)
+				t.setOpSource(to)
+				t.AddCreateClaimableBalanceOp(to, p.Asset, p.Amount)
+				t.AddEndSponsoringOp()
+			})
+		}
+	}
+	units = append(units, poolDeletes...)
+	units = append(units, deletes...)
+	plan.DeletedTrustlines = append(poolDeleted, deleted...)
+
+	// remove the other subentries
+	var names []string
+	for name := range acct.Data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		n := name
+		add(1, false, func(t *Tx) { t.AddDeleteDataOp(n) })
+	}
+	plan.DeletedData = names
+	for _, s := range acct.Signers {
+		if s.Key == acct.AccountID {
+			continue
+		}
+		key := s.Key
+		add(1, false, func(t *Tx) { t.AddRemoveSignerOp(key) })
+		plan.RemovedSigners = append(plan.RemovedSigners, key)
+	}
+	add(1, false, func(t *Tx) { t.AddAccountMergeOp(to) })
+
+	// pack the units into transactions, keeping the merge last
+	var txs [][]mergeUnit
+	var ops int
+	for _, u := range units {
+		if len(txs) == 0 || ops+u.ops > mergeMaxOps {
+			txs = append(txs, nil)
+			ops = 0
+		}
+		txs[len(txs)-1] = append(txs[len(txs)-1], u)
+		ops += u.ops
+	}
+
+	baseFee := opts.BaseFee
+	if baseFee == 0 {
+		baseFee = strategyBaseFee()
+	}
+	if min := MinBaseFee(); baseFee < min {
+		baseFee = min
+	}
+	var fees int64
+	for i, batch := range txs {
+		t := NewBaseTx(fromAddr, seqnoOffset{opts.SeqnoProvider, int64(i)}, baseFee)
+		signers := []SeedStr{from}
+		for _, u := range batch {
+			u.add(t)
+			if u.destSigns && len(signers) == 1 {
+				signers = append(signers, opts.DestinationSeed)
+			}
+		}
+		t.AddMemoText(opts.MemoText)
+		t.AddBuiltTimeBounds(opts.TimeBounds)
+		if t.err != nil {
+			return nil, errMap(t.err)
+		}
+		sig, err := t.sign(signers...)
+		if err != nil {
+			return nil, err
+		}
+		plan.Transactions = append(plan.Transactions, sig)
+		fees += int64(t.internal.Fee)
+	}
+	plan.Fees = StringFromStellarAmount(fees)
+
+	native, err := ParseStellarAmount(fromAccount.internalNativeBalance())
+	if err != nil {
+		return nil, err
+	}
+	merge := native - fees + xlmIn
+	if !toExists {
+		start, _ := ParseStellarAmount(mergeStartingBalance)
+		merge -= start
+	}
+	if merge < 0 {
+		return nil, fmt.Errorf("account can't pay the %s XLM of fees", plan.Fees)
+	}
+	plan.MergeAmount = StringFromStellarAmount(merge)
+
+	return plan, nil
+}
+
+// Submit submits the plan's transactions in order, stopping at the
+// first error.
+func (p *MergePlan) Submit() ([]SubmitResult, error) {
+	var results []SubmitResult
+	for _, tx := range p.Transactions {
+		res, err := Submit(tx.Signed)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// mergeSponsorshipRevokes returns the operations that revoke the
+// sponsorships by sponsor that can be found on horizon, and the number
+// of reserves they release.
+func mergeSponsorshipRevokes(sponsor AddressStr) (revokes []func(t *Tx), reserves uint32, err error) {
+	offers := newOfferIterator("/offers?sponsor="+sponsor.String(), HistoryOptions{Order: "asc"})
+	for offers.Next() {
+		o := offers.Offer()
+		seller, err := NewAddressStr(o.Seller)
+		if err != nil {
+			return nil, 0, err
+		}
+		var key xdr.LedgerKey
+		if err := key.SetOffer(xdr.MustAddress(seller.String()), uint64(o.ID)); err != nil {
+			return nil, 0, err
+		}
+		revokes = append(revokes, func(t *Tx) { t.AddRevokeSponsorshipOp(key) })
+		reserves++
+	}
+	if err := offers.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	it := newHistoryIterator("/accounts?sponsor="+sponsor.String(), HistoryOptions{Order: "asc"},
+		func() historyPage { return &AccountsPage{} }, nil)
+	for it.next() {
+		a := it.page.(*AccountsPage).Embedded.Records[it.index]
+		owner, err := NewAddressStr(a.AccountID)
+		if err != nil {
+			return nil, 0, err
+		}
+		id := xdr.MustAddress(owner.String())
+		if a.Sponsor == sponsor.String() {
+			var key xdr.LedgerKey
+			if err := key.SetAccount(id); err != nil {
+				return nil, 0, err
+			}
+			revokes = append(revokes, func(t *Tx) { t.AddRevokeSponsorshipOp(key) })
+			reserves += 2
+		}
+		for _, b := range a.Balances {
+			if b.Sponsor != sponsor.String() {
+				continue
+			}
+			var line xdr.TrustLineAsset
+			if b.LiquidityPoolId != "" {
+				poolID, err := parsePoolID(b.LiquidityPoolId)
+				if err != nil {
+					return nil, 0, err
+				}
+				line = xdr.TrustLineAsset{Type: xdr.AssetTypeAssetTypePoolShare, LiquidityPoolId: &poolID}
+				reserves += 2
+			} else {
+				asset, err := horizonAssetToXDR(horizonProtocol.Asset(b.Asset))
+				if err != nil {
+					return nil, 0, err
+				}
+				line = asset.ToTrustLineAsset()
+				reserves++
+			}
+			var key xdr.LedgerKey
+			if err := key.SetTrustline(id, line); err != nil {
+				return nil, 0, err
+			}
+			revokes = append(revokes, func(t *Tx) { t.AddRevokeSponsorshipOp(key) })
+		}
+		for _, s := range a.Signers {
+			if s.Sponsor != sponsor.String() {
+				continue
+			}
+			signerKey := s.Key
+			revokes = append(revokes, func(t *Tx) { t.AddRevokeSignerSponsorshipOp(owner, signerKey) })
+			reserves++
+		}
+	}
+	if it.err != nil {
+		return nil, 0, it.err
+	}
+	return revokes, reserves, nil
+}
+
+// mergeCanReceive returns true if to can receive amount of asset.
+func mergeCanReceive(to *horizonProtocol.Account, asset AssetMinimal, amount string) bool {
+	if to == nil {
+		return false
+	}
+	if to.AccountID == asset.AssetIssuer {
+		// payments to the issuer burn the asset
+		return true
+	}
+	for _, b := range to.Balances {
+		if b.Code != asset.AssetCode || b.Issuer != asset.AssetIssuer {
+			continue
+		}
+		if b.IsAuthorized != nil && !*b.IsAuthorized {
+			return false
+		}
+		limit, err := ParseStellarAmount(b.Limit)
+		if err != nil {
+			return false
+		}
+		balance, err := ParseStellarAmount(b.Balance)
+		if err != nil {
+			return false
+		}
+		var liabilities int64
+		if b.BuyingLiabilities != "" {
+			if liabilities, err = ParseStellarAmount(b.BuyingLiabilities); err != nil {
+				return false
+			}
+		}
+		amt, err := ParseStellarAmount(amount)
+		return err == nil && amt <= limit-balance-liabilities
+	}
+	return false
+}
+
+// mergeSellPath returns the best path to sell amount of asset for
+// lumens and the minimum lumens to accept.
+func mergeSellPath(asset AssetMinimal, amount string, slippage *big.Rat) ([]AssetBase, int64, error) {
+	paths, err := FindStrictSendPaths(asset, amount, []AssetBase{AssetMinimal{AssetType: "native"}})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(paths) == 0 {
+		return nil, 0, fmt.Errorf("no path to sell %s %s:%s for XLM", amount, asset.AssetCode, asset.AssetIssuer)
+	}
+	best := paths[0]
+	for _, p := range paths[1:] {
+		if c, err := CompareStellarAmounts(p.DestinationAmount, best.DestinationAmount); err == nil && c > 0 {
+			best = p
+		}
+	}
+	xlm, err := ParseStellarAmount(best.DestinationAmount)
+	if err != nil {
+		return nil, 0, err
+	}
+	// floor(xlm * (1 - slippage))
+	min := new(big.Rat).Sub(big.NewRat(1, 1), slippage)
+	min.Mul(min, big.NewRat(xlm, 1))
+	v := new(big.Int).Quo(min.Num(), min.Denom())
+	return PathAssetSliceToAssetBase(best.Path), v.Int64(), nil
+}
+
+func horizonAssetToXDR(a horizonProtocol.Asset) (xdr.Asset, error) {
+	if a.Type == "native" {
+		return xdr.NewAsset(xdr.AssetTypeAssetTypeNative, nil)
+	}
+	return assetBaseToXDR(AssetMinimal{AssetType: a.Type, AssetCode: a.Code, AssetIssuer: a.Issuer})
+}
+
+func balanceIsZero(s string) bool {
+	n, err := ParseStellarAmount(s)
+	return err == nil && n == 0
+}
+
+// seqnoOffset returns sequence numbers offset past the provider's, for
+// transactions that are submitted after others from the same account.
+type seqnoOffset struct {
+	SequenceProvider
+	offset int64
+}
+
+func (s seqnoOffset) SequenceForAccount(aid string) (int64, error) {
+	n, err := s.SequenceProvider.SequenceForAccount(aid)
+	if err != nil {
+		return 0, err
+	}
+	return n + s.offset, nil
+}
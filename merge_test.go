@@ -0,0 +1,294 @@
+package stellarnet
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+// mergeHorizon is a fake horizon for PlanAccountMerge.
+type mergeHorizon struct {
+	sync.Mutex
+	accounts          map[string]string
+	offers            map[string]string
+	sponsoredAccounts string
+	sponsoredOffers   string
+	paths             string
+	pools             map[string]string
+	envelopes         []xdr.TransactionEnvelope
+}
+
+func mergePage(records string) string {
+	return `{"_links": {"next": {"href": ""}}, "_embedded": {"records": [` + records + `]}}`
+}
+
+func (h *mergeHorizon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Lock()
+	defer h.Unlock()
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/transactions":
+		var env xdr.TransactionEnvelope
+		if err := xdr.SafeUnmarshalBase64(r.FormValue("tx"), &env); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		h.envelopes = append(h.envelopes, env)
+		fmt.Fprintf(w, `{"hash": "tx%d", "ledger": %d}`, len(h.envelopes), 100+len(h.envelopes))
+		return
+	case r.URL.Path == "/accounts" && r.URL.Query().Get("sponsor") != "":
+		fmt.Fprint(w, mergePage(h.sponsoredAccounts))
+		return
+	case r.URL.Path == "/offers" && r.URL.Query().Get("sponsor") != "":
+		fmt.Fprint(w, mergePage(h.sponsoredOffers))
+		return
+	case r.URL.Path == "/paths/strict-send":
+		fmt.Fprint(w, mergePage(h.paths))
+		return
+	case strings.HasPrefix(r.URL.Path, "/liquidity_pools/"):
+		if pool, ok := h.pools[strings.TrimPrefix(r.URL.Path, "/liquidity_pools/")]; ok {
+			fmt.Fprint(w, pool)
+			return
+		}
+	case strings.HasSuffix(r.URL.Path, "/offers"):
+		fmt.Fprint(w, mergePage(h.offers[strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/accounts/"), "/offers")]))
+		return
+	}
+	if account, ok := h.accounts[strings.TrimPrefix(r.URL.Path, "/accounts/")]; ok {
+		fmt.Fprint(w, account)
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte(`{"status": 404, "title": "Resource Missing"}`))
+}
+
+func mergeAccount(address, balances, extra string) string {
+	return fmt.Sprintf(`{"id": %q, "account_id": %q, "sequence": "100", "signers": [{"key": %q, "weight": 1, "type": "ed25519_public_key"}],
+		"balances": [%s{"balance": "100.0000000", "asset_type": "native"}]%s}`, address, address, address, balances, extra)
+}
+
+func mergeTrustline(code, issuer, balance string) string {
+	return fmt.Sprintf(`{"balance": %q, "limit": "1000.0000000", "asset_type": "credit_alphanum4", "asset_code": %q, "asset_issuer": %q, "is_authorized": true}, `,
+		balance, code, issuer)
+}
+
+func withMergeHorizon(t *testing.T, fake *mergeHorizon) func() {
+	_, restore := withFakeHorizon(t, fake)
+	return restore
+}
+
+func opTypes(env xdr.TransactionEnvelope) []xdr.OperationType {
+	var types []xdr.OperationType
+	for _, op := range env.Operations() {
+		types = append(types, op.Body.Type)
+	}
+	return types
+}
+
+func TestPlanAccountMerge(t *testing.T) {
+	from := keypair.MustRandom()
+	to := keypair.MustRandom()
+	issuer := keypair.MustRandom().Address()
+	other := keypair.MustRandom().Address()
+	signer := keypair.MustRandom().Address()
+	value := base64.StdEncoding.EncodeToString([]byte("v"))
+
+	fake := &mergeHorizon{
+		accounts: map[string]string{
+			from.Address(): strings.Replace(mergeAccount(from.Address(),
+				mergeTrustline("USD", issuer, "10.0000000")+mergeTrustline("EUR", issuer, "5.0000000")+mergeTrustline("JPY", issuer, "0.0000000"),
+				fmt.Sprintf(`, "num_sponsoring": 3, "data": {"b": %q, "a": %q}`, value, value)),
+				`"signers": [`, fmt.Sprintf(`"signers": [{"key": %q, "weight": 1, "type": "ed25519_public_key"}, `, signer), 1),
+			to.Address(): mergeAccount(to.Address(), mergeTrustline("USD", issuer, "0.0000000"), ""),
+		},
+		offers: map[string]string{
+			from.Address(): fmt.Sprintf(`{"id": "12", "paging_token": "12", "seller": %q, "selling": {"asset_type": "native"},
+				"buying": {"asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": %q}, "amount": "1.0000000", "price": "1.0000000", "price_r": {"n": 1, "d": 1}}`,
+				from.Address(), issuer),
+		},
+		sponsoredAccounts: fmt.Sprintf(`{"id": %q, "account_id": %q, "sequence": "1", "sponsor": %q,
+			"balances": [{"balance": "0.0000000", "limit": "1.0000000", "asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": %q, "sponsor": %q}, {"balance": "1.0000000", "asset_type": "native"}]}`,
+			other, other, from.Address(), issuer, from.Address()),
+		paths: `{"source_amount": "5.0000000", "source_asset_type": "credit_alphanum4", "source_asset_code": "EUR", "source_asset_issuer": "` + issuer + `",
+			"path": [], "destination_amount": "3.0000000", "destination_asset_type": "native"}`,
+	}
+	defer withMergeHorizon(t, fake)()
+
+	// by default, untrusted assets are an error
+	_, err := PlanAccountMerge(seedStr(t, from), addressStr(t, to), MergeOptions{})
+	require.Error(t, err)
+
+	plan, err := PlanAccountMerge(seedStr(t, from), addressStr(t, to), MergeOptions{Untrusted: MergeUntrustedSellForXLM})
+	require.NoError(t, err)
+	require.False(t, plan.CreateDestination)
+	require.Equal(t, 2, plan.RevokedSponsorships)
+	require.Equal(t, []int64{12}, plan.CanceledOffers)
+	require.Len(t, plan.Payments, 2)
+	require.Equal(t, MergeActionPayment, plan.Payments[0].Action)
+	require.Equal(t, MergeActionPathPayment, plan.Payments[1].Action)
+	require.Equal(t, "2.9700000", plan.Payments[1].XLM)
+	require.Len(t, plan.DeletedTrustlines, 3)
+	require.Equal(t, []string{"a", "b"}, plan.DeletedData)
+	require.Equal(t, []string{signer}, plan.RemovedSigners)
+	require.Equal(t, "0.0001200", plan.Fees)
+	require.Equal(t, "102.9698800", plan.MergeAmount)
+	require.Len(t, plan.Transactions, 1)
+
+	results, err := plan.Submit()
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Len(t, fake.envelopes, 1)
+	env := fake.envelopes[0]
+	require.Equal(t, []xdr.OperationType{
+		xdr.OperationTypeRevokeSponsorship, xdr.OperationTypeRevokeSponsorship,
+		xdr.OperationTypeManageSellOffer,
+		xdr.OperationTypePayment, xdr.OperationTypePathPaymentStrictSend,
+		xdr.OperationTypeChangeTrust, xdr.OperationTypeChangeTrust, xdr.OperationTypeChangeTrust,
+		xdr.OperationTypeManageData, xdr.OperationTypeManageData,
+		xdr.OperationTypeSetOptions,
+		xdr.OperationTypeAccountMerge,
+	}, opTypes(env))
+	require.Equal(t, xdr.Int64(0), env.Operations()[2].Body.MustManageSellOfferOp().Amount)
+	require.Equal(t, xdr.Int64(12), env.Operations()[2].Body.MustManageSellOfferOp().OfferId)
+	pp := env.Operations()[4].Body.MustPathPaymentStrictSendOp()
+	require.Equal(t, from.Address(), pp.Destination.ToAccountId().Address())
+	require.Equal(t, xdr.Int64(29700000), pp.DestMin)
+	require.Equal(t, "via keybase", env.Memo().MustText())
+
+	// the account sponsors something horizon can't list
+	fake.accounts[from.Address()] = strings.Replace(fake.accounts[from.Address()], `"num_sponsoring": 3`, `"num_sponsoring": 4`, 1)
+	_, err = PlanAccountMerge(seedStr(t, from), addressStr(t, to), MergeOptions{Untrusted: MergeUntrustedSellForXLM})
+	require.Error(t, err)
+}
+
+func TestPlanAccountMergeClaimableBalance(t *testing.T) {
+	from := keypair.MustRandom()
+	to := keypair.MustRandom()
+	issuer := keypair.MustRandom().Address()
+	fake := &mergeHorizon{accounts: map[string]string{
+		from.Address(): mergeAccount(from.Address(), mergeTrustline("EUR", issuer, "5.0000000"), ""),
+		to.Address():   mergeAccount(to.Address(), "", ""),
+	}}
+	defer withMergeHorizon(t, fake)()
+
+	_, err := PlanAccountMerge(seedStr(t, from), addressStr(t, to), MergeOptions{Untrusted: MergeUntrustedClaimableBalance})
+	require.Equal(t, ErrMissingParameter{Key: "destination_seed"}, err)
+
+	plan, err := PlanAccountMerge(seedStr(t, from), addressStr(t, to), MergeOptions{
+		Untrusted:       MergeUntrustedClaimableBalance,
+		DestinationSeed: seedStr(t, to),
+	})
+	require.NoError(t, err)
+	require.Equal(t, MergeActionClaimableBalance, plan.Payments[0].Action)
+	require.Equal(t, "99.9999500", plan.MergeAmount)
+
+	var env xdr.TransactionEnvelope
+	require.NoError(t, xdr.SafeUnmarshalBase64(plan.Transactions[0].Signed, &env))
+	require.Equal(t, []xdr.OperationType{
+		xdr.OperationTypeBeginSponsoringFutureReserves, xdr.OperationTypeCreateClaimableBalance, xdr.OperationTypeEndSponsoringFutureReserves,
+		xdr.OperationTypeChangeTrust, xdr.OperationTypeAccountMerge,
+	}, opTypes(env))
+	require.Equal(t, []string{to.Address(), "", "", "", ""}, opSources(t, env))
+	require.Len(t, env.Signatures(), 2)
+}
+
+func TestPlanAccountMergeSplit(t *testing.T) {
+	from := keypair.MustRandom()
+	to := keypair.MustRandom()
+	value := base64.StdEncoding.EncodeToString([]byte("v"))
+	var data []string
+	for i := 0; i < 150; i++ {
+		data = append(data, fmt.Sprintf(`"key%03d": %q`, i, value))
+	}
+	fake := &mergeHorizon{accounts: map[string]string{
+		from.Address(): mergeAccount(from.Address(), "", `, "data": {`+strings.Join(data, ", ")+`}`),
+	}}
+	defer withMergeHorizon(t, fake)()
+
+	plan, err := PlanAccountMerge(seedStr(t, from), addressStr(t, to), MergeOptions{})
+	require.NoError(t, err)
+	require.True(t, plan.CreateDestination)
+	require.Len(t, plan.DeletedData, 150)
+	require.Len(t, plan.Transactions, 2)
+	require.Equal(t, "0.0015200", plan.Fees)
+	require.Equal(t, "98.9984800", plan.MergeAmount)
+
+	results, err := plan.Submit()
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Len(t, fake.envelopes, 2)
+	first, second := fake.envelopes[0], fake.envelopes[1]
+	require.Len(t, first.Operations(), 100)
+	require.Equal(t, xdr.OperationTypeCreateAccount, first.Operations()[0].Body.Type)
+	require.Len(t, second.Operations(), 52)
+	require.Equal(t, xdr.OperationTypeAccountMerge, second.Operations()[51].Body.Type)
+	require.Equal(t, first.SeqNum()+1, second.SeqNum())
+}
+
+func TestPlanAccountMergePoolShare(t *testing.T) {
+	from := keypair.MustRandom()
+	to := keypair.MustRandom()
+	issuer := keypair.MustRandom().Address()
+	usd, err := NewAssetMinimal("USD", issuer)
+	require.NoError(t, err)
+	eur, err := NewAssetMinimal("EUR", issuer)
+	require.NoError(t, err)
+	poolID, err := LiquidityPoolID(usd, eur)
+	require.NoError(t, err)
+	poolShare := func(balance string) string {
+		return fmt.Sprintf(`{"balance": %q, "limit": "1000.0000000", "asset_type": "liquidity_pool_shares", "liquidity_pool_id": %q}, `, balance, poolID)
+	}
+
+	// horizon lists the pool share trustline after the asset trustlines
+	// it depends on
+	fake := &mergeHorizon{
+		accounts: map[string]string{
+			from.Address(): mergeAccount(from.Address(),
+				mergeTrustline("USD", issuer, "0.0000000")+mergeTrustline("EUR", issuer, "0.0000000")+poolShare("0.0000000"), ""),
+			to.Address(): mergeAccount(to.Address(), "", ""),
+		},
+		pools: map[string]string{
+			poolID: fmt.Sprintf(`{"id": %q, "fee_bp": 30, "type": "constant_product", "total_trustlines": "1", "total_shares": "0.0000000",
+				"reserves": [{"asset": "EUR:%s", "amount": "0.0000000"}, {"asset": "USD:%s", "amount": "0.0000000"}]}`, poolID, issuer, issuer),
+		},
+	}
+	defer withMergeHorizon(t, fake)()
+
+	plan, err := PlanAccountMerge(seedStr(t, from), addressStr(t, to), MergeOptions{})
+	require.NoError(t, err)
+	require.Len(t, plan.DeletedTrustlines, 3)
+	require.Equal(t, poolID, plan.DeletedTrustlines[0].LiquidityPoolID)
+	require.Empty(t, plan.DeletedTrustlines[1].LiquidityPoolID)
+	require.Empty(t, plan.DeletedTrustlines[2].LiquidityPoolID)
+
+	var env xdr.TransactionEnvelope
+	require.NoError(t, xdr.SafeUnmarshalBase64(plan.Transactions[0].Signed, &env))
+	require.Equal(t, []xdr.OperationType{
+		xdr.OperationTypeChangeTrust, xdr.OperationTypeChangeTrust, xdr.OperationTypeChangeTrust,
+		xdr.OperationTypeAccountMerge,
+	}, opTypes(env))
+	var lines []xdr.AssetType
+	for _, op := range env.Operations()[:3] {
+		ct := op.Body.MustChangeTrustOp()
+		require.Equal(t, xdr.Int64(0), ct.Limit)
+		lines = append(lines, ct.Line.Type)
+	}
+	require.Equal(t, []xdr.AssetType{xdr.AssetTypeAssetTypePoolShare, xdr.AssetTypeAssetTypeCreditAlphanum4, xdr.AssetTypeAssetTypeCreditAlphanum4}, lines)
+	params := env.Operations()[0].Body.MustChangeTrustOp().Line.MustLiquidityPool().MustConstantProduct()
+	id, err := xdr.NewPoolId(params.AssetA, params.AssetB, params.Fee)
+	require.NoError(t, err)
+	require.Equal(t, poolID, hex.EncodeToString(id[:]))
+
+	// shares must be withdrawn first
+	fake.accounts[from.Address()] = mergeAccount(from.Address(),
+		mergeTrustline("USD", issuer, "0.0000000")+mergeTrustline("EUR", issuer, "0.0000000")+poolShare("1.0000000"), "")
+	_, err = PlanAccountMerge(seedStr(t, from), addressStr(t, to), MergeOptions{})
+	require.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package stellarnet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/stellar/go/clients/horizonclient"
+	horizonProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+)
+
+// networkParamsTTL is how long NetworkParams caches the latest ledger's
+// parameters.  They only change with network upgrades.
+const networkParamsTTL = time.Minute
+
+// NetworkParameters are the network-wide settings of a ledger.
+type NetworkParameters struct {
+	Ledger          int32
+	ClosedAt        time.Time
+	ProtocolVersion int32
+	// BaseFee is the minimum fee per operation in stroops.
+	BaseFee uint64
+	// BaseReserve is the reserve per ledger entry in stroops.
+	BaseReserve  int64
+	MaxTxSetSize int32
+}
+
+// defaultNetworkParams are used when the latest ledger can't be loaded.
+var defaultNetworkParams = NetworkParameters{
+	BaseFee:     txnbuild.MinBaseFee,
+	BaseReserve: 5000000,
+}
+
+var networkParamsCache struct {
+	sync.Mutex
+	client  *horizonclient.Client
+	params  NetworkParameters
+	err     error
+	expires time.Time
+}
+
+// LatestLedger returns the most recently closed ledger.
+func LatestLedger() (horizonProtocol.Ledger, error) {
+	link, err := horizonLink(Client().HorizonURL, "/ledgers?order=desc&limit=1")
+	if err != nil {
+		return horizonProtocol.Ledger{}, err
+	}
+	var page horizonProtocol.LedgersPage
+	if err := getDecodeJSONStrict(link, Client().HTTP.Get, &page); err != nil {
+		return horizonProtocol.Ledger{}, err
+	}
+	if len(page.Embedded.Records) == 0 {
+		return horizonProtocol.Ledger{}, ErrResourceNotFound
+	}
+	return page.Embedded.Records[0], nil
+}
+
+// NetworkParams returns the parameters of the latest ledger.  They are
+// cached for a minute per horizon client.  So that an unreachable
+// horizon isn't asked again on every call, failures are cached too.
+func NetworkParams() (NetworkParameters, error) {
+	client := HorizonClient()
+	networkParamsCache.Lock()
+	if networkParamsCache.client == client && time.Now().Before(networkParamsCache.expires) {
+		params, err := networkParamsCache.params, networkParamsCache.err
+		networkParamsCache.Unlock()
+		return params, err
+	}
+	networkParamsCache.Unlock()
+
+	params, err := loadNetworkParams()
+
+	networkParamsCache.Lock()
+	defer networkParamsCache.Unlock()
+	networkParamsCache.client = client
+	networkParamsCache.params = params
+	networkParamsCache.err = err
+	networkParamsCache.expires = time.Now().Add(networkParamsTTL)
+	return params, err
+}
+
+// loadNetworkParams reads the parameters from the latest ledger.
+func loadNetworkParams() (NetworkParameters, error) {
+	ledger, err := LatestLedger()
+	if err != nil {
+		return NetworkParameters{}, err
+	}
+	params := NetworkParameters{
+		Ledger:          ledger.Sequence,
+		ClosedAt:        ledger.ClosedAt,
+		ProtocolVersion: ledger.ProtocolVersion,
+		BaseFee:         uint64(ledger.BaseFee),
+		BaseReserve:     int64(ledger.BaseReserve),
+		MaxTxSetSize:    ledger.MaxTxSetSize,
+	}
+	if params.BaseFee == 0 {
+		params.BaseFee = defaultNetworkParams.BaseFee
+	}
+	if params.BaseReserve == 0 {
+		params.BaseReserve = defaultNetworkParams.BaseReserve
+	}
+	return params, nil
+}
+
+// networkParamsOrDefault returns NetworkParams, or the defaults if the
+// latest ledger can't be loaded.
+func networkParamsOrDefault() NetworkParameters {
+	params, err := NetworkParams()
+	if err != nil {
+		return defaultNetworkParams
+	}
+	return params
+}
+
+// BaseReserve returns the network's base reserve from the latest ledger.
+func BaseReserve() (string, error) {
+	params, err := NetworkParams()
+	if err != nil {
+		return "", err
+	}
+	return StringFromStellarAmount(params.BaseReserve), nil
+}
+
+// MinBaseFee returns the network's minimum fee per operation in stroops
+// from the latest ledger, or txnbuild.MinBaseFee if it can't be loaded.
+// The transaction builders don't make network requests, so pass it as
+// their baseFee to pay the network's current minimum.
+func MinBaseFee() uint64 {
+	return networkParamsOrDefault().BaseFee
+}
@@ -0,0 +1,91 @@
+package stellarnet
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkParams(t *testing.T) {
+	var fetches int
+	var submitted []xdr.TransactionEnvelope
+	from := keypair.MustRandom()
+	_, restore := withFakeHorizon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/transactions":
+			var env xdr.TransactionEnvelope
+			require.NoError(t, xdr.SafeUnmarshalBase64(r.FormValue("tx"), &env))
+			submitted = append(submitted, env)
+			fmt.Fprint(w, `{"hash": "tx1", "ledger": 8}`)
+			return
+		case r.URL.Path == "/accounts/"+from.Address():
+			fmt.Fprintf(w, `{"id": %q, "account_id": %q, "sequence": "100", "balances": [{"balance": "50.0000000", "asset_type": "native"}]}`,
+				from.Address(), from.Address())
+			return
+		case r.URL.Path != "/ledgers":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status": 404, "title": "Resource Missing"}`))
+			return
+		}
+		fetches++
+		fmt.Fprint(w, `{"_embedded": {"records": [{"sequence": 7, "closed_at": "2021-01-02T03:04:05Z", "protocol_version": 18,
+			"base_fee_in_stroops": 200, "base_reserve_in_stroops": 10000000, "max_tx_set_size": 1000}]}}`)
+	}))
+	defer restore()
+
+	params, err := NetworkParams()
+	require.NoError(t, err)
+	require.Equal(t, int32(7), params.Ledger)
+	require.Equal(t, int32(18), params.ProtocolVersion)
+	require.Equal(t, uint64(200), params.BaseFee)
+	require.Equal(t, int64(10000000), params.BaseReserve)
+	require.Equal(t, int32(1000), params.MaxTxSetSize)
+	require.Equal(t, 2021, params.ClosedAt.Year())
+
+	// cached
+	_, err = NetworkParams()
+	require.NoError(t, err)
+	require.Equal(t, 1, fetches)
+
+	require.Equal(t, uint64(200), MinBaseFee())
+
+	// building transactions stays offline, but the helpers that submit
+	// pay the network's minimum fee
+	tx := NewBaseTx(addressStr(t, keypair.MustRandom()), &testSeqnoProv{seqno: 100}, 0)
+	tx.AddPaymentOp(addressStr(t, keypair.MustRandom()), "1")
+	env, err := tx.Envelope()
+	require.NoError(t, err)
+	require.Equal(t, xdr.Uint32(100), env.V1.Tx.Fee)
+	_, _, _, err = SendXLM(seedStr(t, from), addressStr(t, keypair.MustRandom()), "1", "")
+	require.NoError(t, err)
+	require.Len(t, submitted, 1)
+	require.Equal(t, xdr.Uint32(200), submitted[0].V1.Tx.Fee)
+
+	// AvailableBalance uses the network's reserve
+	available, err := AvailableBalance("10", 1)
+	require.NoError(t, err)
+	require.Equal(t, "7.0000000", available)
+	require.Equal(t, 1, fetches)
+
+	// a different client has its own parameters, and without a ledger
+	// the defaults are used.  The failure is cached too.
+	var missingFetches int
+	_, restoreMissing := withFakeHorizon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		missingFetches++
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status": 404, "title": "Resource Missing"}`))
+	}))
+	defer restoreMissing()
+	_, err = NetworkParams()
+	require.Error(t, err)
+	require.Equal(t, uint64(100), MinBaseFee())
+	require.Equal(t, defaultNetworkParams, networkParamsOrDefault())
+	available, err = AvailableBalance("10", 1)
+	require.NoError(t, err)
+	require.Equal(t, "8.5000000", available)
+	require.Equal(t, 1, missingFetches)
+}
@@ -1,11 +1,14 @@
 package stellarnet
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/protocols/horizon/effects"
 	"github.com/stellar/go/protocols/horizon/operations"
 	"github.com/stellar/go/support/render/hal"
+	"github.com/stellar/go/xdr"
 )
 
 // TransactionEmbed is used to get the Links in addition to
@@ -62,6 +65,10 @@ type Operation struct {
 	CreatedAt       time.Time `json:"created_at"`
 	TransactionHash string    `json:"transaction_hash"`
 
+	// Transaction is only set when the operations are requested
+	// with join=transactions.
+	Transaction *TransactionEmbed `json:"transaction,omitempty"`
+
 	// create_account fields
 	Account         string `json:"account"`
 	StartingBalance string `json:"starting_balance"`
@@ -72,10 +79,77 @@ type Operation struct {
 	From      string `json:"from"`
 	To        string `json:"to"`
 	Amount    string `json:"amount"`
+
+	// Details is the operation decoded into the horizon type for
+	// its Type (operations.PathPayment, operations.ManageSellOffer,
+	// operations.SetOptions, ...), for use in a type switch.  It is nil
+	// for operation types this package doesn't know about and for
+	// records that don't decode into their type.
+	Details operations.Operation `json:"-"`
+}
+
+// operationFields is Operation without its UnmarshalJSON method.
+type operationFields Operation
+
+// UnmarshalJSON decodes the common fields of an operation and
+// its Details.
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*operationFields)(o)); err != nil {
+		return err
+	}
+	var typeI struct {
+		TypeI *int32 `json:"type_i"`
+	}
+	if err := json.Unmarshal(data, &typeI); err != nil {
+		return err
+	}
+	var t xdr.OperationType
+	if typeI.TypeI != nil {
+		t = xdr.OperationType(*typeI.TypeI)
+	} else {
+		var ok bool
+		t, ok = operationTypes[o.Type]
+		if !ok {
+			o.Details = nil
+			return nil
+		}
+	}
+	if _, ok := operations.TypeNames[t]; !ok {
+		o.Details = nil
+		return nil
+	}
+	details, err := operations.UnmarshalOperation(int32(t), data)
+	if err != nil {
+		// a record horizon's types can't decode shouldn't fail
+		// the whole page, so treat it like an unknown type.
+		o.Details = nil
+		return nil
+	}
+	o.Details = details
+	return nil
+}
+
+// operationTypes maps horizon's operation type names, including the
+// names older horizons used, to the xdr operation types.
+var operationTypes = map[string]xdr.OperationType{
+	"path_payment":         xdr.OperationTypePathPaymentStrictReceive,
+	"manage_offer":         xdr.OperationTypeManageSellOffer,
+	"create_passive_offer": xdr.OperationTypeCreatePassiveSellOffer,
+}
+
+func init() {
+	for t, name := range operations.TypeNames {
+		operationTypes[name] = t
+	}
 }
 
 // EffectsPage is for decoding the effects.
 type EffectsPage struct {
+	Links struct {
+		Self hal.Link `json:"self"`
+		Next hal.Link `json:"next"`
+		Prev hal.Link `json:"prev"`
+	} `json:"_links"`
 	Embedded struct {
 		Records []Effect
 	} `json:"_embedded"`
@@ -83,8 +157,38 @@ type EffectsPage struct {
 
 // Effect is a single effect.
 type Effect struct {
-	Type   string `json:"type"`
-	Amount string `json:"amount"`
+	ID          string    `json:"id"`
+	PagingToken string    `json:"paging_token"`
+	Account     string    `json:"account"`
+	Type        string    `json:"type"`
+	CreatedAt   time.Time `json:"created_at"`
+	Amount      string    `json:"amount"`
+
+	// Details is the effect decoded into the horizon type for its
+	// Type (effects.AccountCredited, effects.Trade,
+	// effects.TrustlineCreated, ...), for use in a type switch.
+	// Unknown effect types are an effects.Base.  It is nil for records
+	// that don't decode into their type.
+	Details effects.Effect `json:"-"`
+}
+
+// effectFields is Effect without its UnmarshalJSON method.
+type effectFields Effect
+
+// UnmarshalJSON decodes the common fields of an effect and its Details.
+func (e *Effect) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*effectFields)(e)); err != nil {
+		return err
+	}
+	details, err := effects.UnmarshalEffect(e.Type, data)
+	if err != nil {
+		// as with operations, a record horizon's types can't decode
+		// shouldn't fail the whole page.
+		e.Details = nil
+		return nil
+	}
+	e.Details = details
+	return nil
 }
 
 // AssetEmbed is a single asset in the AssetsPage.
@@ -205,3 +309,27 @@ type PathsPage struct {
 		Records []FullPath `json:"records"`
 	} `json:"_embedded"`
 }
+
+// OffersPage is a page of offers.
+type OffersPage struct {
+	Links struct {
+		Self hal.Link `json:"self"`
+		Next hal.Link `json:"next"`
+		Prev hal.Link `json:"prev"`
+	} `json:"_links"`
+	Embedded struct {
+		Records []horizon.Offer `json:"records"`
+	} `json:"_embedded"`
+}
+
+// AccountsPage is a page of accounts.
+type AccountsPage struct {
+	Links struct {
+		Self hal.Link `json:"self"`
+		Next hal.Link `json:"next"`
+		Prev hal.Link `json:"prev"`
+	} `json:"_links"`
+	Embedded struct {
+		Records []horizon.Account `json:"records"`
+	} `json:"_embedded"`
+}
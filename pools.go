@@ -0,0 +1,321 @@
+package stellarnet
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+
+	horizonProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/support/render/hal"
+	"github.com/stellar/go/xdr"
+)
+
+// liquidityPoolParameters returns the constant product pool parameters
+// for the pair a, b, in either order.
+func liquidityPoolParameters(a, b AssetBase) (xdr.LiquidityPoolParameters, error) {
+	xa, err := assetBaseToXDR(a)
+	if err != nil {
+		return xdr.LiquidityPoolParameters{}, err
+	}
+	xb, err := assetBaseToXDR(b)
+	if err != nil {
+		return xdr.LiquidityPoolParameters{}, err
+	}
+	if xa.Equals(xb) {
+		return xdr.LiquidityPoolParameters{}, errors.New("liquidity pool assets must be different")
+	}
+	if xb.LessThan(xa) {
+		xa, xb = xb, xa
+	}
+	return xdr.LiquidityPoolParameters{
+		Type: xdr.LiquidityPoolTypeLiquidityPoolConstantProduct,
+		ConstantProduct: &xdr.LiquidityPoolConstantProductParameters{
+			AssetA: xa,
+			AssetB: xb,
+			Fee:    xdr.LiquidityPoolFeeV18,
+		},
+	}, nil
+}
+
+// LiquidityPoolID returns the hex ID of the liquidity pool for the pair
+// a, b (in either order) with the standard fee.
+func LiquidityPoolID(a, b AssetBase) (string, error) {
+	params, err := liquidityPoolParameters(a, b)
+	if err != nil {
+		return "", err
+	}
+	cp := params.MustConstantProduct()
+	id, err := xdr.NewPoolId(cp.AssetA, cp.AssetB, cp.Fee)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+// LiquidityPoolAssets returns the assets of the pool for the pair a, b
+// in the pool's order (asset A first).
+func LiquidityPoolAssets(a, b AssetBase) (AssetMinimal, AssetMinimal, error) {
+	params, err := liquidityPoolParameters(a, b)
+	if err != nil {
+		return AssetMinimal{}, AssetMinimal{}, err
+	}
+	cp := params.MustConstantProduct()
+	assetA, err := xdrTypedAssetMinimal(cp.AssetA)
+	if err != nil {
+		return AssetMinimal{}, AssetMinimal{}, err
+	}
+	assetB, err := xdrTypedAssetMinimal(cp.AssetB)
+	if err != nil {
+		return AssetMinimal{}, AssetMinimal{}, err
+	}
+	return assetA, assetB, nil
+}
+
+func parsePoolID(poolID string) (xdr.PoolId, error) {
+	var id xdr.PoolId
+	b, err := hex.DecodeString(poolID)
+	if err != nil || len(b) != len(id) {
+		return id, ErrInvalidParameter{Key: "pool_id"}
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+func makeXDRPoolShareChangeTrustAsset(a, b AssetBase) (xdr.ChangeTrustAsset, error) {
+	params, err := liquidityPoolParameters(a, b)
+	if err != nil {
+		return xdr.ChangeTrustAsset{}, err
+	}
+	return xdr.NewChangeTrustAsset(xdr.AssetTypeAssetTypePoolShare, params)
+}
+
+// LiquidityPool is a liquidity pool from horizon.
+type LiquidityPool struct {
+	horizonProtocol.LiquidityPool
+}
+
+// ReserveAssets returns the assets of the pool's reserves, in order.
+func (p LiquidityPool) ReserveAssets() ([]AssetMinimal, error) {
+	assets := make([]AssetMinimal, len(p.Reserves))
+	for i, r := range p.Reserves {
+		a, err := canonicalAssetMinimal(r.Asset)
+		if err != nil {
+			return nil, err
+		}
+		assets[i] = a
+	}
+	return assets, nil
+}
+
+// ShareAmounts returns the amount of each reserve that shares of the
+// pool are worth, rounded down like a withdrawal.
+func (p LiquidityPool) ShareAmounts(shares string) ([]string, error) {
+	n, err := ParseStellarAmount(shares)
+	if err != nil {
+		return nil, err
+	}
+	total, err := ParseStellarAmount(p.TotalShares)
+	if err != nil {
+		return nil, err
+	}
+	amounts := make([]string, len(p.Reserves))
+	for i, r := range p.Reserves {
+		if total == 0 {
+			amounts[i] = StringFromStellarAmount(0)
+			continue
+		}
+		reserve, err := ParseStellarAmount(r.Amount)
+		if err != nil {
+			return nil, err
+		}
+		v := new(big.Int).Mul(big.NewInt(n), big.NewInt(reserve))
+		v.Quo(v, big.NewInt(total))
+		amounts[i] = StringFromStellarAmount(v.Int64())
+	}
+	return amounts, nil
+}
+
+// DepositPriceBounds returns min and max prices for a deposit into the
+// pool, the current price (reserve A / reserve B) minus and plus the
+// fraction slippage.
+func (p LiquidityPool) DepositPriceBounds(slippage *big.Rat) (min, max string, err error) {
+	if len(p.Reserves) != 2 {
+		return "", "", errors.New("liquidity pool does not have two reserves")
+	}
+	a, err := ParseStellarAmount(p.Reserves[0].Amount)
+	if err != nil {
+		return "", "", err
+	}
+	b, err := ParseStellarAmount(p.Reserves[1].Amount)
+	if err != nil {
+		return "", "", err
+	}
+	if a == 0 || b == 0 {
+		return "", "", errors.New("liquidity pool is empty")
+	}
+	if slippage == nil {
+		slippage = new(big.Rat)
+	}
+	if slippage.Sign() < 0 || slippage.Cmp(big.NewRat(1, 1)) >= 0 {
+		return "", "", ErrInvalidParameter{Key: "slippage"}
+	}
+	current := big.NewRat(a, b)
+	lo := new(big.Rat).Sub(big.NewRat(1, 1), slippage)
+	lo.Mul(lo, current)
+	hi := new(big.Rat).Add(big.NewRat(1, 1), slippage)
+	hi.Mul(hi, current)
+	return lo.FloatString(7), hi.FloatString(7), nil
+}
+
+// LiquidityPoolDetails returns the liquidity pool with hex ID poolID.
+func LiquidityPoolDetails(poolID string) (LiquidityPool, error) {
+	if _, err := parsePoolID(poolID); err != nil {
+		return LiquidityPool{}, err
+	}
+	link, err := horizonLink(Client().HorizonURL, "/liquidity_pools/"+poolID)
+	if err != nil {
+		return LiquidityPool{}, errMap(err)
+	}
+	var pool LiquidityPool
+	if err := getDecodeJSONStrict(link, Client().HTTP.Get, &pool); err != nil {
+		return LiquidityPool{}, err
+	}
+	return pool, nil
+}
+
+// LiquidityPoolsPage is a page of liquidity pools.
+type LiquidityPoolsPage struct {
+	Links struct {
+		Self hal.Link `json:"self"`
+		Next hal.Link `json:"next"`
+		Prev hal.Link `json:"prev"`
+	} `json:"_links"`
+	Embedded struct {
+		Records []LiquidityPool `json:"records"`
+	} `json:"_embedded"`
+}
+
+func (p *LiquidityPoolsPage) nextLink() string { return p.Links.Next.Href }
+func (p *LiquidityPoolsPage) numRecords() int  { return len(p.Embedded.Records) }
+
+// LiquidityPoolFilter selects the pools returned by LiquidityPools.
+// Account selects the pools the account has shares in and Reserves the
+// pools that hold all of the assets.
+type LiquidityPoolFilter struct {
+	Account  AddressStr
+	Reserves []AssetBase
+}
+
+func (f LiquidityPoolFilter) path() string {
+	v := url.Values{}
+	if f.Account != "" {
+		v.Set("account", f.Account.String())
+	}
+	if len(f.Reserves) > 0 {
+		reserves := make([]string, len(f.Reserves))
+		for i, a := range f.Reserves {
+			reserves[i] = canonicalAssetString(a)
+		}
+		v.Set("reserves", strings.Join(reserves, ","))
+	}
+	if len(v) == 0 {
+		return "/liquidity_pools"
+	}
+	return "/liquidity_pools?" + v.Encode()
+}
+
+// canonicalAssetString returns "native" or "CODE:ISSUER", the format
+// horizon uses for assets in liquidity pools.
+func canonicalAssetString(a AssetBase) string {
+	if a.TypeString() == "native" || (a.CodeString() == "" && a.IssuerString() == "") {
+		return "native"
+	}
+	return strings.TrimSpace(a.CodeString()) + ":" + strings.TrimSpace(a.IssuerString())
+}
+
+// LiquidityPoolIterator iterates over liquidity pools.
+type LiquidityPoolIterator struct {
+	*historyIterator
+}
+
+// NewLiquidityPoolIterator returns an iterator over the pools selected
+// by filter.  StopTime and StopLedger don't apply to pools.
+func NewLiquidityPoolIterator(filter LiquidityPoolFilter, opts HistoryOptions) *LiquidityPoolIterator {
+	return &LiquidityPoolIterator{newHistoryIterator(filter.path(), opts,
+		func() historyPage { return &LiquidityPoolsPage{} }, nil)}
+}
+
+// Next advances to the next pool.
+func (it *LiquidityPoolIterator) Next() bool { return it.next() }
+
+// Err returns the error that stopped the iterator, if any.
+func (it *LiquidityPoolIterator) Err() error { return it.err }
+
+// LiquidityPool returns the current pool.
+func (it *LiquidityPoolIterator) LiquidityPool() LiquidityPool {
+	return it.page.(*LiquidityPoolsPage).Embedded.Records[it.index]
+}
+
+// Cursor returns the paging token of the current pool.
+func (it *LiquidityPoolIterator) Cursor() string {
+	if it.page == nil {
+		return it.opts.Cursor
+	}
+	return it.LiquidityPool().PT
+}
+
+// LiquidityPools returns the pools selected by filter.
+func LiquidityPools(filter LiquidityPoolFilter, opts HistoryOptions) ([]LiquidityPool, error) {
+	var pools []LiquidityPool
+	it := NewLiquidityPoolIterator(filter, opts)
+	for it.Next() {
+		pools = append(pools, it.LiquidityPool())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return pools, nil
+}
+
+// PoolShareBalance is an account's shares in a liquidity pool and what
+// they are worth.
+type PoolShareBalance struct {
+	PoolID string
+	Shares string
+	Pool   LiquidityPool
+	// Amounts are the amounts of the pool's reserves the shares can be
+	// withdrawn for, in the order of Pool.Reserves.
+	Amounts []string
+}
+
+// PoolShareBalances returns the account's pool share balances with the
+// current reserves of each pool.
+func (a *Account) PoolShareBalances() ([]PoolShareBalance, error) {
+	balances, err := a.Balances()
+	if err != nil {
+		return nil, err
+	}
+	var shares []PoolShareBalance
+	for _, b := range balances {
+		if b.LiquidityPoolId == "" {
+			continue
+		}
+		pool, err := LiquidityPoolDetails(b.LiquidityPoolId)
+		if err != nil {
+			return nil, err
+		}
+		amounts, err := pool.ShareAmounts(b.Balance)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, PoolShareBalance{
+			PoolID:  b.LiquidityPoolId,
+			Shares:  b.Balance,
+			Pool:    pool,
+			Amounts: amounts,
+		})
+	}
+	return shares, nil
+}
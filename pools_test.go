@@ -0,0 +1,164 @@
+package stellarnet
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+const testPoolAccount = "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO"
+
+func testPoolID(t *testing.T) string {
+	id, err := LiquidityPoolID(testMarketUSD, AssetMinimal{})
+	require.NoError(t, err)
+	return id
+}
+
+func TestLiquidityPoolID(t *testing.T) {
+	id := testPoolID(t)
+	other, err := LiquidityPoolID(AssetMinimal{}, testMarketUSD)
+	require.NoError(t, err)
+	require.Equal(t, id, other)
+
+	native, err := assetBaseToXDR(AssetMinimal{})
+	require.NoError(t, err)
+	usd, err := assetBaseToXDR(testMarketUSD)
+	require.NoError(t, err)
+	expected, err := xdr.NewPoolId(native, usd, xdr.LiquidityPoolFeeV18)
+	require.NoError(t, err)
+	require.Equal(t, hex.EncodeToString(expected[:]), id)
+
+	a, b, err := LiquidityPoolAssets(testMarketUSD, AssetMinimal{})
+	require.NoError(t, err)
+	require.Equal(t, AssetMinimal{AssetType: "native"}, a)
+	require.Equal(t, testMarketUSD, b)
+
+	_, err = LiquidityPoolID(testMarketUSD, testMarketUSD)
+	require.Error(t, err)
+}
+
+func TestLiquidityPoolOps(t *testing.T) {
+	source := keypair.MustRandom()
+	id := testPoolID(t)
+
+	tx := NewBaseTx(addressStr(t, source), &testSeqnoProv{seqno: 100}, txnbuild.MinBaseFee)
+	tx.AddCreatePoolShareTrustlineOp(testMarketUSD, AssetMinimal{}, "1000")
+	tx.AddLiquidityPoolDepositOp(id, "100", "25", "3.9", "4.1")
+	tx.AddLiquidityPoolWithdrawOp(id, "10", "39", "9")
+	tx.AddDeletePoolShareTrustlineOp(AssetMinimal{}, testMarketUSD)
+	txEnv, err := tx.Envelope()
+	require.NoError(t, err)
+	ops := txEnv.Operations()
+	require.Len(t, ops, 4)
+
+	line := ops[0].Body.MustChangeTrustOp().Line
+	require.Equal(t, xdr.AssetTypeAssetTypePoolShare, line.Type)
+	require.Equal(t, fmt.Sprintf("Establish trust line to liquidity pool %s (XLM, USD/%s) with limit 10000000000", id, testMarketIssuer), OpSummary(ops[0], false))
+
+	deposit := ops[1].Body.MustLiquidityPoolDepositOp()
+	require.Equal(t, id, hex.EncodeToString(deposit.LiquidityPoolId[:]))
+	require.Equal(t, xdr.Int64(1000000000), deposit.MaxAmountA)
+	require.Equal(t, xdr.Int64(250000000), deposit.MaxAmountB)
+	require.Equal(t, xdr.Price{N: 39, D: 10}, deposit.MinPrice)
+	require.Equal(t, xdr.Price{N: 41, D: 10}, deposit.MaxPrice)
+
+	withdraw := ops[2].Body.MustLiquidityPoolWithdrawOp()
+	require.Equal(t, xdr.Int64(100000000), withdraw.Amount)
+	require.Equal(t, xdr.Int64(390000000), withdraw.MinAmountA)
+	require.Equal(t, xdr.Int64(90000000), withdraw.MinAmountB)
+
+	require.Equal(t, xdr.Int64(0), ops[3].Body.MustChangeTrustOp().Limit)
+
+	tx = NewBaseTx(addressStr(t, source), &testSeqnoProv{seqno: 100}, txnbuild.MinBaseFee)
+	tx.AddLiquidityPoolDepositOp("abc", "100", "25", "3.9", "4.1")
+	_, err = tx.Envelope()
+	require.Equal(t, ErrInvalidParameter{Key: "pool_id"}, err)
+
+	tx = NewBaseTx(addressStr(t, source), &testSeqnoProv{seqno: 100}, txnbuild.MinBaseFee)
+	tx.AddLiquidityPoolWithdrawOp(id, "0", "0", "0")
+	_, err = tx.Envelope()
+	require.Error(t, err)
+
+	// long asset codes work for regular trustlines too
+	tx = NewBaseTx(addressStr(t, source), &testSeqnoProv{seqno: 100}, txnbuild.MinBaseFee)
+	tx.AddCreateTrustlineOp("LONGCODE", testMarketIssuer, "100")
+	txEnv, err = tx.Envelope()
+	require.NoError(t, err)
+	require.Equal(t, xdr.AssetTypeAssetTypeCreditAlphanum12, txEnv.Operations()[0].Body.MustChangeTrustOp().Line.Type)
+}
+
+type poolHorizon struct {
+	poolID   string
+	requests []string
+}
+
+func (h *poolHorizon) pool() string {
+	return fmt.Sprintf(`{"id": %q, "paging_token": %q, "fee_bp": 30, "type": "constant_product", "total_trustlines": "2", "total_shares": "100.0000000",
+		"reserves": [{"asset": "native", "amount": "400.0000000"}, {"asset": "USD:%s", "amount": "100.0000000"}]}`, h.poolID, h.poolID, testMarketIssuer)
+}
+
+func (h *poolHorizon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.requests = append(h.requests, r.URL.Path+"?"+r.URL.RawQuery)
+	switch r.URL.Path {
+	case "/liquidity_pools/" + h.poolID:
+		fmt.Fprint(w, h.pool())
+	case "/liquidity_pools":
+		fmt.Fprintf(w, `{"_embedded": {"records": [%s]}}`, h.pool())
+	case "/accounts/" + testPoolAccount:
+		fmt.Fprintf(w, `{"id": %q, "account_id": %q, "sequence": "1", "subentry_count": 2, "balances": [
+			{"balance": "25.0000000", "limit": "1000.0000000", "liquidity_pool_id": %q, "asset_type": "liquidity_pool_shares"},
+			{"balance": "10.0000000", "limit": "1000.0000000", "asset_type": "credit_alphanum4", "asset_code": "USD", "asset_issuer": %q},
+			{"balance": "50.0000000", "asset_type": "native"}]}`, testPoolAccount, testPoolAccount, h.poolID, testMarketIssuer)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status": 404, "title": "Resource Missing"}`))
+	}
+}
+
+func TestLiquidityPoolQueries(t *testing.T) {
+	fake := &poolHorizon{poolID: testPoolID(t)}
+	_, restore := withFakeHorizon(t, fake)
+	defer restore()
+
+	pool, err := LiquidityPoolDetails(fake.poolID)
+	require.NoError(t, err)
+	require.Equal(t, "100.0000000", pool.TotalShares)
+	assets, err := pool.ReserveAssets()
+	require.NoError(t, err)
+	require.Equal(t, []AssetMinimal{{AssetType: "native"}, testMarketUSD}, assets)
+	min, max, err := pool.DepositPriceBounds(big.NewRat(1, 100))
+	require.NoError(t, err)
+	require.Equal(t, "3.9600000", min)
+	require.Equal(t, "4.0400000", max)
+
+	_, err = LiquidityPoolDetails("nope")
+	require.Equal(t, ErrInvalidParameter{Key: "pool_id"}, err)
+
+	pools, err := LiquidityPools(LiquidityPoolFilter{Reserves: []AssetBase{AssetMinimal{}, testMarketUSD}}, HistoryOptions{})
+	require.NoError(t, err)
+	require.Len(t, pools, 1)
+	require.Contains(t, fake.requests[len(fake.requests)-1], "reserves=native%2CUSD%3A"+testMarketIssuer)
+
+	acct := NewAccount(testPoolAccount)
+	balances, err := acct.Balances()
+	require.NoError(t, err)
+	require.Equal(t, fake.poolID, balances[0].LiquidityPoolId)
+	tlines, err := acct.Trustlines()
+	require.NoError(t, err)
+	require.Equal(t, "liquidity_pool_shares", tlines[0].Type)
+	require.Equal(t, fake.poolID, tlines[0].LiquidityPoolID)
+
+	shares, err := acct.PoolShareBalances()
+	require.NoError(t, err)
+	require.Len(t, shares, 1)
+	require.Equal(t, fake.poolID, shares[0].PoolID)
+	require.Equal(t, "25.0000000", shares[0].Shares)
+	require.Equal(t, []string{"100.0000000", "25.0000000"}, shares[0].Amounts)
+}
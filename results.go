@@ -2,6 +2,7 @@ package stellarnet
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/stellar/go/xdr"
 )
@@ -72,3 +73,153 @@ func PathPaymentIntermediatePath(envelopeXDR string, opIndex int) ([]AssetMinima
 
 	return path, nil
 }
+
+// BalanceChanges returns the balance changes of every account and asset
+// a transaction touched, from its envelope, result and result meta XDR
+// (the envelope_xdr, result_xdr and result_meta_xdr fields from horizon).
+// The changes include the fee, charged to the fee bump account for fee
+// bump transactions, and the actual amounts of path payments and merges.
+//
+// There is one BalanceChange per account and asset, with the fee first,
+// then in the order the operations changed them.  Balances that didn't
+// change and balances of liquidity pool shares aren't included.
+func BalanceChanges(envelopeXDR, resultXDR, resultMetaXDR string) ([]BalanceChange, error) {
+	var txEnv xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(envelopeXDR, &txEnv); err != nil {
+		return nil, err
+	}
+	var result xdr.TransactionResult
+	if err := xdr.SafeUnmarshalBase64(resultXDR, &result); err != nil {
+		return nil, err
+	}
+	var meta xdr.TransactionMeta
+	if err := xdr.SafeUnmarshalBase64(resultMetaXDR, &meta); err != nil {
+		return nil, err
+	}
+
+	var changes balanceChanges
+	feeSource := txEnv.SourceAccount()
+	if txEnv.IsFeeBump() {
+		feeSource = txEnv.FeeBumpAccount()
+	}
+	changes.addInt(feeSource.ToAccountId().Address(), AssetMinimal{AssetType: "native"}, -int64(result.FeeCharged))
+
+	// The fee isn't in the result meta: the first state of the fee
+	// source is after the fee was charged.  So the balance changes are
+	// the differences between the first and last state of each balance.
+	var all []xdr.LedgerEntryChange
+	switch meta.V {
+	case 0:
+		for _, op := range *meta.Operations {
+			all = append(all, op.Changes...)
+		}
+	case 1:
+		all = append(all, meta.V1.TxChanges...)
+		for _, op := range meta.V1.Operations {
+			all = append(all, op.Changes...)
+		}
+	case 2:
+		all = append(all, meta.V2.TxChangesBefore...)
+		for _, op := range meta.V2.Operations {
+			all = append(all, op.Changes...)
+		}
+		all = append(all, meta.V2.TxChangesAfter...)
+	default:
+		return nil, fmt.Errorf("unsupported transaction meta version %d", meta.V)
+	}
+
+	var keys []balanceChangeKey
+	first := make(map[balanceChangeKey]int64)
+	last := make(map[balanceChangeKey]int64)
+	for _, change := range all {
+		key, balance, ok, err := ledgerEntryChangeBalance(change)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if _, seen := first[key]; !seen {
+			keys = append(keys, key)
+			// created entries start from zero
+			if change.Type == xdr.LedgerEntryChangeTypeLedgerEntryState {
+				first[key] = balance
+			} else {
+				first[key] = 0
+			}
+		}
+		last[key] = balance
+	}
+	for _, key := range keys {
+		if delta := last[key] - first[key]; delta != 0 {
+			changes.addInt(key.account, key.asset, delta)
+		}
+	}
+
+	return changes.list(), nil
+}
+
+// ledgerEntryChangeBalance returns the account and asset of the balance
+// in an account or trust line entry change, and the balance after the
+// change.  ok is false for other entries.
+func ledgerEntryChangeBalance(change xdr.LedgerEntryChange) (key balanceChangeKey, balance int64, ok bool, err error) {
+	if change.Type == xdr.LedgerEntryChangeTypeLedgerEntryRemoved {
+		lk := change.MustRemoved()
+		switch lk.Type {
+		case xdr.LedgerEntryTypeAccount:
+			return balanceChangeKey{account: lk.Account.AccountId.Address(), asset: AssetMinimal{AssetType: "native"}}, 0, true, nil
+		case xdr.LedgerEntryTypeTrustline:
+			if lk.TrustLine.Asset.Type == xdr.AssetTypeAssetTypePoolShare {
+				return key, 0, false, nil
+			}
+			asset, err := xdrTypedAssetMinimal(lk.TrustLine.Asset.ToAsset())
+			if err != nil {
+				return key, 0, false, err
+			}
+			return balanceChangeKey{account: lk.TrustLine.AccountId.Address(), asset: asset}, 0, true, nil
+		}
+		return key, 0, false, nil
+	}
+
+	entry, _ := change.GetLedgerEntry()
+	switch entry.Data.Type {
+	case xdr.LedgerEntryTypeAccount:
+		account := entry.Data.MustAccount()
+		return balanceChangeKey{account: account.AccountId.Address(), asset: AssetMinimal{AssetType: "native"}}, int64(account.Balance), true, nil
+	case xdr.LedgerEntryTypeTrustline:
+		line := entry.Data.MustTrustLine()
+		if line.Asset.Type == xdr.AssetTypeAssetTypePoolShare {
+			return key, 0, false, nil
+		}
+		asset, err := xdrTypedAssetMinimal(line.Asset.ToAsset())
+		if err != nil {
+			return key, 0, false, err
+		}
+		return balanceChangeKey{account: line.AccountId.Address(), asset: asset}, int64(line.Balance), true, nil
+	}
+	return key, 0, false, nil
+}
+
+// xdrTypedAssetMinimal is XDRToAssetMinimal with the asset type set,
+// so assets compare equal to the ones from horizon.
+func xdrTypedAssetMinimal(x xdr.Asset) (AssetMinimal, error) {
+	a, err := XDRToAssetMinimal(x)
+	if err != nil {
+		return AssetMinimal{}, err
+	}
+	return NewAssetMinimal(a.AssetCode, a.AssetIssuer)
+}
+
+// TxBalanceChanges gets the transaction from horizon with TxDetails and
+// returns its BalanceChanges.
+func TxBalanceChanges(txID string) ([]BalanceChange, error) {
+	txID, err := CheckTxID(txID)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := TxDetails(txID)
+	if err != nil {
+		return nil, err
+	}
+	return BalanceChanges(tx.EnvelopeXdr, tx.ResultXdr, tx.ResultMetaXdr)
+}
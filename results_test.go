@@ -2,8 +2,12 @@ package stellarnet
 
 import (
 	"fmt"
+	"net/http"
 	"strings"
 	"testing"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
 )
 
 type resultTest struct {
@@ -76,3 +80,120 @@ func TestPathPaymentIntermediatePath(t *testing.T) {
 		}
 	}
 }
+
+func testAccountEntry(address string, balance xdr.Int64) *xdr.LedgerEntry {
+	return &xdr.LedgerEntry{Data: xdr.LedgerEntryData{
+		Type:    xdr.LedgerEntryTypeAccount,
+		Account: &xdr.AccountEntry{AccountId: xdr.MustAddress(address), Balance: balance},
+	}}
+}
+
+func testTrustLineEntry(address string, asset xdr.Asset, balance xdr.Int64) *xdr.LedgerEntry {
+	return &xdr.LedgerEntry{Data: xdr.LedgerEntryData{
+		Type:      xdr.LedgerEntryTypeTrustline,
+		TrustLine: &xdr.TrustLineEntry{AccountId: xdr.MustAddress(address), Asset: asset.ToTrustLineAsset(), Balance: balance, Limit: 1000000000},
+	}}
+}
+
+func testBalanceChangesXDR(t *testing.T, feeBump bool) (envelopeXDR, resultXDR, metaXDR string) {
+	const (
+		a = "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO"
+		b = "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB"
+		d = "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX"
+	)
+	usd := xdr.MustNewCreditAsset("USD", d)
+	payment := xdr.Operation{Body: xdr.OperationBody{Type: xdr.OperationTypePayment, PaymentOp: &xdr.PaymentOp{
+		Destination: xdr.MustMuxedAddress(b), Asset: xdr.MustNewNativeAsset(), Amount: 100000000,
+	}}}
+	txEnv := xdr.TransactionEnvelope{Type: xdr.EnvelopeTypeEnvelopeTypeTx, V1: &xdr.TransactionV1Envelope{Tx: xdr.Transaction{
+		SourceAccount: xdr.MustMuxedAddress(a), Fee: 300, SeqNum: 2, Operations: []xdr.Operation{payment, payment, payment},
+	}}}
+	if feeBump {
+		txEnv = xdr.TransactionEnvelope{Type: xdr.EnvelopeTypeEnvelopeTypeTxFeeBump, FeeBump: &xdr.FeeBumpTransactionEnvelope{Tx: xdr.FeeBumpTransaction{
+			FeeSource: xdr.MustMuxedAddress(d), Fee: 400,
+			InnerTx: xdr.FeeBumpTransactionInnerTx{Type: xdr.EnvelopeTypeEnvelopeTypeTx, V1: txEnv.V1},
+		}}}
+	}
+	result := xdr.TransactionResult{FeeCharged: 300, Result: xdr.TransactionResultResult{Code: xdr.TransactionResultCodeTxSuccess, Results: &[]xdr.OperationResult{}}}
+
+	state := func(e *xdr.LedgerEntry) xdr.LedgerEntryChange {
+		return xdr.LedgerEntryChange{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: e}
+	}
+	updated := func(e *xdr.LedgerEntry) xdr.LedgerEntryChange {
+		return xdr.LedgerEntryChange{Type: xdr.LedgerEntryChangeTypeLedgerEntryUpdated, Updated: e}
+	}
+	meta := xdr.TransactionMeta{V: 2, V2: &xdr.TransactionMetaV2{
+		// sequence number bump
+		TxChangesBefore: xdr.LedgerEntryChanges{state(testAccountEntry(a, 500000000)), updated(testAccountEntry(a, 500000000))},
+		Operations: []xdr.OperationMeta{
+			// 10 XLM from a to b
+			{Changes: xdr.LedgerEntryChanges{
+				state(testAccountEntry(a, 500000000)), updated(testAccountEntry(a, 400000000)),
+				state(testAccountEntry(b, 200000000)), updated(testAccountEntry(b, 300000000)),
+			}},
+			// 2.5 USD from d to b, b's trust line created earlier in the op
+			{Changes: xdr.LedgerEntryChanges{
+				{Type: xdr.LedgerEntryChangeTypeLedgerEntryCreated, Created: testTrustLineEntry(b, usd, 25000000)},
+			}},
+			// d merged into b
+			{Changes: xdr.LedgerEntryChanges{
+				state(testAccountEntry(d, 50000000)),
+				{Type: xdr.LedgerEntryChangeTypeLedgerEntryRemoved, Removed: &xdr.LedgerKey{Type: xdr.LedgerEntryTypeAccount, Account: &xdr.LedgerKeyAccount{AccountId: xdr.MustAddress(d)}}},
+				state(testAccountEntry(b, 300000000)), updated(testAccountEntry(b, 350000000)),
+			}},
+		},
+	}}
+
+	var err error
+	envelopeXDR, err = xdr.MarshalBase64(txEnv)
+	require.NoError(t, err)
+	resultXDR, err = xdr.MarshalBase64(result)
+	require.NoError(t, err)
+	metaXDR, err = xdr.MarshalBase64(meta)
+	require.NoError(t, err)
+	return envelopeXDR, resultXDR, metaXDR
+}
+
+func TestBalanceChanges(t *testing.T) {
+	xlm := AssetMinimal{AssetType: "native"}
+	usd := AssetMinimal{AssetType: "credit_alphanum4", AssetCode: "USD", AssetIssuer: "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX"}
+
+	changes, err := BalanceChanges(testBalanceChangesXDR(t, false))
+	require.NoError(t, err)
+	require.Equal(t, []BalanceChange{
+		{Account: "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", Asset: xlm, Delta: "-10.0000300"},
+		{Account: "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", Asset: xlm, Delta: "15.0000000"},
+		{Account: "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", Asset: usd, Delta: "2.5000000"},
+		{Account: "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX", Asset: xlm, Delta: "-5.0000000"},
+	}, changes)
+
+	// the fee bump account pays the fee
+	changes, err = BalanceChanges(testBalanceChangesXDR(t, true))
+	require.NoError(t, err)
+	require.Equal(t, BalanceChange{Account: "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX", Asset: xlm, Delta: "-5.0000300"}, changes[0])
+	require.Equal(t, BalanceChange{Account: "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", Asset: xlm, Delta: "-10.0000000"}, changes[1])
+
+	_, err = BalanceChanges("bad", "xdr", "")
+	require.Error(t, err)
+}
+
+func TestTxBalanceChanges(t *testing.T) {
+	envelopeXDR, resultXDR, metaXDR := testBalanceChangesXDR(t, false)
+	hash := testTxHash(7)
+	_, restore := withFakeHorizon(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/transactions/"+hash {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status": 404, "title": "Resource Missing"}`))
+			return
+		}
+		fmt.Fprintf(w, `{"id": %q, "hash": %q, "envelope_xdr": %q, "result_xdr": %q, "result_meta_xdr": %q}`, hash, hash, envelopeXDR, resultXDR, metaXDR)
+	}))
+	defer restore()
+
+	changes, err := TxBalanceChanges(hash)
+	require.NoError(t, err)
+	require.Len(t, changes, 4)
+
+	_, err = TxBalanceChanges(testTxHash(8))
+	require.Equal(t, ErrResourceNotFound, err)
+}
@@ -0,0 +1,265 @@
+package stellarnet
+
+import (
+	"math/big"
+)
+
+// OrderBookFill is the result of crossing an order book snapshot.
+type OrderBookFill struct {
+	// Sent is the amount the taker pays and Received is the amount it
+	// gets.
+	Sent     string
+	Received string
+	// AveragePrice and WorstPrice are in the units of the snapshot's
+	// prices (Buying per unit of Selling).  They are nil if nothing was
+	// filled.
+	AveragePrice *big.Rat
+	WorstPrice   *big.Rat
+	// Filled is true only if the whole amount was matched.  Remaining
+	// is the part of the amount that wasn't, because the book ran out
+	// of offers or because what was left couldn't buy a stroop at the
+	// next offer's price.
+	Filled    bool
+	Remaining string
+}
+
+// crossLevel is an order book level seen from the taker: price is the
+// number of units paid per unit received and amount is the number of
+// stroops that can be received at that price.
+type crossLevel struct {
+	price  *big.Rat
+	amount int64
+	// book is the price as shown in the snapshot.
+	book *big.Rat
+}
+
+// SimulateSell returns the fill for selling amount of b.Selling into
+// the bids.
+func (b *OrderBookSnapshot) SimulateSell(amount string) (OrderBookFill, error) {
+	levels := make([]crossLevel, 0, len(b.Bids))
+	for _, bid := range b.Bids {
+		if bid.Price == nil || bid.Price.Sign() <= 0 {
+			return OrderBookFill{}, ErrInvalidParameter{Key: "price"}
+		}
+		n, err := ParseStellarAmount(bid.Amount)
+		if err != nil {
+			return OrderBookFill{}, err
+		}
+		// bids sell Buying, so the taker pays 1/price
+		levels = append(levels, crossLevel{price: new(big.Rat).Inv(bid.Price), amount: n, book: bid.Price})
+	}
+	fill, err := simulateCross(levels, amount, true)
+	if err != nil {
+		return OrderBookFill{}, err
+	}
+	if fill.AveragePrice != nil {
+		fill.AveragePrice.Inv(fill.AveragePrice)
+	}
+	return fill, nil
+}
+
+// SimulateBuy returns the fill for buying amount of b.Selling from the
+// asks.
+func (b *OrderBookSnapshot) SimulateBuy(amount string) (OrderBookFill, error) {
+	levels := make([]crossLevel, 0, len(b.Asks))
+	for _, ask := range b.Asks {
+		if ask.Price == nil || ask.Price.Sign() <= 0 {
+			return OrderBookFill{}, ErrInvalidParameter{Key: "price"}
+		}
+		n, err := ParseStellarAmount(ask.Amount)
+		if err != nil {
+			return OrderBookFill{}, err
+		}
+		levels = append(levels, crossLevel{price: ask.Price, amount: n, book: ask.Price})
+	}
+	return simulateCross(levels, amount, false)
+}
+
+// simulateCross walks levels, best first.  If send is true, amount is
+// what the taker pays, otherwise it is what the taker receives.
+//
+// Like the ledger, the amount received is rounded down and the amount
+// paid is rounded up to the stroop, in favor of the offer owners.
+// Levels are crossed as single offers, so the result can be off by a
+// stroop per offer from what the network does.
+//
+// The average price of the result is paid per unit received.
+func simulateCross(levels []crossLevel, amount string, send bool) (OrderBookFill, error) {
+	remaining, err := ParseStellarAmount(amount)
+	if err != nil {
+		return OrderBookFill{}, err
+	}
+	if remaining <= 0 {
+		return OrderBookFill{}, ErrInvalidParameter{Key: "amount"}
+	}
+
+	var fill OrderBookFill
+	var sent, received int64
+	for _, l := range levels {
+		if remaining == 0 {
+			break
+		}
+		var get int64
+		if send {
+			// floor(remaining / price)
+			q := new(big.Int).Mul(big.NewInt(remaining), l.price.Denom())
+			get = q.Quo(q, l.price.Num()).Int64()
+			if get == 0 {
+				// too little left to buy a stroop
+				break
+			}
+		} else {
+			get = remaining
+		}
+		if get > l.amount {
+			get = l.amount
+		}
+		// ceil(get * price)
+		pay := new(big.Int).Mul(big.NewInt(get), l.price.Num())
+		pay.Add(pay, new(big.Int).Sub(l.price.Denom(), big.NewInt(1)))
+		pay.Quo(pay, l.price.Denom())
+
+		sent += pay.Int64()
+		received += get
+		if send {
+			remaining -= pay.Int64()
+		} else {
+			remaining -= get
+		}
+		fill.WorstPrice = new(big.Rat).Set(l.book)
+	}
+
+	fill.Filled = remaining == 0
+	fill.Remaining = StringFromStellarAmount(remaining)
+	fill.Sent = StringFromStellarAmount(sent)
+	fill.Received = StringFromStellarAmount(received)
+	if received > 0 {
+		fill.AveragePrice = big.NewRat(sent, received)
+	}
+	return fill, nil
+}
+
+// PathPaymentSendMax returns a sendAmountMax for a strict receive path
+// payment along p (for example from FindPaymentPaths), by simulating
+// the trades in the current order book and liquidity pool of each hop,
+// from the destination back to the source.  Like the network, each hop
+// trades with whichever of the two needs less.  slippage is the
+// fraction added on top of the simulated amount to allow for the books
+// and pools moving before the payment is submitted, for example
+// big.NewRat(1, 100) for 1%.  nil means no slippage.
+//
+// It returns ErrInsufficientLiquidity if neither the book nor the pool
+// of a hop can fill it.  Use it instead of PathPaymentMaxValue when the
+// fixed 5% margin is too much or not enough.
+func PathPaymentSendMax(p FullPath, slippage *big.Rat) (string, error) {
+	assets := []AssetBase{p.SourceAsset()}
+	for _, a := range p.Path {
+		assets = append(assets, a)
+	}
+	assets = append(assets, p.DestinationAsset())
+
+	need := p.DestinationAmount
+	for i := len(assets) - 2; i >= 0; i-- {
+		book, err := OrderBook(assets[i+1], assets[i], historyMaxPageSize)
+		if err != nil {
+			return "", err
+		}
+		fill, err := book.SimulateBuy(need)
+		if err != nil {
+			return "", err
+		}
+		send, filled := fill.Sent, fill.Filled
+
+		poolSend, poolFilled, err := simulatePoolBuy(assets[i], assets[i+1], need)
+		if err != nil {
+			return "", err
+		}
+		if poolFilled && (!filled || stellarAmountLess(poolSend, send)) {
+			send, filled = poolSend, true
+		}
+		if !filled {
+			return "", ErrInsufficientLiquidity
+		}
+		need = send
+	}
+
+	if slippage == nil || slippage.Sign() <= 0 {
+		return need, nil
+	}
+	n, err := ParseStellarAmount(need)
+	if err != nil {
+		return "", err
+	}
+	// ceil(n * (1 + slippage))
+	max := new(big.Rat).Add(big.NewRat(1, 1), slippage)
+	max.Mul(max, big.NewRat(n, 1))
+	v := new(big.Int).Add(max.Num(), new(big.Int).Sub(max.Denom(), big.NewInt(1)))
+	v.Quo(v, max.Denom())
+	if !v.IsInt64() {
+		return "", ErrInvalidParameter{Key: "slippage"}
+	}
+	return StringFromStellarAmount(v.Int64()), nil
+}
+
+// simulatePoolBuy returns the amount of sell the liquidity pool for
+// sell and buy takes to pay out amount of buy.  It returns false if
+// there is no pool or it can't pay out amount.
+//
+// Like the ledger, the amount paid in is rounded up in favor of the
+// pool: ceil(X * y * 10000 / ((Y - y) * (10000 - fee))) for reserves X
+// of sell and Y of buy and fee in basis points.
+func simulatePoolBuy(sell, buy AssetBase, amount string) (string, bool, error) {
+	y, err := ParseStellarAmount(amount)
+	if err != nil {
+		return "", false, err
+	}
+	poolID, err := LiquidityPoolID(sell, buy)
+	if err != nil {
+		return "", false, err
+	}
+	pool, err := LiquidityPoolDetails(poolID)
+	if err == ErrResourceNotFound {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	assets, err := pool.ReserveAssets()
+	if err != nil {
+		return "", false, err
+	}
+	var reserveSell, reserveBuy int64
+	for i, a := range assets {
+		n, err := ParseStellarAmount(pool.Reserves[i].Amount)
+		if err != nil {
+			return "", false, err
+		}
+		switch {
+		case sameAsset(a, sell):
+			reserveSell = n
+		case sameAsset(a, buy):
+			reserveBuy = n
+		}
+	}
+	if reserveSell == 0 || y >= reserveBuy || pool.FeeBP >= 10000 {
+		return "", false, nil
+	}
+
+	num := new(big.Int).Mul(big.NewInt(reserveSell), big.NewInt(y))
+	num.Mul(num, big.NewInt(10000))
+	den := new(big.Int).Mul(big.NewInt(reserveBuy-y), big.NewInt(10000-int64(pool.FeeBP)))
+	num.Add(num, new(big.Int).Sub(den, big.NewInt(1)))
+	num.Quo(num, den)
+	if !num.IsInt64() {
+		return "", false, nil
+	}
+	return StringFromStellarAmount(num.Int64()), true, nil
+}
+
+// stellarAmountLess returns true if amount a is less than amount b.
+// Both are formatted with StringFromStellarAmount.
+func stellarAmountLess(a, b string) bool {
+	x, errX := ParseStellarAmount(a)
+	y, errY := ParseStellarAmount(b)
+	return errX == nil && errY == nil && x < y
+}
@@ -0,0 +1,119 @@
+package stellarnet
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulateOrderBook(t *testing.T) {
+	book := &OrderBookSnapshot{
+		Selling: AssetMinimal{AssetType: "native"},
+		Buying:  testMarketUSD,
+		Bids: []OrderBookLevel{
+			{Price: big.NewRat(1, 4), Amount: "50.0000000"},
+			{Price: big.NewRat(1, 5), Amount: "10.0000000"},
+		},
+		Asks: []OrderBookLevel{
+			{Price: big.NewRat(1, 3), Amount: "30.0000000"},
+			{Price: big.NewRat(1, 2), Amount: "10.0000000"},
+		},
+	}
+
+	fill, err := book.SimulateSell("100")
+	require.NoError(t, err)
+	require.Equal(t, OrderBookFill{Sent: "100.0000000", Received: "25.0000000", AveragePrice: big.NewRat(1, 4), WorstPrice: big.NewRat(1, 4), Filled: true, Remaining: "0.0000000"}, fill)
+
+	// through the first bid into the second
+	fill, err = book.SimulateSell("250")
+	require.NoError(t, err)
+	require.Equal(t, "250.0000000", fill.Sent)
+	require.Equal(t, "60.0000000", fill.Received)
+	require.Equal(t, big.NewRat(6, 25), fill.AveragePrice)
+	require.Equal(t, big.NewRat(1, 5), fill.WorstPrice)
+	require.True(t, fill.Filled)
+
+	fill, err = book.SimulateSell("1000")
+	require.NoError(t, err)
+	require.Equal(t, "250.0000000", fill.Sent)
+	require.False(t, fill.Filled)
+	require.Equal(t, "750.0000000", fill.Remaining)
+
+	fill, err = book.SimulateBuy("35")
+	require.NoError(t, err)
+	require.Equal(t, "12.5000000", fill.Sent)
+	require.Equal(t, "35.0000000", fill.Received)
+	require.Equal(t, big.NewRat(5, 14), fill.AveragePrice)
+	require.Equal(t, big.NewRat(1, 2), fill.WorstPrice)
+	require.True(t, fill.Filled)
+
+	// rounding favors the offer owner
+	fill, err = book.SimulateBuy("0.0000001")
+	require.NoError(t, err)
+	require.Equal(t, "0.0000001", fill.Sent)
+	book.Bids[0].Price = big.NewRat(1, 3)
+	fill, err = book.SimulateSell("0.0000002")
+	require.NoError(t, err)
+	require.Equal(t, "0.0000000", fill.Received)
+	require.Nil(t, fill.AveragePrice)
+	require.False(t, fill.Filled)
+	require.Equal(t, "0.0000002", fill.Remaining)
+
+	// a remainder too small for the next offer isn't a fill
+	fill, err = book.SimulateSell("3.0000002")
+	require.NoError(t, err)
+	require.Equal(t, "3.0000000", fill.Sent)
+	require.Equal(t, "1.0000000", fill.Received)
+	require.False(t, fill.Filled)
+	require.Equal(t, "0.0000002", fill.Remaining)
+
+	_, err = book.SimulateBuy("0")
+	require.Equal(t, ErrInvalidParameter{Key: "amount"}, err)
+	_, err = book.SimulateBuy("x")
+	require.Error(t, err)
+}
+
+func TestPathPaymentSendMax(t *testing.T) {
+	fake, done := withMarketHorizon(t)
+	defer done()
+
+	path := FullPath{
+		SourceAssetType:      testMarketUSD.AssetType,
+		SourceAssetCode:      testMarketUSD.AssetCode,
+		SourceAssetIssuer:    testMarketUSD.AssetIssuer,
+		SourceAmount:         "12.0000000",
+		DestinationAssetType: "native",
+		DestinationAmount:    "35.0000000",
+	}
+	max, err := PathPaymentSendMax(path, nil)
+	require.NoError(t, err)
+	require.Equal(t, "12.5000000", max)
+	max, err = PathPaymentSendMax(path, big.NewRat(1, 100))
+	require.NoError(t, err)
+	require.Equal(t, "12.6250000", max)
+
+	path.DestinationAmount = "50.0000000"
+	_, err = PathPaymentSendMax(path, nil)
+	require.Equal(t, ErrInsufficientLiquidity, err)
+
+	// a pool pricing XLM at 0.4 USD fills what the book can't, and the
+	// book stays cheaper where it can fill
+	fake.poolUSD = "400.0000000"
+	max, err = PathPaymentSendMax(path, nil)
+	require.NoError(t, err)
+	require.Equal(t, "21.1159796", max)
+	path.DestinationAmount = "35.0000000"
+	max, err = PathPaymentSendMax(path, nil)
+	require.NoError(t, err)
+	require.Equal(t, "12.5000000", max)
+
+	// at 0.3 USD the pool is cheaper
+	fake.poolUSD = "300.0000000"
+	max, err = PathPaymentSendMax(path, nil)
+	require.NoError(t, err)
+	require.Equal(t, "10.9135698", max)
+	path.DestinationAmount = "1000.0000000"
+	_, err = PathPaymentSendMax(path, nil)
+	require.Equal(t, ErrInsufficientLiquidity, err)
+}
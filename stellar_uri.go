@@ -20,6 +20,15 @@ type HTTPGetter interface {
 	Get(url string) (resp *http.Response, err error)
 }
 
+// HTTPPoster is an interface for making form POST http requests.
+type HTTPPoster interface {
+	PostForm(url string, data url.Values) (resp *http.Response, err error)
+}
+
+// maxURIChainDepth is the maximum number of nested chain URIs
+// allowed by SEP7.
+const maxURIChainDepth = 7
+
 // ErrMissingParameter is returned when a required parameter is missing.
 type ErrMissingParameter struct {
 	Key string
@@ -66,6 +75,29 @@ func (e ErrInvalidWellKnownOrigin) Error() string {
 	return "invalid origin domain stellar.toml file looking for signing key"
 }
 
+// ErrNetworkPassphraseMismatch is returned when the network_passphrase
+// in a URI does not match the configured network.
+type ErrNetworkPassphraseMismatch struct {
+	URIPassphrase     string
+	NetworkPassphrase string
+}
+
+// Error implements error for ErrNetworkPassphraseMismatch.
+func (e ErrNetworkPassphraseMismatch) Error() string {
+	return fmt.Sprintf("request network passphrase %q does not match network %q", e.URIPassphrase, e.NetworkPassphrase)
+}
+
+// ErrCallbackStatus is returned when the callback url responds to the
+// signed transaction with an unsuccessful http status.
+type ErrCallbackStatus struct {
+	StatusCode int
+}
+
+// Error implements error for ErrCallbackStatus.
+func (e ErrCallbackStatus) Error() string {
+	return fmt.Sprintf("callback returned http status %d", e.StatusCode)
+}
+
 // ErrInvalidScheme is returned if the URI scheme is not web+stellar.
 var ErrInvalidScheme = errors.New("invalid stellar URI scheme")
 
@@ -128,12 +160,21 @@ type ValidatedStellarURI struct {
 	ReplaceSourceAccount bool
 	ReplaceSeqnum        bool
 	UnknownReplaceFields bool
+	Replace              []ReplaceField
+	NetworkPassphrase    string
+	Chain                *ValidatedStellarURI
+
+	// replaceErr is the error parsing the replace parameter.  A
+	// malformed replace parameter doesn't make the URI invalid, but it
+	// can't be applied.
+	replaceErr error
 }
 
 // ValidateStellarURI will check the validity of a web+stellar SEP7 URI.
 //
 // It will check that the parameters are valid and that the payload is
-// signed with the appropriate key.
+// signed with the appropriate key.  A malformed replace parameter does
+// not make a tx URI invalid; ApplyReplacements returns the error.
 func ValidateStellarURI(uri string, getter HTTPGetter) (*ValidatedStellarURI, error) {
 	uv, err := newUnvalidatedURI(uri)
 	if err != nil {
@@ -209,6 +250,10 @@ func newUnvalidatedURI(uri string) (*unvalidatedURI, error) {
 }
 
 func (u *unvalidatedURI) Validate(getter HTTPGetter) (*ValidatedStellarURI, error) {
+	return u.validate(getter, 0)
+}
+
+func (u *unvalidatedURI) validate(getter HTTPGetter, depth int) (*ValidatedStellarURI, error) {
 	// URIs without signatures are valid iff the origin domain is also not set
 	if u.OriginDomain == "" && u.Signature != "" {
 		return nil, ErrMissingParameter{Key: "origin_domain"}
@@ -221,14 +266,44 @@ func (u *unvalidatedURI) Validate(getter HTTPGetter) (*ValidatedStellarURI, erro
 		return nil, ErrInvalidParameter{Key: "origin_domain"}
 	}
 
+	// network_passphrase is optional, but if it is there it must match
+	// the network that the transaction will be signed for.
+	passphrase := u.value("network_passphrase")
+	if passphrase != "" && passphrase != NetworkPassphrase() {
+		return nil, ErrNetworkPassphraseMismatch{URIPassphrase: passphrase, NetworkPassphrase: NetworkPassphrase()}
+	}
+
+	var validated *ValidatedStellarURI
+	var err error
 	switch u.Operation {
 	case "pay":
-		return u.validatePay(getter)
+		validated, err = u.validatePay(getter)
 	case "tx":
-		return u.validateTx(getter)
+		validated, err = u.validateTx(getter)
 	default:
 		return nil, ErrInvalidOperation
 	}
+	if err != nil {
+		return nil, err
+	}
+	validated.NetworkPassphrase = passphrase
+
+	chain := u.value("chain")
+	if chain != "" {
+		if depth+1 >= maxURIChainDepth {
+			return nil, ErrInvalidParameter{Key: "chain"}
+		}
+		uv, err := newUnvalidatedURI(chain)
+		if err != nil {
+			return nil, ErrInvalidParameter{Key: "chain"}
+		}
+		validated.Chain, err = uv.validate(getter, depth+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return validated, nil
 }
 
 type tomlStellar struct {
@@ -404,27 +479,86 @@ func (u *unvalidatedURI) newValidated(op string) *ValidatedStellarURI {
 		v.CallbackURL = strings.TrimPrefix(callback, "url:")
 	}
 
-	// check to see if the source account should be replaced
-	replace := u.value("replace")
-	if replace != "" {
-		pieces := strings.Split(replace, ";")
-		fieldsAndHints := strings.Split(pieces[0], ",")
-		for _, f := range fieldsAndHints {
-			parts := strings.Split(f, ":")
-			switch parts[0] {
-			case "sourceAccount":
-				v.ReplaceSourceAccount = true
-			case "seqNum":
-				v.ReplaceSeqnum = true
-			default:
-				v.UnknownReplaceFields = true
-			}
+	// check to see which fields should be replaced
+	fields, err := ParseReplace(u.value("replace"))
+	if err != nil {
+		// still set the flags from the field paths, so callers see
+		// the same flags for malformed replace parameters as they
+		// always have
+		v.replaceErr = err
+		fields = nil
+		for _, f := range strings.Split(strings.Split(u.value("replace"), ";")[0], ",") {
+			fields = append(fields, ReplaceField{Path: strings.TrimSpace(strings.Split(f, ":")[0])})
+		}
+	} else {
+		v.Replace = fields
+	}
+	for _, f := range fields {
+		switch f.Path {
+		case "sourceAccount":
+			v.ReplaceSourceAccount = true
+		case "seqNum":
+			v.ReplaceSeqnum = true
+		}
+		if !ReplaceFieldSupported(f.Path) {
+			v.UnknownReplaceFields = true
 		}
 	}
 
 	return v
 }
 
+// ApplyReplacements returns a copy of the transaction envelope in a tx
+// URI with the replace fields set to values (keyed by the fields'
+// RefID).  See ApplyReplacements.  It returns ErrInvalidParameter if
+// the replace parameter is malformed.
+func (v *ValidatedStellarURI) ApplyReplacements(values map[string]string) (*xdr.TransactionEnvelope, error) {
+	if v.TxEnv == nil {
+		return nil, ErrMissingParameter{Key: "xdr"}
+	}
+	if v.replaceErr != nil {
+		return nil, v.replaceErr
+	}
+	txEnv, err := ApplyReplacements(*v.TxEnv, v.Replace, values)
+	if err != nil {
+		return nil, err
+	}
+	return &txEnv, nil
+}
+
+// Complete finishes the request after the wallet has signed the
+// transaction.  If the URI has a callback, signedXDR is POSTed to it in
+// the xdr form field, as SEP7 requires.  Otherwise signedXDR is
+// submitted to horizon with Submit.
+func (v *ValidatedStellarURI) Complete(signedXDR string, poster HTTPPoster) (SubmitResult, error) {
+	var txEnv xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(signedXDR, &txEnv); err != nil {
+		return SubmitResult{}, ErrInvalidParameter{Key: "xdr"}
+	}
+	if len(txEnv.Signatures()) == 0 {
+		return SubmitResult{}, errors.New("transaction is not signed")
+	}
+
+	if v.CallbackURL == "" {
+		return Submit(signedXDR)
+	}
+
+	txid, err := HashTxEnvelope(txEnv)
+	if err != nil {
+		return SubmitResult{}, err
+	}
+	resp, err := poster.PostForm(v.CallbackURL, url.Values{"xdr": {signedXDR}})
+	if err != nil {
+		return SubmitResult{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return SubmitResult{}, ErrCallbackStatus{StatusCode: resp.StatusCode}
+	}
+
+	return SubmitResult{TxID: txid, Attempt: 1}, nil
+}
+
 func (u *unvalidatedURI) value(key string) string {
 	return strings.TrimSpace(u.values.Get(key))
 }
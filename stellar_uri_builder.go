@@ -0,0 +1,295 @@
+package stellarnet
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/stellar/go/xdr"
+)
+
+// maxURIMessageLength is the maximum length of the msg parameter
+// allowed by SEP7.
+const maxURIMessageLength = 300
+
+// StellarURIBuilder is a data structure used for making a web+stellar
+// SEP7 URI.  After creating one with NewPayURIBuilder, NewTxURIBuilder
+// or NewTxURIBuilderFromTx, add the optional parameters with the
+// various Set* functions, and finally call URI() or Sign().
+//
+// Like Tx, any errors that occur during the Set* functions are delayed
+// to return when URI() or Sign() is called.
+type StellarURIBuilder struct {
+	operation string
+	keys      []string
+	values    map[string]string
+	err       error
+}
+
+func newStellarURIBuilder(operation string) *StellarURIBuilder {
+	return &StellarURIBuilder{
+		operation: operation,
+		values:    make(map[string]string),
+	}
+}
+
+// NewPayURIBuilder creates a StellarURIBuilder for a pay operation
+// to destination.
+func NewPayURIBuilder(destination AddressStr) *StellarURIBuilder {
+	b := newStellarURIBuilder("pay")
+	if _, err := NewAddressStr(destination.String()); err != nil {
+		b.err = ErrInvalidParameter{Key: "destination"}
+		return b
+	}
+	b.set("destination", destination.String())
+	return b
+}
+
+// NewTxURIBuilder creates a StellarURIBuilder for a tx operation
+// that will ask the user to sign txEnv.
+func NewTxURIBuilder(txEnv xdr.TransactionEnvelope) *StellarURIBuilder {
+	b := newStellarURIBuilder("tx")
+	encoded, err := xdr.MarshalBase64(txEnv)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.set("xdr", encoded)
+	return b
+}
+
+// NewTxURIBuilderFromTx creates a StellarURIBuilder for a tx operation
+// with the unsigned transaction built from t.
+func NewTxURIBuilderFromTx(t *Tx) *StellarURIBuilder {
+	txEnv, err := t.Envelope()
+	if err != nil {
+		b := newStellarURIBuilder("tx")
+		b.err = err
+		return b
+	}
+	return NewTxURIBuilder(txEnv)
+}
+
+// SetAmount sets the amount for a pay operation.
+func (b *StellarURIBuilder) SetAmount(amount string) {
+	if b.skipSet("amount", "pay") {
+		return
+	}
+	if _, err := ParseStellarAmount(amount); err != nil {
+		b.err = ErrInvalidParameter{Key: "amount"}
+		return
+	}
+	b.set("amount", amount)
+}
+
+// SetAsset sets the asset for a pay operation.  Native assets
+// don't need to be set, as XLM is the default.
+func (b *StellarURIBuilder) SetAsset(asset AssetBase) {
+	if b.skipSet("asset_code", "pay") {
+		return
+	}
+	if asset == nil || asset.TypeString() == "native" || (asset.CodeString() == "" && asset.IssuerString() == "") {
+		return
+	}
+	if _, err := assetBaseToXDR(asset); err != nil {
+		b.err = ErrInvalidParameter{Key: "asset_code"}
+		return
+	}
+	b.set("asset_code", asset.CodeString())
+	b.set("asset_issuer", asset.IssuerString())
+}
+
+// SetMemo sets the memo and memo_type for a pay operation.
+// Hash and return memos are base64 encoded as the spec requires.
+func (b *StellarURIBuilder) SetMemo(memo *Memo) {
+	if b.skipSet("memo", "pay") {
+		return
+	}
+	if memo == nil {
+		return
+	}
+	switch memo.Type {
+	case MemoTypeNone:
+		return
+	case MemoTypeText:
+		if memo.Text == nil || len(*memo.Text) > 28 {
+			b.err = ErrInvalidParameter{Key: "memo"}
+			return
+		}
+		b.set("memo", *memo.Text)
+		b.set("memo_type", "MEMO_TEXT")
+	case MemoTypeID:
+		if memo.ID == nil {
+			b.err = ErrInvalidParameter{Key: "memo"}
+			return
+		}
+		b.set("memo", strconv.FormatUint(*memo.ID, 10))
+		b.set("memo_type", "MEMO_ID")
+	case MemoTypeHash:
+		if memo.Hash == nil {
+			b.err = ErrInvalidParameter{Key: "memo"}
+			return
+		}
+		b.set("memo", base64.StdEncoding.EncodeToString(memo.Hash[:]))
+		b.set("memo_type", "MEMO_HASH")
+	case MemoTypeReturn:
+		if memo.ReturnHash == nil {
+			b.err = ErrInvalidParameter{Key: "memo"}
+			return
+		}
+		b.set("memo", base64.StdEncoding.EncodeToString(memo.ReturnHash[:]))
+		b.set("memo_type", "MEMO_RETURN")
+	default:
+		b.err = ErrInvalidParameter{Key: "memo_type"}
+	}
+}
+
+// SetReplace sets the replace parameter for a tx operation.  The
+// value is in the SEP7 replace format, for example:
+// "sourceAccount:X;X:account to pay the fees".
+func (b *StellarURIBuilder) SetReplace(replace string) {
+	if b.skipSet("replace", "tx") {
+		return
+	}
+	if _, err := ParseReplace(replace); err != nil {
+		b.err = err
+		return
+	}
+	b.set("replace", replace)
+}
+
+// SetReplaceFields sets the replace parameter for a tx operation
+// from a list of fields (see FormatReplace).
+func (b *StellarURIBuilder) SetReplaceFields(fields []ReplaceField) {
+	b.SetReplace(FormatReplace(fields))
+}
+
+// SetCallback sets the url that the signed transaction will be posted
+// to instead of being submitted to the network.
+func (b *StellarURIBuilder) SetCallback(callbackURL string) {
+	if b.skipSet("callback", "") {
+		return
+	}
+	u, err := url.Parse(callbackURL)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		b.err = ErrInvalidParameter{Key: "callback"}
+		return
+	}
+	// sep7 spec says only "url:" callbacks are supported
+	b.set("callback", "url:"+callbackURL)
+}
+
+// SetMessage sets the message that will be shown to the user.
+func (b *StellarURIBuilder) SetMessage(msg string) {
+	if b.skipSet("msg", "") {
+		return
+	}
+	if len(msg) > maxURIMessageLength {
+		b.err = ErrInvalidParameter{Key: "msg"}
+		return
+	}
+	b.set("msg", msg)
+}
+
+// SetNetworkPassphrase sets the network passphrase.  It only needs to
+// be set for networks other than the public network.
+func (b *StellarURIBuilder) SetNetworkPassphrase(passphrase string) {
+	if b.skipSet("network_passphrase", "") {
+		return
+	}
+	b.set("network_passphrase", passphrase)
+}
+
+// SetOriginDomain sets the origin domain.  URIs with an origin domain
+// must be signed with Sign() by the URI_REQUEST_SIGNING_KEY in the
+// domain's stellar.toml.
+func (b *StellarURIBuilder) SetOriginDomain(domain string) {
+	if b.skipSet("origin_domain", "") {
+		return
+	}
+	if !isDomainName(domain) {
+		b.err = ErrInvalidParameter{Key: "origin_domain"}
+		return
+	}
+	b.set("origin_domain", domain)
+}
+
+// SetChain sets the chain parameter to a web+stellar URI that
+// this URI is forwarding.
+func (b *StellarURIBuilder) SetChain(uri string) {
+	if b.skipSet("chain", "") {
+		return
+	}
+	if _, err := newUnvalidatedURI(uri); err != nil {
+		b.err = ErrInvalidParameter{Key: "chain"}
+		return
+	}
+	b.set("chain", uri)
+}
+
+// URI returns the unsigned URI.
+func (b *StellarURIBuilder) URI() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	if b.operation == "pay" && b.values["destination"] == "" {
+		return "", ErrMissingParameter{Key: "destination"}
+	}
+	if b.operation == "tx" && b.values["xdr"] == "" {
+		return "", ErrMissingParameter{Key: "xdr"}
+	}
+
+	params := make([]string, len(b.keys))
+	for i, k := range b.keys {
+		params[i] = k + "=" + uriQueryEscape(b.values[k])
+	}
+
+	return "web+stellar:" + b.operation + "?" + strings.Join(params, "&"), nil
+}
+
+// Sign returns the URI signed by seed (see SignStellarURI).  An origin
+// domain is required for a signed URI to validate.
+func (b *StellarURIBuilder) Sign(seed SeedStr) (string, error) {
+	uri, err := b.URI()
+	if err != nil {
+		return "", err
+	}
+	if b.values["origin_domain"] == "" {
+		return "", ErrMissingParameter{Key: "origin_domain"}
+	}
+	signed, _, err := SignStellarURI(uri, seed)
+	if err != nil {
+		return "", err
+	}
+	return signed, nil
+}
+
+// set adds or replaces a parameter, keeping the order that the
+// parameters were first added.
+func (b *StellarURIBuilder) set(key, value string) {
+	if _, ok := b.values[key]; !ok {
+		b.keys = append(b.keys, key)
+	}
+	b.values[key] = value
+}
+
+// skipSet returns true if there is already a condition that prevents
+// setting key.  If operation is not empty, key is only valid for
+// that operation.
+func (b *StellarURIBuilder) skipSet(key, operation string) bool {
+	if b.err != nil {
+		return true
+	}
+	if operation != "" && operation != b.operation {
+		b.err = ErrInvalidParameter{Key: key}
+		return true
+	}
+	return false
+}
+
+// uriQueryEscape escapes s for use as a URI parameter value.  Spaces
+// are encoded as %20 instead of + to match the SEP7 examples.
+func uriQueryEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
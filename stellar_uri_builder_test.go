@@ -0,0 +1,137 @@
+package stellarnet
+
+import (
+	"testing"
+
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+const testURISigningSeed = "SBPOVRVKTTV7W3IOX2FJPSMPCJ5L2WU2YKTP3HCLYPXNI5MDIGREVNYC"
+
+func TestPayURIBuilder(t *testing.T) {
+	b := NewPayURIBuilder("GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO")
+	b.SetAmount("120.1234567")
+	b.SetMemo(NewMemoText("skdjfasf"))
+	b.SetMessage("pay me with lumens")
+	b.SetOriginDomain("someDomain.com")
+	uri, err := b.URI()
+	require.NoError(t, err)
+	require.Equal(t, "web+stellar:pay?destination=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO&amount=120.1234567&memo=skdjfasf&memo_type=MEMO_TEXT&msg=pay%20me%20with%20lumens&origin_domain=someDomain.com", uri)
+
+	seed, err := NewSeedStr(testURISigningSeed)
+	require.NoError(t, err)
+	signed, err := b.Sign(seed)
+	require.NoError(t, err)
+
+	v, err := ValidateStellarURI(signed, &httpClient{})
+	require.NoError(t, err)
+	require.True(t, v.Signed)
+	require.Equal(t, "pay", v.Operation)
+	require.Equal(t, "someDomain.com", v.OriginDomain)
+	require.Equal(t, "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", v.Recipient)
+	require.Equal(t, "120.1234567", v.Amount)
+	require.Equal(t, "pay me with lumens", v.Message)
+	memo, err := v.MemoExport()
+	require.NoError(t, err)
+	require.Equal(t, NewMemoText("skdjfasf").String(), memo.String())
+}
+
+func TestPayURIBuilderAssetAndMemo(t *testing.T) {
+	asset, err := NewAssetMinimal("USD", "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX")
+	require.NoError(t, err)
+	hash := MemoHash{1, 2, 3, 250, 251, 252}
+
+	b := NewPayURIBuilder("GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB")
+	b.SetAmount("10")
+	b.SetAsset(asset)
+	b.SetMemo(NewMemoHash(hash))
+	b.SetCallback("https://www.example.com/callback?a=b c")
+	b.SetNetworkPassphrase(NetworkPassphrase())
+	uri, err := b.URI()
+	require.NoError(t, err)
+
+	v, err := ValidateStellarURI(uri, &httpClient{})
+	require.NoError(t, err)
+	require.False(t, v.Signed)
+	require.Equal(t, "USD", v.AssetCode)
+	require.Equal(t, "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX", v.AssetIssuer)
+	require.Equal(t, "MEMO_HASH", v.MemoType)
+	require.Equal(t, "https://www.example.com/callback?a=b c", v.CallbackURL)
+	memo, err := v.MemoExport()
+	require.NoError(t, err)
+	require.Equal(t, NewMemoHash(hash).String(), memo.String())
+
+	// native asset doesn't add any parameters
+	b = NewPayURIBuilder("GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB")
+	b.SetAsset(AssetMinimal{AssetType: "native"})
+	b.SetMemo(NewMemoID(12345))
+	uri, err = b.URI()
+	require.NoError(t, err)
+	require.Equal(t, "web+stellar:pay?destination=GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB&memo=12345&memo_type=MEMO_ID", uri)
+}
+
+func TestTxURIBuilder(t *testing.T) {
+	tx := NewBaseTx("GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", &testSeqnoProv{seqno: 100}, txnbuild.MinBaseFee)
+	tx.AddCreateTrustlineOp("WHAT", "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", "922337203685.4775807")
+
+	b := NewTxURIBuilderFromTx(tx)
+	b.SetReplace("sourceAccount:X,seqNum:Y;X:account to pay the fees,Y:sequence number")
+	b.SetOriginDomain("someDomain.com")
+	b.SetChain("web+stellar:pay?destination=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO")
+	seed, err := NewSeedStr(testURISigningSeed)
+	require.NoError(t, err)
+	signed, err := b.Sign(seed)
+	require.NoError(t, err)
+
+	v, err := ValidateStellarURI(signed, &httpClient{})
+	require.NoError(t, err)
+	require.True(t, v.Signed)
+	require.Equal(t, "tx", v.Operation)
+	require.True(t, v.ReplaceSourceAccount)
+	require.True(t, v.ReplaceSeqnum)
+	require.NotNil(t, v.TxEnv)
+	require.Equal(t, int64(101), v.TxEnv.SeqNum())
+	require.Len(t, v.TxEnv.Operations(), 1)
+	require.Equal(t, xdr.OperationTypeChangeTrust, v.TxEnv.Operations()[0].Body.Type)
+}
+
+func TestStellarURIBuilderErrors(t *testing.T) {
+	b := NewPayURIBuilder("GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXC")
+	_, err := b.URI()
+	require.Equal(t, ErrInvalidParameter{Key: "destination"}, err)
+
+	b = NewPayURIBuilder("GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB")
+	b.SetAmount("1e10")
+	b.SetMessage("ignored after the first error")
+	_, err = b.URI()
+	require.Equal(t, ErrInvalidParameter{Key: "amount"}, err)
+
+	b = NewPayURIBuilder("GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB")
+	b.SetReplace("sourceAccount:X;X:source")
+	_, err = b.URI()
+	require.Equal(t, ErrInvalidParameter{Key: "replace"}, err)
+
+	b = NewPayURIBuilder("GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB")
+	b.SetMemo(NewMemoText("this memo is much too long for a stellar memo"))
+	_, err = b.URI()
+	require.Equal(t, ErrInvalidParameter{Key: "memo"}, err)
+
+	b = NewPayURIBuilder("GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB")
+	b.SetCallback("/relative/callback")
+	_, err = b.URI()
+	require.Equal(t, ErrInvalidParameter{Key: "callback"}, err)
+
+	b = NewPayURIBuilder("GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB")
+	seed, err := NewSeedStr(testURISigningSeed)
+	require.NoError(t, err)
+	_, err = b.Sign(seed)
+	require.Equal(t, ErrMissingParameter{Key: "origin_domain"}, err)
+
+	tx := NewBaseTx("GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", &testSeqnoProv{}, txnbuild.MinBaseFee)
+	b = NewTxURIBuilderFromTx(tx)
+	b.SetAmount("10")
+	_, err = b.URI()
+	require.Equal(t, ErrNoOps, err)
+}
@@ -0,0 +1,311 @@
+package stellarnet
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/stellar/go/xdr"
+)
+
+// ReplaceField is a single field from the replace parameter of a
+// web+stellar tx URI.  The wallet should ask the user for a value
+// for each distinct RefID and use ApplyReplacements to put them in
+// the transaction.
+type ReplaceField struct {
+	// Path is the Txrep (SEP11) path of the field, without the
+	// "tx." prefix.  For example "sourceAccount" or
+	// "operations[0].destination".
+	Path string
+	// RefID identifies the value for this field.  Fields with the
+	// same RefID get the same value.
+	RefID string
+	// Hint is an optional description of the value to show to the user.
+	Hint string
+}
+
+// ErrUnsupportedReplaceField is returned when a replace field
+// cannot (or should not) be replaced in a transaction.
+type ErrUnsupportedReplaceField struct {
+	Path string
+}
+
+// Error implements error for ErrUnsupportedReplaceField.
+func (e ErrUnsupportedReplaceField) Error() string {
+	return fmt.Sprintf("unsupported replace field %q", e.Path)
+}
+
+// ErrMissingReplaceValue is returned when there is no value for
+// the reference identifier of a replace field.
+type ErrMissingReplaceValue struct {
+	RefID string
+}
+
+// Error implements error for ErrMissingReplaceValue.
+func (e ErrMissingReplaceValue) Error() string {
+	return fmt.Sprintf("missing value for replace reference %q", e.RefID)
+}
+
+var replaceRefIDRE = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+var replaceOpPathRE = regexp.MustCompile(`^operations\[(\d+)\]\.(.+)$`)
+
+// ParseReplace parses the replace parameter of a web+stellar tx URI.
+//
+// The format is a comma separated list of path:refID fields, then
+// a semicolon, then a list of refID:hint hints.  For example:
+//
+//	sourceAccount:X,operations[0].destination:Y;X:account to pay fees,Y:recipient
+//
+// Hints are optional.
+func ParseReplace(replace string) ([]ReplaceField, error) {
+	replace = strings.TrimSpace(replace)
+	if replace == "" {
+		return nil, nil
+	}
+
+	var fieldList, hintList string
+	if index := strings.Index(replace, ";"); index >= 0 {
+		fieldList = replace[:index]
+		hintList = replace[index+1:]
+	} else {
+		fieldList = replace
+	}
+
+	var fields []ReplaceField
+	refIDs := make(map[string]bool)
+	for _, f := range strings.Split(fieldList, ",") {
+		index := strings.LastIndex(f, ":")
+		if index == -1 {
+			return nil, ErrInvalidParameter{Key: "replace"}
+		}
+		path := strings.TrimPrefix(strings.TrimSpace(f[:index]), "tx.")
+		refID := strings.TrimSpace(f[index+1:])
+		if path == "" || !replaceRefIDRE.MatchString(refID) {
+			return nil, ErrInvalidParameter{Key: "replace"}
+		}
+		fields = append(fields, ReplaceField{Path: path, RefID: refID})
+		refIDs[refID] = true
+	}
+
+	hints, err := parseReplaceHints(hintList, refIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range fields {
+		fields[i].Hint = hints[f.RefID]
+	}
+
+	return fields, nil
+}
+
+// parseReplaceHints parses the refID:hint list.  Hints are free text
+// and can contain commas, so a new hint only starts after a comma
+// (or semicolon) when it is followed by a known refID and a colon.
+func parseReplaceHints(hintList string, refIDs map[string]bool) (map[string]string, error) {
+	hints := make(map[string]string)
+	if strings.TrimSpace(hintList) == "" {
+		return hints, nil
+	}
+
+	startsHint := func(s string) (string, bool) {
+		index := strings.Index(s, ":")
+		if index == -1 {
+			return "", false
+		}
+		refID := strings.TrimSpace(s[:index])
+		return refID, refIDs[refID]
+	}
+
+	var current string
+	var text []string
+	flush := func() {
+		if current != "" {
+			hints[current] = strings.TrimSpace(strings.Join(text, ","))
+		}
+	}
+	for _, piece := range strings.FieldsFunc(hintList, func(r rune) bool { return r == ',' || r == ';' }) {
+		if refID, ok := startsHint(piece); ok {
+			flush()
+			current = refID
+			text = []string{piece[strings.Index(piece, ":")+1:]}
+			continue
+		}
+		if current == "" {
+			return nil, ErrInvalidParameter{Key: "replace"}
+		}
+		text = append(text, piece)
+	}
+	flush()
+
+	return hints, nil
+}
+
+// FormatReplace returns fields in the replace parameter format.
+// It is the inverse of ParseReplace.
+func FormatReplace(fields []ReplaceField) string {
+	paths := make([]string, len(fields))
+	var hints []string
+	seen := make(map[string]bool)
+	for i, f := range fields {
+		paths[i] = f.Path + ":" + f.RefID
+		if f.Hint != "" && !seen[f.RefID] {
+			hints = append(hints, f.RefID+":"+f.Hint)
+			seen[f.RefID] = true
+		}
+	}
+	if len(hints) == 0 {
+		return strings.Join(paths, ",")
+	}
+	return strings.Join(paths, ",") + ";" + strings.Join(hints, ",")
+}
+
+// ReplaceFieldSupported returns true if ApplyReplacements can replace
+// a field at path.  Only fields that identify accounts, the sequence
+// number and the time bounds can be replaced.  Amounts, assets and
+// everything else that changes what the transaction does are not
+// supported.
+func ReplaceFieldSupported(path string) bool {
+	switch path {
+	case "sourceAccount", "seqNum", "timeBounds.minTime", "timeBounds.maxTime":
+		return true
+	}
+	m := replaceOpPathRE.FindStringSubmatch(path)
+	if m == nil {
+		return false
+	}
+	switch m[2] {
+	case "sourceAccount", "destination":
+		return true
+	}
+	_, ok := replaceOpFields[m[2]]
+	return ok
+}
+
+// replaceOpFields maps the operation field paths that can be replaced
+// to their operation type.
+var replaceOpFields = map[string]xdr.OperationType{
+	"body.createAccountOp.destination":                 xdr.OperationTypeCreateAccount,
+	"body.paymentOp.destination":                       xdr.OperationTypePayment,
+	"body.pathPaymentStrictReceiveOp.destination":      xdr.OperationTypePathPaymentStrictReceive,
+	"body.pathPaymentStrictSendOp.destination":         xdr.OperationTypePathPaymentStrictSend,
+	"body.destination":                                 xdr.OperationTypeAccountMerge,
+	"body.allowTrustOp.trustor":                        xdr.OperationTypeAllowTrust,
+	"body.setTrustLineFlagsOp.trustor":                 xdr.OperationTypeSetTrustLineFlags,
+	"body.clawbackOp.from":                             xdr.OperationTypeClawback,
+	"body.beginSponsoringFutureReservesOp.sponsoredID": xdr.OperationTypeBeginSponsoringFutureReserves,
+}
+
+// ApplyReplacements returns a copy of txEnv with the replace fields set
+// to the values supplied by the user.  values is keyed by RefID.
+//
+// The replacements are made on the Txrep (SEP11) form of txEnv, so
+// paths are Txrep paths.  Any existing signatures in txEnv will no
+// longer be valid.
+func ApplyReplacements(txEnv xdr.TransactionEnvelope, fields []ReplaceField, values map[string]string) (xdr.TransactionEnvelope, error) {
+	if txEnv.IsFeeBump() && len(fields) > 0 {
+		return xdr.TransactionEnvelope{}, ErrUnsupportedReplaceField{Path: fields[0].Path}
+	}
+
+	enc, err := newTxrepEncoding(txEnv)
+	if err != nil {
+		return xdr.TransactionEnvelope{}, err
+	}
+
+	for _, f := range fields {
+		if !ReplaceFieldSupported(f.Path) {
+			return xdr.TransactionEnvelope{}, ErrUnsupportedReplaceField{Path: f.Path}
+		}
+		value, ok := values[f.RefID]
+		if !ok || strings.TrimSpace(value) == "" {
+			return xdr.TransactionEnvelope{}, ErrMissingReplaceValue{RefID: f.RefID}
+		}
+		if err := replaceTxrepField(enc, txEnv, f.Path, strings.TrimSpace(value)); err != nil {
+			return xdr.TransactionEnvelope{}, err
+		}
+		// decode after each field so a bad value can be reported
+		// for its path
+		if _, err := enc.envelope(); err != nil {
+			if _, ok := err.(ErrTxrepInvalidValue); ok {
+				return xdr.TransactionEnvelope{}, ErrInvalidParameter{Key: f.Path}
+			}
+			return xdr.TransactionEnvelope{}, err
+		}
+	}
+
+	return enc.envelope()
+}
+
+// replaceTxrepField sets the Txrep key(s) for path to value.  txEnv is
+// the original envelope, used to check that operation fields match the
+// operation type.
+func replaceTxrepField(enc *txrepEncoding, txEnv xdr.TransactionEnvelope, path, value string) error {
+	switch path {
+	case "sourceAccount":
+		if txEnv.Type == xdr.EnvelopeTypeEnvelopeTypeTxV0 {
+			enc.set("tx.sourceAccountEd25519", value)
+		} else {
+			enc.set("tx.sourceAccount", value)
+		}
+		return nil
+	case "seqNum":
+		seqno, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seqno < 0 {
+			return ErrInvalidParameter{Key: path}
+		}
+		enc.set("tx.seqNum", value)
+		return nil
+	case "timeBounds.minTime", "timeBounds.maxTime":
+		if enc.values["tx.timeBounds._present"] != "true" {
+			enc.set("tx.timeBounds._present", "true")
+			enc.set("tx.timeBounds.minTime", "0")
+			enc.set("tx.timeBounds.maxTime", "0")
+		}
+		enc.set("tx."+path, value)
+		return nil
+	}
+
+	m := replaceOpPathRE.FindStringSubmatch(path)
+	if m == nil {
+		return ErrUnsupportedReplaceField{Path: path}
+	}
+	index, err := strconv.Atoi(m[1])
+	ops := txEnv.Operations()
+	if err != nil || index >= len(ops) {
+		return ErrUnsupportedReplaceField{Path: path}
+	}
+	prefix := fmt.Sprintf("tx.operations[%d]", index)
+
+	field := m[2]
+	if field == "sourceAccount" {
+		enc.set(prefix+".sourceAccount._present", "true")
+		enc.set(prefix+".sourceAccount", value)
+		return nil
+	}
+
+	if field == "destination" {
+		// shorthand used in the SEP7 examples
+		switch ops[index].Body.Type {
+		case xdr.OperationTypeCreateAccount:
+			field = "body.createAccountOp.destination"
+		case xdr.OperationTypePayment:
+			field = "body.paymentOp.destination"
+		case xdr.OperationTypePathPaymentStrictReceive:
+			field = "body.pathPaymentStrictReceiveOp.destination"
+		case xdr.OperationTypePathPaymentStrictSend:
+			field = "body.pathPaymentStrictSendOp.destination"
+		case xdr.OperationTypeAccountMerge:
+			field = "body.destination"
+		default:
+			return ErrUnsupportedReplaceField{Path: path}
+		}
+	}
+
+	opType, ok := replaceOpFields[field]
+	if !ok || opType != ops[index].Body.Type {
+		return ErrUnsupportedReplaceField{Path: path}
+	}
+	enc.set(prefix+"."+field, value)
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package stellarnet
+
+import (
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReplace(t *testing.T) {
+	fields, err := ParseReplace("sourceAccount:X,operations[0].destination:Y,tx.operations[1].destination:Y;X:account to pay fees, if you want,Y:recipient")
+	require.NoError(t, err)
+	require.Equal(t, []ReplaceField{
+		{Path: "sourceAccount", RefID: "X", Hint: "account to pay fees, if you want"},
+		{Path: "operations[0].destination", RefID: "Y", Hint: "recipient"},
+		{Path: "operations[1].destination", RefID: "Y", Hint: "recipient"},
+	}, fields)
+
+	fields, err = ParseReplace("sourceAccount:X,seqNum:Y")
+	require.NoError(t, err)
+	require.Equal(t, []ReplaceField{
+		{Path: "sourceAccount", RefID: "X"},
+		{Path: "seqNum", RefID: "Y"},
+	}, fields)
+
+	// hints separated by semicolons
+	fields, err = ParseReplace("sourceAccount:X,seqNum:Y;X:source;Y:seqno")
+	require.NoError(t, err)
+	require.Equal(t, "source", fields[0].Hint)
+	require.Equal(t, "seqno", fields[1].Hint)
+
+	fields, err = ParseReplace("")
+	require.NoError(t, err)
+	require.Nil(t, fields)
+
+	bad := []string{
+		"sourceAccount",
+		"sourceAccount:",
+		":X",
+		"sourceAccount:X Y",
+		"sourceAccount:X;Z",
+	}
+	for _, b := range bad {
+		_, err := ParseReplace(b)
+		require.Equal(t, ErrInvalidParameter{Key: "replace"}, err, b)
+	}
+}
+
+func TestFormatReplace(t *testing.T) {
+	in := "sourceAccount:X,operations[0].destination:Y,operations[1].destination:Y;X:account to pay fees,Y:recipient"
+	fields, err := ParseReplace(in)
+	require.NoError(t, err)
+	require.Equal(t, in, FormatReplace(fields))
+}
+
+func TestApplyReplacements(t *testing.T) {
+	source := keypair.MustRandom()
+	recipient := keypair.MustRandom()
+	newSource := keypair.MustRandom()
+	newRecipient := keypair.MustRandom()
+
+	tx := NewBaseTx(addressStr(t, source), &testSeqnoProv{seqno: 100}, txnbuild.MinBaseFee)
+	tx.AddPaymentOp(addressStr(t, recipient), "10")
+	tx.AddCreateTrustlineOp("WHAT", addressStr(t, recipient), "100")
+	txEnv, err := tx.Envelope()
+	require.NoError(t, err)
+
+	fields := []ReplaceField{
+		{Path: "sourceAccount", RefID: "X"},
+		{Path: "seqNum", RefID: "S"},
+		{Path: "operations[0].destination", RefID: "Y"},
+		{Path: "operations[1].sourceAccount", RefID: "X"},
+	}
+	values := map[string]string{
+		"X": newSource.Address(),
+		"Y": newRecipient.Address(),
+		"S": "5000",
+	}
+	replaced, err := ApplyReplacements(txEnv, fields, values)
+	require.NoError(t, err)
+
+	sourceAccount := replaced.SourceAccount()
+	require.Equal(t, newSource.Address(), sourceAccount.Address())
+	require.Equal(t, int64(5000), replaced.SeqNum())
+	ops := replaced.Operations()
+	require.Equal(t, newRecipient.Address(), ops[0].Body.PaymentOp.Destination.Address())
+	require.Equal(t, newSource.Address(), ops[1].SourceAccount.Address())
+
+	// original is unchanged
+	originalSource := txEnv.SourceAccount()
+	require.Equal(t, source.Address(), originalSource.Address())
+	require.Equal(t, recipient.Address(), txEnv.Operations()[0].Body.PaymentOp.Destination.Address())
+	require.Nil(t, txEnv.Operations()[1].SourceAccount)
+
+	// full txrep path
+	_, err = ApplyReplacements(txEnv, []ReplaceField{{Path: "operations[0].body.paymentOp.destination", RefID: "Y"}}, values)
+	require.NoError(t, err)
+
+	// path doesn't match operation type
+	_, err = ApplyReplacements(txEnv, []ReplaceField{{Path: "operations[1].destination", RefID: "Y"}}, values)
+	require.Equal(t, ErrUnsupportedReplaceField{Path: "operations[1].destination"}, err)
+	_, err = ApplyReplacements(txEnv, []ReplaceField{{Path: "operations[0].body.createAccountOp.destination", RefID: "Y"}}, values)
+	require.Equal(t, ErrUnsupportedReplaceField{Path: "operations[0].body.createAccountOp.destination"}, err)
+
+	// unsafe fields
+	_, err = ApplyReplacements(txEnv, []ReplaceField{{Path: "operations[0].body.paymentOp.amount", RefID: "Y"}}, values)
+	require.Equal(t, ErrUnsupportedReplaceField{Path: "operations[0].body.paymentOp.amount"}, err)
+	_, err = ApplyReplacements(txEnv, []ReplaceField{{Path: "fee", RefID: "Y"}}, values)
+	require.Equal(t, ErrUnsupportedReplaceField{Path: "fee"}, err)
+
+	// out of range operation
+	_, err = ApplyReplacements(txEnv, []ReplaceField{{Path: "operations[2].sourceAccount", RefID: "X"}}, values)
+	require.Equal(t, ErrUnsupportedReplaceField{Path: "operations[2].sourceAccount"}, err)
+
+	// missing and invalid values
+	_, err = ApplyReplacements(txEnv, []ReplaceField{{Path: "sourceAccount", RefID: "Z"}}, values)
+	require.Equal(t, ErrMissingReplaceValue{RefID: "Z"}, err)
+	_, err = ApplyReplacements(txEnv, []ReplaceField{{Path: "sourceAccount", RefID: "S"}}, values)
+	require.Equal(t, ErrInvalidParameter{Key: "sourceAccount"}, err)
+	_, err = ApplyReplacements(txEnv, []ReplaceField{{Path: "seqNum", RefID: "S"}}, map[string]string{"S": "-1"})
+	require.Equal(t, ErrInvalidParameter{Key: "seqNum"}, err)
+	_, err = ApplyReplacements(txEnv, []ReplaceField{{Path: "seqNum", RefID: "S"}}, map[string]string{"S": "abc"})
+	require.Equal(t, ErrInvalidParameter{Key: "seqNum"}, err)
+}
+
+func TestApplyReplacementsV0(t *testing.T) {
+	// the transaction from the SEP7 change trust example
+	var txEnv xdr.TransactionEnvelope
+	err := xdr.SafeUnmarshalBase64("AAAAAP+yw+ZEuNg533pUmwlYxfrq6/BoMJqiJ8vuQhf6rHWmAAAAZAB8NHAAAAABAAAAAAAAAAAAAAABAAAAAAAAAAEAAAAA/7LD5kS42DnfelSbCVjF+urr8GgwmqIny+5CF/qsdaYAAAAAAAAAAACYloAAAAAAAAAAAA==", &txEnv)
+	require.NoError(t, err)
+	require.Equal(t, xdr.EnvelopeTypeEnvelopeTypeTxV0, txEnv.Type)
+
+	newSource := keypair.MustRandom()
+	replaced, err := ApplyReplacements(txEnv, []ReplaceField{{Path: "sourceAccount", RefID: "X"}}, map[string]string{"X": newSource.Address()})
+	require.NoError(t, err)
+	sourceAccount := replaced.SourceAccount()
+	require.Equal(t, newSource.Address(), sourceAccount.Address())
+}
+
+func TestValidatedURIApplyReplacements(t *testing.T) {
+	tx := NewBaseTx("GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", &testSeqnoProv{seqno: 100}, txnbuild.MinBaseFee)
+	tx.AddPaymentOp("GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", "10")
+
+	b := NewTxURIBuilderFromTx(tx)
+	b.SetReplaceFields([]ReplaceField{
+		{Path: "sourceAccount", RefID: "X", Hint: "account to pay from"},
+		{Path: "operations[0].destination", RefID: "Y", Hint: "recipient"},
+	})
+	uri, err := b.URI()
+	require.NoError(t, err)
+
+	v, err := ValidateStellarURI(uri, &httpClient{})
+	require.NoError(t, err)
+	require.True(t, v.ReplaceSourceAccount)
+	require.False(t, v.UnknownReplaceFields)
+	require.Len(t, v.Replace, 2)
+	require.Equal(t, "recipient", v.Replace[1].Hint)
+
+	replaced, err := v.ApplyReplacements(map[string]string{
+		"X": keypair.MustRandom().Address(),
+		"Y": "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", replaced.Operations()[0].Body.PaymentOp.Destination.Address())
+
+	// unsafe fields are flagged
+	b = NewTxURIBuilderFromTx(tx)
+	b.SetReplace("operations[0].body.paymentOp.amount:X")
+	uri, err = b.URI()
+	require.NoError(t, err)
+	v, err = ValidateStellarURI(uri, &httpClient{})
+	require.NoError(t, err)
+	require.True(t, v.UnknownReplaceFields)
+	_, err = v.ApplyReplacements(map[string]string{"X": "1000"})
+	require.Equal(t, ErrUnsupportedReplaceField{Path: "operations[0].body.paymentOp.amount"}, err)
+
+	// bad grammar still validates, like it did before replace was
+	// parsed, but can't be applied
+	v, err = ValidateStellarURI("web+stellar:tx?xdr="+uriQueryEscape(v.XDR)+"&replace=sourceAccount", &httpClient{})
+	require.NoError(t, err)
+	require.True(t, v.ReplaceSourceAccount)
+	require.False(t, v.UnknownReplaceFields)
+	require.Nil(t, v.Replace)
+	_, err = v.ApplyReplacements(map[string]string{"X": keypair.MustRandom().Address()})
+	require.Equal(t, ErrInvalidParameter{Key: "replace"}, err)
+}
@@ -4,8 +4,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stretchr/testify/require"
 )
 
 type invalidURITest struct {
@@ -239,6 +244,85 @@ func TestSignStellarURI(t *testing.T) {
 	}
 }
 
+func TestStellarURINetworkPassphrase(t *testing.T) {
+	base := "web+stellar:pay?destination=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO"
+
+	v, err := ValidateStellarURI(base+"&network_passphrase="+uriQueryEscape(NetworkPassphrase()), &httpClient{})
+	require.NoError(t, err)
+	require.Equal(t, NetworkPassphrase(), v.NetworkPassphrase)
+
+	_, err = ValidateStellarURI(base+"&network_passphrase=Some%20Other%20Network", &httpClient{})
+	require.Equal(t, ErrNetworkPassphraseMismatch{URIPassphrase: "Some Other Network", NetworkPassphrase: NetworkPassphrase()}, err)
+}
+
+func TestStellarURIChain(t *testing.T) {
+	uri := "web+stellar:pay?destination=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO&msg=first"
+	for i := 0; i < maxURIChainDepth-1; i++ {
+		uri = "web+stellar:pay?destination=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO&chain=" + uriQueryEscape(uri)
+	}
+	v, err := ValidateStellarURI(uri, &httpClient{})
+	require.NoError(t, err)
+	depth := 1
+	for ; v.Chain != nil; v = v.Chain {
+		depth++
+	}
+	require.Equal(t, maxURIChainDepth, depth)
+	require.Equal(t, "first", v.Message)
+
+	// one level too many
+	uri = "web+stellar:pay?destination=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO&chain=" + uriQueryEscape(uri)
+	_, err = ValidateStellarURI(uri, &httpClient{})
+	require.Equal(t, ErrInvalidParameter{Key: "chain"}, err)
+
+	// nested URIs are validated too
+	uri = "web+stellar:pay?destination=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO&chain=" + uriQueryEscape("web+stellar:pay?destination=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO&origin_domain=someDomain.com")
+	_, err = ValidateStellarURI(uri, &httpClient{})
+	require.Equal(t, ErrMissingParameter{Key: "signature"}, err)
+}
+
+func TestStellarURICompleteCallback(t *testing.T) {
+	var posted string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		posted = r.PostForm.Get("xdr")
+		if posted == "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	kp := keypair.MustRandom()
+	tx, err := newBaseTxSeed(seedStr(t, kp), &testSeqnoProv{seqno: 100}, txnbuild.MinBaseFee)
+	require.NoError(t, err)
+	tx.AddPaymentOp("GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", "10")
+
+	b := NewTxURIBuilderFromTx(tx)
+	b.SetCallback(server.URL + "/sep7")
+	uri, err := b.URI()
+	require.NoError(t, err)
+	v, err := ValidateStellarURI(uri, &httpClient{})
+	require.NoError(t, err)
+	require.Equal(t, server.URL+"/sep7", v.CallbackURL)
+
+	// unsigned transactions are rejected
+	_, err = v.Complete(v.XDR, http.DefaultClient)
+	require.Error(t, err)
+	require.Equal(t, "", posted)
+
+	signed, err := tx.Sign(seedStr(t, kp))
+	require.NoError(t, err)
+	res, err := v.Complete(signed.Signed, http.DefaultClient)
+	require.NoError(t, err)
+	require.Equal(t, signed.Signed, posted)
+	require.Equal(t, signed.TxHash, res.TxID)
+
+	// callback errors are returned
+	v.CallbackURL = server.URL + "/missing"
+	server.Config.Handler = http.NotFoundHandler()
+	_, err = v.Complete(signed.Signed, http.DefaultClient)
+	require.Equal(t, ErrCallbackStatus{StatusCode: http.StatusNotFound}, err)
+}
+
 type httpClient struct{}
 
 func (h *httpClient) Get(url string) (resp *http.Response, err error) {
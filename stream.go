@@ -0,0 +1,215 @@
+package stellarnet
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/stellar/go/clients/horizonclient"
+	horizonProtocol "github.com/stellar/go/protocols/horizon"
+)
+
+// StreamRetryDelay is how long the streaming functions wait before
+// reconnecting to horizon after a disconnect or a network error.  The
+// delay doubles after each consecutive failure, up to StreamMaxRetryDelay.
+var StreamRetryDelay = time.Second
+
+// StreamMaxRetryDelay is the longest the streaming functions wait
+// between reconnection attempts.
+var StreamMaxRetryDelay = 30 * time.Second
+
+// PaymentHandler is called with each payment received by StreamPayments.
+// The Details of the operation hold the record decoded by its type
+// (operations.Payment, operations.CreateAccount, operations.AccountMerge,
+// ...).  Returning an error stops the stream.
+type PaymentHandler func(Operation) error
+
+// TransactionHandler is called with each transaction received by
+// StreamTransactions.  Returning an error stops the stream.
+type TransactionHandler func(horizonProtocol.Transaction) error
+
+// LedgerHandler is called with each ledger received by StreamLedgers.
+// Returning an error stops the stream.
+type LedgerHandler func(horizonProtocol.Ledger) error
+
+// StreamPayments streams the payments (payment, path payment,
+// create_account and account_merge operations) for the account from
+// horizon.  cursor is the paging token to start after; if empty, only
+// new payments are streamed.
+//
+// StreamPayments reconnects after disconnects, starting from the last
+// payment it delivered, and only returns when ctx is done (nil), when
+// handler returns an error, or when horizon rejects the request.
+func (a *Account) StreamPayments(ctx context.Context, cursor string, handler PaymentHandler) error {
+	return streamHorizon(ctx, "/accounts/"+a.address.String()+"/payments", cursor, func(data []byte) error {
+		var payment Operation
+		if err := json.Unmarshal(data, &payment); err != nil {
+			return errMap(err)
+		}
+		return handler(payment)
+	})
+}
+
+// StreamTransactions streams the transactions for the account from
+// horizon.  It works like StreamPayments.
+func (a *Account) StreamTransactions(ctx context.Context, cursor string, handler TransactionHandler) error {
+	return streamHorizon(ctx, "/accounts/"+a.address.String()+"/transactions", cursor, func(data []byte) error {
+		var tx horizonProtocol.Transaction
+		if err := json.Unmarshal(data, &tx); err != nil {
+			return errMap(err)
+		}
+		return handler(tx)
+	})
+}
+
+// StreamLedgers streams ledgers as they close.  It works like
+// StreamPayments.
+func StreamLedgers(ctx context.Context, cursor string, handler LedgerHandler) error {
+	return streamHorizon(ctx, "/ledgers", cursor, func(data []byte) error {
+		var ledger horizonProtocol.Ledger
+		if err := json.Unmarshal(data, &ledger); err != nil {
+			return errMap(err)
+		}
+		return handler(ledger)
+	})
+}
+
+// streamHorizon reads server sent events from the horizon endpoint at
+// path, calling handler with the data of each record.  The id of each
+// event is the record's paging token, which is used as the cursor when
+// reconnecting.
+func streamHorizon(ctx context.Context, path, cursor string, handler func(data []byte) error) error {
+	if cursor == "" {
+		cursor = "now"
+	}
+	delay := StreamRetryDelay
+	for {
+		received, err := streamHorizonOnce(ctx, path, &cursor, handler)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if _, ok := err.(streamRetryError); !ok {
+				return err
+			}
+		}
+		if received {
+			delay = StreamRetryDelay
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		if !received {
+			delay *= 2
+			if delay > StreamMaxRetryDelay {
+				delay = StreamMaxRetryDelay
+			}
+		}
+	}
+}
+
+// streamClient returns the client to use for streams.  Streams stay open
+// as long as ctx, so an overall client Timeout (like the one MakeClient
+// sets) would cut every stream off.  For an *http.Client it returns a copy
+// without the Timeout that shares its Transport.
+func streamClient(c horizonclient.HTTP) horizonclient.HTTP {
+	hc, ok := c.(*http.Client)
+	if !ok || hc.Timeout == 0 {
+		return c
+	}
+	sc := *hc
+	sc.Timeout = 0
+	return &sc
+}
+
+// streamRetryError wraps errors that streamHorizon should retry after.
+type streamRetryError struct {
+	err error
+}
+
+func (e streamRetryError) Error() string {
+	return e.err.Error()
+}
+
+// streamHorizonOnce makes one connection to horizon and reads events
+// until it is closed.  It returns true if any records were received.
+func streamHorizonOnce(ctx context.Context, path string, cursor *string, handler func(data []byte) error) (bool, error) {
+	link, err := horizonLink(Client().HorizonURL, path+"?cursor="+url.QueryEscape(*cursor))
+	if err != nil {
+		return false, errMap(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return false, errMap(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := streamClient(Client().HTTP).Do(req)
+	if err != nil {
+		return false, streamRetryError{err: err}
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return false, streamRetryError{err: fmt.Errorf("horizon stream status %d", resp.StatusCode)}
+	case resp.StatusCode != http.StatusOK:
+		return false, decodeHorizonError(resp)
+	}
+
+	received := false
+	reader := bufio.NewReader(resp.Body)
+	var id string
+	var data bytes.Buffer
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return received, streamRetryError{err: err}
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			// blank line dispatches the event.  horizon sends
+			// "hello" and "byebye" string events that aren't records.
+			if data.Len() > 0 && data.Bytes()[0] == '{' {
+				if err := handler(data.Bytes()); err != nil {
+					return received, err
+				}
+				received = true
+				if id != "" {
+					*cursor = id
+				}
+			}
+			id = ""
+			data.Reset()
+		} else {
+			field, value := line, ""
+			if index := strings.Index(line, ":"); index >= 0 {
+				field, value = line[:index], strings.TrimPrefix(line[index+1:], " ")
+			}
+			switch field {
+			case "id":
+				id = value
+			case "data":
+				if data.Len() > 0 {
+					data.WriteByte('\n')
+				}
+				data.WriteString(value)
+			}
+		}
+
+		if err == io.EOF {
+			return received, nil
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package stellarnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	horizonProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/protocols/horizon/operations"
+	"github.com/stretchr/testify/require"
+)
+
+// streamHorizonServer is a fake horizon that streams three records per
+// endpoint, dropping the connection after every two.
+type streamHorizonServer struct {
+	sync.Mutex
+	cursors []string
+}
+
+func (s *streamHorizonServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	cursor := r.URL.Query().Get("cursor")
+	s.cursors = append(s.cursors, cursor)
+	fail := len(s.cursors) == 2
+	s.Unlock()
+
+	if r.URL.Path == "/accounts/missing/payments" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status": 404, "title": "Resource Missing"}`))
+		return
+	}
+	if fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprint(w, "retry: 10\nevent: open\ndata: \"hello\"\n\n")
+	start := 1
+	if cursor != "now" {
+		fmt.Sscanf(cursor, "%d", &start)
+		start++
+	}
+	for i := start; i <= 3 && i < start+2; i++ {
+		var record string
+		switch r.URL.Path {
+		case "/ledgers":
+			record = fmt.Sprintf(`{"id": "l%d", "paging_token": "%d", "sequence": %d}`, i, i, 100+i)
+		case "/accounts/GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO/transactions":
+			record = fmt.Sprintf(`{"id": "tx%d", "paging_token": "%d", "ledger": %d, "successful": true}`, i, i, 100+i)
+		default:
+			switch i {
+			case 1:
+				record = fmt.Sprintf(`{"id": "%d", "paging_token": "%d", "type": "create_account", "type_i": 0,`+"\n"+`"starting_balance": "%d.0000000"}`, i, i, i)
+			case 3:
+				record = fmt.Sprintf(`{"id": "%d", "paging_token": "%d", "type": "account_merge", "type_i": 8,`+"\n"+`"into": "GDEST"}`, i, i)
+			default:
+				record = fmt.Sprintf(`{"id": "%d", "paging_token": "%d", "type": "payment", "type_i": 1, "amount": "%d.0000000",`+"\n"+`"asset_type": "native"}`, i, i, i)
+			}
+		}
+		// multi line data fields are joined with newlines
+		fmt.Fprintf(w, "id: %d\ndata: %s\n\n", i, strings.Replace(record, "\n", "\ndata: ", -1))
+	}
+	if start > 3 {
+		// nothing left, hold the connection open until the client goes away
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		return
+	}
+	fmt.Fprint(w, "event: close\ndata: \"byebye\"\n\n")
+}
+
+var errStopStream = errors.New("stop")
+
+func withStreamHorizon(t *testing.T) (*streamHorizonServer, func()) {
+	fake := &streamHorizonServer{}
+	_, restore := withFakeHorizon(t, fake)
+	prevDelay := StreamRetryDelay
+	StreamRetryDelay = time.Millisecond
+	return fake, func() {
+		StreamRetryDelay = prevDelay
+		restore()
+	}
+}
+
+func TestStreamPayments(t *testing.T) {
+	fake, done := withStreamHorizon(t)
+	defer done()
+
+	var payments []Operation
+	acct := NewAccount("GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO")
+	err := acct.StreamPayments(context.Background(), "", func(p Operation) error {
+		payments = append(payments, p)
+		if len(payments) == 3 {
+			return errStopStream
+		}
+		return nil
+	})
+	require.Equal(t, errStopStream, err)
+	require.Len(t, payments, 3)
+	for i, p := range payments {
+		require.Equal(t, fmt.Sprintf("%d", i+1), p.PagingToken)
+		require.Equal(t, fmt.Sprintf("%d", i+1), p.Details.PagingToken())
+	}
+	create, ok := payments[0].Details.(operations.CreateAccount)
+	require.True(t, ok)
+	require.Equal(t, "1.0000000", create.StartingBalance)
+	payment, ok := payments[1].Details.(operations.Payment)
+	require.True(t, ok)
+	require.Equal(t, "2.0000000", payment.Amount)
+	merge, ok := payments[2].Details.(operations.AccountMerge)
+	require.True(t, ok)
+	require.Equal(t, "GDEST", merge.Into)
+	// first connection, failed reconnect, reconnect from the last record
+	require.Equal(t, []string{"now", "2", "2"}, fake.cursors)
+
+	err = NewAccount("missing").StreamPayments(context.Background(), "", func(p Operation) error { return nil })
+	require.Equal(t, ErrResourceNotFound, err)
+}
+
+func TestStreamClient(t *testing.T) {
+	transport := &http.Transport{}
+	hc := &http.Client{Timeout: 30 * time.Second, Transport: transport}
+	sc, ok := streamClient(hc).(*http.Client)
+	require.True(t, ok)
+	require.Equal(t, time.Duration(0), sc.Timeout)
+	require.True(t, sc.Transport == transport)
+	require.Equal(t, 30*time.Second, hc.Timeout)
+
+	require.True(t, streamClient(http.DefaultClient) == http.DefaultClient)
+}
+
+func TestStreamTransactionsAndLedgers(t *testing.T) {
+	fake, done := withStreamHorizon(t)
+	defer done()
+
+	var txs []horizonProtocol.Transaction
+	acct := NewAccount("GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO")
+	err := acct.StreamTransactions(context.Background(), "1", func(tx horizonProtocol.Transaction) error {
+		txs = append(txs, tx)
+		if len(txs) == 2 {
+			return errStopStream
+		}
+		return nil
+	})
+	require.Equal(t, errStopStream, err)
+	require.Equal(t, "tx2", txs[0].ID)
+	require.Equal(t, "tx3", txs[1].ID)
+	require.Equal(t, int32(103), txs[1].Ledger)
+
+	// the stream runs until the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	var ledgers []horizonProtocol.Ledger
+	err = StreamLedgers(ctx, "2", func(l horizonProtocol.Ledger) error {
+		ledgers = append(ledgers, l)
+		cancel()
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, ledgers, 1)
+	require.Equal(t, int32(103), ledgers[0].Sequence)
+	require.Equal(t, []string{"1", "2", "2"}, fake.cursors)
+}
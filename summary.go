@@ -1,9 +1,13 @@
 package stellarnet
 
 import (
+	"encoding/base64"
 	"fmt"
+	"math"
 	"strings"
 
+	"github.com/stellar/go/protocols/horizon/base"
+	"github.com/stellar/go/protocols/horizon/operations"
 	"github.com/stellar/go/xdr"
 )
 
@@ -105,10 +109,10 @@ func opBodySummary(op xdr.Operation, pastTense bool) string {
 		switch iop.Asset.Type {
 		case xdr.AssetTypeAssetTypeCreditAlphanum4:
 			code := iop.Asset.MustAssetCode4()
-			assetCode = string(code[:])
+			assetCode = strings.TrimRight(string(code[:]), "\x00")
 		case xdr.AssetTypeAssetTypeCreditAlphanum12:
 			code := iop.Asset.MustAssetCode12()
-			assetCode = string(code[:])
+			assetCode = strings.TrimRight(string(code[:]), "\x00")
 		default:
 			return "invalid allow trust asset code"
 		}
@@ -127,7 +131,363 @@ func opBodySummary(op xdr.Operation, pastTense bool) string {
 			return fmt.Sprintf("Remove%s data %q", past("d"), iop.DataName)
 		}
 		return fmt.Sprintf("Add%s data with key %s, hex of binary data %x", past("ed"), iop.DataName, iop.DataValue)
+	case xdr.OperationTypeInflation:
+		return tense("Run", "Ran") + " inflation"
+	case xdr.OperationTypeBumpSequence:
+		iop := op.Body.MustBumpSequenceOp()
+		return fmt.Sprintf("Bump%s sequence number to %d", past("ed"), iop.BumpTo)
+	case xdr.OperationTypeManageBuyOffer:
+		iop := op.Body.MustManageBuyOfferOp()
+		switch {
+		case iop.OfferId == 0:
+			return fmt.Sprintf("Create%s offer buying %s for %s to sell %s", past("d"), XDRAssetAmountSummary(iop.BuyAmount, iop.Buying), iop.Price.String(), XDRAssetSummary(iop.Selling))
+		case iop.BuyAmount == 0:
+			return fmt.Sprintf("Remove%s offer buying %s for %s to sell %s (id %d)", past("d"), XDRAssetSummary(iop.Buying), iop.Price.String(), XDRAssetSummary(iop.Selling), iop.OfferId)
+		default:
+			return fmt.Sprintf("Update%s offer buying %s for %s to sell %s (id %d)", past("d"), XDRAssetAmountSummary(iop.BuyAmount, iop.Buying), iop.Price.String(), XDRAssetSummary(iop.Selling), iop.OfferId)
+		}
+	case xdr.OperationTypeCreateClaimableBalance:
+		iop := op.Body.MustCreateClaimableBalanceOp()
+		return fmt.Sprintf("Create%s claimable balance of %s for %s", past("d"), XDRAssetAmountSummary(iop.Amount, iop.Asset), claimantsSummary(len(iop.Claimants)))
+	case xdr.OperationTypeClaimClaimableBalance:
+		iop := op.Body.MustClaimClaimableBalanceOp()
+		return fmt.Sprintf("Claim%s claimable balance %s", past("ed"), xdrBalanceIDSummary(iop.BalanceId))
+	case xdr.OperationTypeBeginSponsoringFutureReserves:
+		iop := op.Body.MustBeginSponsoringFutureReservesOp()
+		return fmt.Sprintf("%s sponsoring future reserves of %s", tense("Begin", "Began"), iop.SponsoredId.Address())
+	case xdr.OperationTypeEndSponsoringFutureReserves:
+		return fmt.Sprintf("End%s sponsoring future reserves", past("ed"))
+	case xdr.OperationTypeRevokeSponsorship:
+		iop := op.Body.MustRevokeSponsorshipOp()
+		return fmt.Sprintf("Revoke%s sponsorship of %s", past("d"), xdrRevokeSponsorshipSummary(iop))
+	case xdr.OperationTypeClawback:
+		iop := op.Body.MustClawbackOp()
+		return fmt.Sprintf("%s back %s from %s", tense("Claw", "Clawed"), XDRAssetAmountSummary(iop.Amount, iop.Asset), iop.From.Address())
+	case xdr.OperationTypeClawbackClaimableBalance:
+		iop := op.Body.MustClawbackClaimableBalanceOp()
+		return fmt.Sprintf("%s back claimable balance %s", tense("Claw", "Clawed"), xdrBalanceIDSummary(iop.BalanceId))
+	case xdr.OperationTypeSetTrustLineFlags:
+		iop := op.Body.MustSetTrustLineFlagsOp()
+		return fmt.Sprintf("Update%s trust line flags of %s for %s: %s", past("d"), XDRAssetSummary(iop.Asset), iop.Trustor.Address(),
+			trustLineFlagsSummary(trustLineFlagBits(uint32(iop.SetFlags)), trustLineFlagBits(uint32(iop.ClearFlags))))
+	case xdr.OperationTypeLiquidityPoolDeposit:
+		iop := op.Body.MustLiquidityPoolDepositOp()
+		return fmt.Sprintf("Deposit%s at most %s and %s into liquidity pool %x", past("ed"), StringFromStellarXdrAmount(iop.MaxAmountA), StringFromStellarXdrAmount(iop.MaxAmountB), iop.LiquidityPoolId[:])
+	case xdr.OperationTypeLiquidityPoolWithdraw:
+		iop := op.Body.MustLiquidityPoolWithdrawOp()
+		return fmt.Sprintf("%s %s pool shares from liquidity pool %x", tense("Withdraw", "Withdrew"), StringFromStellarXdrAmount(iop.Amount), iop.LiquidityPoolId[:])
 	default:
 		return "invalid operation type"
 	}
 }
+
+// HorizonOpSummary returns a string summary of an operation from
+// horizon, like OpSummary does for xdr operations.  op is one of the
+// operations types, for example Operation.Details.
+//
+// Horizon operations always have a source account, so unlike OpSummary
+// the summary doesn't include it.
+func HorizonOpSummary(op operations.Operation, pastTense bool) string {
+	past := func(suffix string) string {
+		if pastTense {
+			return suffix
+		}
+		return ""
+	}
+	tense := func(present, past string) string {
+		if pastTense {
+			return past
+		}
+		return present
+	}
+	switch iop := op.(type) {
+	case operations.CreateAccount:
+		return fmt.Sprintf("Create%s account %s with starting balance of %s XLM", past("d"), iop.Account, iop.StartingBalance)
+	case operations.Payment:
+		return fmt.Sprintf("%s %s to account %s", tense("Pay", "Paid"), horizonAssetAmountSummary(iop.Amount, iop.Asset), iop.To)
+	case operations.PathPayment:
+		return fmt.Sprintf("%s %s to account %s using at most %s", tense("Pay", "Paid"), horizonAssetAmountSummary(iop.Amount, iop.Asset), iop.To,
+			horizonAssetAmountSummary(iop.SourceMax, base.Asset{Type: iop.SourceAssetType, Code: iop.SourceAssetCode, Issuer: iop.SourceAssetIssuer}))
+	case operations.PathPaymentStrictSend:
+		return fmt.Sprintf("%s at least %s to account %s using %s", tense("Pay", "Paid"), horizonAssetAmountSummary(iop.DestinationMin, iop.Asset), iop.To,
+			horizonAssetAmountSummary(iop.SourceAmount, base.Asset{Type: iop.SourceAssetType, Code: iop.SourceAssetCode, Issuer: iop.SourceAssetIssuer}))
+	case operations.ManageSellOffer:
+		selling, buying := horizonOfferAssets(iop.Offer)
+		switch {
+		case iop.OfferID == 0:
+			return fmt.Sprintf("Create%s offer selling %s %s for %s to buy %s", past("d"), iop.Amount, selling, iop.Price, buying)
+		case horizonAmountZero(iop.Amount):
+			return fmt.Sprintf("Remove%s offer selling %s for %s to buy %s (id %d)", past("d"), selling, iop.Price, buying, iop.OfferID)
+		default:
+			return fmt.Sprintf("Update%s offer selling %s %s for %s to buy %s (id %d)", past("d"), iop.Amount, selling, iop.Price, buying, iop.OfferID)
+		}
+	case operations.ManageBuyOffer:
+		selling, buying := horizonOfferAssets(iop.Offer)
+		switch {
+		case iop.OfferID == 0:
+			return fmt.Sprintf("Create%s offer buying %s %s for %s to sell %s", past("d"), iop.Amount, buying, iop.Price, selling)
+		case horizonAmountZero(iop.Amount):
+			return fmt.Sprintf("Remove%s offer buying %s for %s to sell %s (id %d)", past("d"), buying, iop.Price, selling, iop.OfferID)
+		default:
+			return fmt.Sprintf("Update%s offer buying %s %s for %s to sell %s (id %d)", past("d"), iop.Amount, buying, iop.Price, selling, iop.OfferID)
+		}
+	case operations.CreatePassiveSellOffer:
+		selling, buying := horizonOfferAssets(iop.Offer)
+		if horizonAmountZero(iop.Amount) {
+			return fmt.Sprintf("Remove%s passive offer selling %s for %s to buy %s", past("d"), selling, iop.Price, buying)
+		}
+		return fmt.Sprintf("Create%s passive offer selling %s %s for %s to buy %s", past("d"), iop.Amount, selling, iop.Price, buying)
+	case operations.SetOptions:
+		var all []string
+		if iop.InflationDest != "" {
+			all = append(all, fmt.Sprintf("Set inflation destination to %s", iop.InflationDest))
+		}
+		if len(iop.ClearFlags) > 0 {
+			all = append(all, fmt.Sprintf("Clear%s account flags %b", past("ed"), flagBits(iop.ClearFlags)))
+		}
+		if len(iop.SetFlags) > 0 {
+			all = append(all, fmt.Sprintf("Set account flags %b", flagBits(iop.SetFlags)))
+		}
+		if iop.MasterKeyWeight != nil {
+			all = append(all, fmt.Sprintf("Set master key weight to %d", *iop.MasterKeyWeight))
+		}
+		if iop.LowThreshold != nil {
+			all = append(all, fmt.Sprintf("Set low threshold to %d", *iop.LowThreshold))
+		}
+		if iop.MedThreshold != nil {
+			all = append(all, fmt.Sprintf("Set medium threshold to %d", *iop.MedThreshold))
+		}
+		if iop.HighThreshold != nil {
+			all = append(all, fmt.Sprintf("Set high threshold to %d", *iop.HighThreshold))
+		}
+		if iop.HomeDomain != "" {
+			all = append(all, fmt.Sprintf("Set home domain to %q", iop.HomeDomain))
+		}
+		if iop.SignerKey != "" {
+			var weight int
+			if iop.SignerWeight != nil {
+				weight = *iop.SignerWeight
+			}
+			all = append(all, fmt.Sprintf("Set signer key %s with weight %d", iop.SignerKey, weight))
+		}
+		return strings.Join(all, "\n")
+	case operations.ChangeTrust:
+		var line string
+		if iop.LiquidityPoolID != "" {
+			line = "liquidity pool " + iop.LiquidityPoolID
+		} else {
+			line = horizonAssetSummary(iop.Asset)
+		}
+		if horizonAmountZero(iop.Limit) {
+			return fmt.Sprintf("Remove%s trust line to %s", past("d"), line)
+		}
+		if iop.Limit == StringFromStellarAmount(math.MaxInt64) {
+			return fmt.Sprintf("Establish%s trust line to %s", past("ed"), line)
+		}
+		return fmt.Sprintf("Establish%s trust line to %s with limit %s", past("ed"), line, iop.Limit)
+	case operations.AllowTrust:
+		if iop.Authorize || iop.AuthorizeToMaintainLiabilities {
+			return fmt.Sprintf("Authorize%s trustline to %s for %s", past("d"), iop.Code, iop.Trustor)
+		}
+		return fmt.Sprintf("Deauthorize%s trustline to %s for %s", past("d"), iop.Code, iop.Trustor)
+	case operations.AccountMerge:
+		return fmt.Sprintf("Merge%s account into %s", past("d"), iop.Into)
+	case operations.Inflation:
+		return tense("Run", "Ran") + " inflation"
+	case operations.ManageData:
+		if iop.Value == "" {
+			return fmt.Sprintf("Remove%s data %q", past("d"), iop.Name)
+		}
+		value, err := base64.StdEncoding.DecodeString(iop.Value)
+		if err != nil {
+			return "invalid data value"
+		}
+		return fmt.Sprintf("Add%s data with key %s, hex of binary data %x", past("ed"), iop.Name, value)
+	case operations.BumpSequence:
+		return fmt.Sprintf("Bump%s sequence number to %s", past("ed"), iop.BumpTo)
+	case operations.CreateClaimableBalance:
+		return fmt.Sprintf("Create%s claimable balance of %s %s for %s", past("d"), iop.Amount, horizonAssetStringSummary(iop.Asset), claimantsSummary(len(iop.Claimants)))
+	case operations.ClaimClaimableBalance:
+		return fmt.Sprintf("Claim%s claimable balance %s", past("ed"), iop.BalanceID)
+	case operations.BeginSponsoringFutureReserves:
+		return fmt.Sprintf("%s sponsoring future reserves of %s", tense("Begin", "Began"), iop.SponsoredID)
+	case operations.EndSponsoringFutureReserves:
+		return fmt.Sprintf("End%s sponsoring future reserves", past("ed"))
+	case operations.RevokeSponsorship:
+		return fmt.Sprintf("Revoke%s sponsorship of %s", past("d"), horizonRevokeSponsorshipSummary(iop))
+	case operations.Clawback:
+		return fmt.Sprintf("%s back %s from %s", tense("Claw", "Clawed"), horizonAssetAmountSummary(iop.Amount, iop.Asset), iop.From)
+	case operations.ClawbackClaimableBalance:
+		return fmt.Sprintf("%s back claimable balance %s", tense("Claw", "Clawed"), iop.BalanceID)
+	case operations.SetTrustLineFlags:
+		return fmt.Sprintf("Update%s trust line flags of %s for %s: %s", past("d"), horizonAssetSummary(iop.Asset), iop.Trustor,
+			trustLineFlagsSummary(iop.SetFlags, iop.ClearFlags))
+	case operations.LiquidityPoolDeposit:
+		var amounts []string
+		for _, r := range iop.ReservesMax {
+			amounts = append(amounts, r.Amount+" "+horizonAssetStringSummary(r.Asset))
+		}
+		return fmt.Sprintf("Deposit%s at most %s into liquidity pool %s", past("ed"), strings.Join(amounts, " and "), iop.LiquidityPoolID)
+	case operations.LiquidityPoolWithdraw:
+		return fmt.Sprintf("%s %s pool shares from liquidity pool %s", tense("Withdraw", "Withdrew"), iop.Shares, iop.LiquidityPoolID)
+	default:
+		return "invalid operation type"
+	}
+}
+
+// Summary returns a string summary of the operation using HorizonOpSummary.
+func (o Operation) Summary(pastTense bool) string {
+	if o.Details == nil {
+		return "unknown operation type " + o.Type
+	}
+	return HorizonOpSummary(o.Details, pastTense)
+}
+
+func claimantsSummary(n int) string {
+	if n == 1 {
+		return "1 claimant"
+	}
+	return fmt.Sprintf("%d claimants", n)
+}
+
+func xdrBalanceIDSummary(id xdr.ClaimableBalanceId) string {
+	s, err := xdr.MarshalHex(id)
+	if err != nil {
+		return "invalid balance id"
+	}
+	return s
+}
+
+func xdrRevokeSponsorshipSummary(op xdr.RevokeSponsorshipOp) string {
+	if op.Type == xdr.RevokeSponsorshipTypeRevokeSponsorshipSigner {
+		return fmt.Sprintf("signer %s of account %s", op.Signer.SignerKey.Address(), op.Signer.AccountId.Address())
+	}
+	key := op.LedgerKey
+	switch key.Type {
+	case xdr.LedgerEntryTypeAccount:
+		return "account " + key.Account.AccountId.Address()
+	case xdr.LedgerEntryTypeTrustline:
+		var line string
+		if key.TrustLine.Asset.Type == xdr.AssetTypeAssetTypePoolShare {
+			line = fmt.Sprintf("liquidity pool %x", key.TrustLine.Asset.LiquidityPoolId[:])
+		} else {
+			line = XDRAssetSummary(key.TrustLine.Asset.ToAsset())
+		}
+		return fmt.Sprintf("trust line to %s of account %s", line, key.TrustLine.AccountId.Address())
+	case xdr.LedgerEntryTypeOffer:
+		return fmt.Sprintf("offer %d of account %s", key.Offer.OfferId, key.Offer.SellerId.Address())
+	case xdr.LedgerEntryTypeData:
+		return fmt.Sprintf("data %q of account %s", key.Data.DataName, key.Data.AccountId.Address())
+	case xdr.LedgerEntryTypeClaimableBalance:
+		return "claimable balance " + xdrBalanceIDSummary(key.ClaimableBalance.BalanceId)
+	case xdr.LedgerEntryTypeLiquidityPool:
+		return fmt.Sprintf("liquidity pool %x", key.LiquidityPool.LiquidityPoolId[:])
+	default:
+		return "invalid ledger entry"
+	}
+}
+
+func horizonRevokeSponsorshipSummary(op operations.RevokeSponsorship) string {
+	switch {
+	case op.SignerKey != nil && op.SignerAccountID != nil:
+		return fmt.Sprintf("signer %s of account %s", *op.SignerKey, *op.SignerAccountID)
+	case op.TrustlineAccountID != nil && op.TrustlineLiquidityPoolID != nil:
+		return fmt.Sprintf("trust line to liquidity pool %s of account %s", *op.TrustlineLiquidityPoolID, *op.TrustlineAccountID)
+	case op.TrustlineAccountID != nil && op.TrustlineAsset != nil:
+		return fmt.Sprintf("trust line to %s of account %s", horizonAssetStringSummary(*op.TrustlineAsset), *op.TrustlineAccountID)
+	case op.OfferID != nil:
+		// horizon doesn't include the seller
+		return fmt.Sprintf("offer %d", *op.OfferID)
+	case op.DataAccountID != nil && op.DataName != nil:
+		return fmt.Sprintf("data %q of account %s", *op.DataName, *op.DataAccountID)
+	case op.ClaimableBalanceID != nil:
+		return "claimable balance " + *op.ClaimableBalanceID
+	case op.AccountID != nil:
+		return "account " + *op.AccountID
+	default:
+		return "unknown ledger entry"
+	}
+}
+
+// trustLineFlagNames are the names horizon uses for the trust line
+// flags, in flag order.
+var trustLineFlagNames = []string{"authorized", "authorized_to_maintain_liabilities", "clawback_enabled"}
+
+// trustLineFlagBits returns the set bits in flags.
+func trustLineFlagBits(flags uint32) []int {
+	var bits []int
+	for bit := 1; bit <= 1<<uint(len(trustLineFlagNames)-1); bit <<= 1 {
+		if flags&uint32(bit) != 0 {
+			bits = append(bits, bit)
+		}
+	}
+	return bits
+}
+
+func trustLineFlagsSummary(set, clear []int) string {
+	names := func(bits []int) string {
+		var all []string
+		for _, bit := range bits {
+			name := fmt.Sprintf("%d", bit)
+			for i, n := range trustLineFlagNames {
+				if bit == 1<<uint(i) {
+					name = n
+				}
+			}
+			all = append(all, name)
+		}
+		return strings.Join(all, ", ")
+	}
+	var all []string
+	if len(set) > 0 {
+		all = append(all, "set "+names(set))
+	}
+	if len(clear) > 0 {
+		all = append(all, "clear "+names(clear))
+	}
+	if len(all) == 0 {
+		return "no changes"
+	}
+	return strings.Join(all, "; ")
+}
+
+// flagBits returns the flags horizon lists separately as a bit mask.
+func flagBits(flags []int) int {
+	var bits int
+	for _, f := range flags {
+		bits |= f
+	}
+	return bits
+}
+
+func horizonAssetSummary(a base.Asset) string {
+	return AssetBaseSummary(AssetMinimal{AssetType: a.Type, AssetCode: a.Code, AssetIssuer: a.Issuer})
+}
+
+func horizonAssetAmountSummary(amount string, a base.Asset) string {
+	return amount + " " + horizonAssetSummary(a)
+}
+
+// horizonAssetStringSummary returns a summary of an asset in horizon's
+// "native" or "CODE:ISSUER" form.
+func horizonAssetStringSummary(s string) string {
+	if s == "native" {
+		return "XLM"
+	}
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return s
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+func horizonOfferAssets(o operations.Offer) (selling, buying string) {
+	selling = horizonAssetSummary(base.Asset{Type: o.SellingAssetType, Code: o.SellingAssetCode, Issuer: o.SellingAssetIssuer})
+	buying = horizonAssetSummary(base.Asset{Type: o.BuyingAssetType, Code: o.BuyingAssetCode, Issuer: o.BuyingAssetIssuer})
+	return selling, buying
+}
+
+func horizonAmountZero(amount string) bool {
+	v, err := ParseStellarAmount(amount)
+	return err == nil && v == 0
+}
@@ -1,9 +1,12 @@
 package stellarnet
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/stellar/go/protocols/horizon/operations"
 	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
 )
 
 var summarytests = []struct {
@@ -81,3 +84,163 @@ func TestOpSummary(t *testing.T) {
 		}
 	}
 }
+
+const testSummaryAccount = "GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT"
+
+var horizonSummaryTests = []struct {
+	json           string
+	summaryPresent string
+	summaryPast    string
+}{
+	{
+		`{"type": "payment", "type_i": 1, "asset_type": "credit_alphanum4", "asset_code": "EFGH", "asset_issuer": "GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT", "from": "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", "to": "GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT", "amount": "13.0000000"}`,
+		"Pay 13.0000000 EFGH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT to account GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT",
+		"Paid 13.0000000 EFGH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT to account GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT",
+	},
+	{
+		// older horizons don't send type_i and use the old type names
+		`{"type": "path_payment", "asset_type": "native", "to": "GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT", "amount": "10.0000000", "source_max": "200.0000000", "source_amount": "150.0000000", "source_asset_type": "credit_alphanum4", "source_asset_code": "ABCD", "source_asset_issuer": "GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT"}`,
+		"Pay 10.0000000 XLM to account GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT using at most 200.0000000 ABCD/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT",
+		"Paid 10.0000000 XLM to account GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT using at most 200.0000000 ABCD/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT",
+	},
+	{
+		`{"type": "manage_sell_offer", "type_i": 3, "amount": "0.0000000", "price": "1.3400000", "offer_id": "77", "selling_asset_type": "native", "buying_asset_type": "credit_alphanum4", "buying_asset_code": "QWER", "buying_asset_issuer": "GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT"}`,
+		"Remove offer selling XLM for 1.3400000 to buy QWER/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT (id 77)",
+		"Removed offer selling XLM for 1.3400000 to buy QWER/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT (id 77)",
+	},
+	{
+		`{"type": "manage_buy_offer", "type_i": 12, "amount": "5.0000000", "price": "2.0000000", "offer_id": "0", "selling_asset_type": "native", "buying_asset_type": "credit_alphanum4", "buying_asset_code": "QWER", "buying_asset_issuer": "GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT"}`,
+		"Create offer buying 5.0000000 QWER/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT for 2.0000000 to sell XLM",
+		"Created offer buying 5.0000000 QWER/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT for 2.0000000 to sell XLM",
+	},
+	{
+		`{"type": "set_options", "type_i": 5, "home_domain": "keybase.io", "set_flags": [1, 2], "signer_key": "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", "signer_weight": 1}`,
+		"Set account flags 11\nSet home domain to \"keybase.io\"\nSet signer key GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB with weight 1",
+		"Set account flags 11\nSet home domain to \"keybase.io\"\nSet signer key GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB with weight 1",
+	},
+	{
+		`{"type": "change_trust", "type_i": 6, "asset_type": "credit_alphanum4", "asset_code": "BLAH", "asset_issuer": "GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT", "limit": "922337203685.4775807", "trustor": "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB"}`,
+		"Establish trust line to BLAH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT",
+		"Established trust line to BLAH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT",
+	},
+	{
+		`{"type": "account_merge", "type_i": 8, "account": "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", "into": "GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT"}`,
+		"Merge account into GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT",
+		"Merged account into GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT",
+	},
+	{
+		`{"type": "manage_data", "type_i": 10, "name": "config", "value": "AQI="}`,
+		"Add data with key config, hex of binary data 0102",
+		"Added data with key config, hex of binary data 0102",
+	},
+	{
+		`{"type": "create_claimable_balance", "type_i": 14, "asset": "BLAH:GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT", "amount": "3.0000000", "claimants": [{"destination": "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", "predicate": {"unconditional": true}}]}`,
+		"Create claimable balance of 3.0000000 BLAH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT for 1 claimant",
+		"Created claimable balance of 3.0000000 BLAH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT for 1 claimant",
+	},
+	{
+		`{"type": "claim_claimable_balance", "type_i": 15, "balance_id": "00000000da0d57da7d4850e7fc10d2a9d0ebc731f7afb40574c03395b17d49149b91f5be", "claimant": "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB"}`,
+		"Claim claimable balance 00000000da0d57da7d4850e7fc10d2a9d0ebc731f7afb40574c03395b17d49149b91f5be",
+		"Claimed claimable balance 00000000da0d57da7d4850e7fc10d2a9d0ebc731f7afb40574c03395b17d49149b91f5be",
+	},
+	{
+		`{"type": "revoke_sponsorship", "type_i": 18, "trustline_account_id": "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", "trustline_asset": "BLAH:GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT"}`,
+		"Revoke sponsorship of trust line to BLAH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT of account GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB",
+		"Revoked sponsorship of trust line to BLAH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT of account GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB",
+	},
+	{
+		`{"type": "clawback", "type_i": 19, "asset_type": "credit_alphanum4", "asset_code": "BLAH", "asset_issuer": "GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT", "from": "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", "amount": "1.5000000"}`,
+		"Claw back 1.5000000 BLAH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT from GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB",
+		"Clawed back 1.5000000 BLAH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT from GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB",
+	},
+	{
+		`{"type": "set_trust_line_flags", "type_i": 21, "asset_type": "credit_alphanum4", "asset_code": "BLAH", "asset_issuer": "GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT", "trustor": "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB", "set_flags": [1], "clear_flags": [4]}`,
+		"Update trust line flags of BLAH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT for GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB: set authorized; clear clawback_enabled",
+		"Updated trust line flags of BLAH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT for GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB: set authorized; clear clawback_enabled",
+	},
+	{
+		`{"type": "liquidity_pool_deposit", "type_i": 22, "liquidity_pool_id": "abcd", "reserves_max": [{"asset": "native", "amount": "10.0000000"}, {"asset": "BLAH:GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT", "amount": "20.0000000"}], "min_price": "0.4", "max_price": "0.6"}`,
+		"Deposit at most 10.0000000 XLM and 20.0000000 BLAH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT into liquidity pool abcd",
+		"Deposited at most 10.0000000 XLM and 20.0000000 BLAH/GDRK3SOWNZ43XFSBSQQVHKMPPNFRZYHIEFO4DIK2WP6PLDL4LICSIPAT into liquidity pool abcd",
+	},
+}
+
+func TestHorizonOpSummary(t *testing.T) {
+	for i, test := range horizonSummaryTests {
+		var op Operation
+		if err := json.Unmarshal([]byte(test.json), &op); err != nil {
+			t.Errorf("test %d: unmarshal error %s", i, err)
+			continue
+		}
+		if op.Details == nil {
+			t.Errorf("test %d: no details for %s", i, op.Type)
+			continue
+		}
+		if summary := op.Summary(false); summary != test.summaryPresent {
+			t.Errorf("test %d: summaryPresent: %q, expected %q", i, summary, test.summaryPresent)
+		}
+		if summary := op.Summary(true); summary != test.summaryPast {
+			t.Errorf("test %d: summaryPast: %q, expected %q", i, summary, test.summaryPast)
+		}
+	}
+
+	var op Operation
+	require.NoError(t, json.Unmarshal([]byte(horizonSummaryTests[1].json), &op))
+	pp, ok := op.Details.(operations.PathPayment)
+	require.True(t, ok)
+	require.Equal(t, "150.0000000", pp.SourceAmount)
+	require.Equal(t, "10.0000000", op.Amount)
+
+	// unknown types decode without details
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "invoke_host_function", "type_i": 24}`), &op))
+	require.Nil(t, op.Details)
+	require.Equal(t, "unknown operation type invoke_host_function", op.Summary(false))
+
+	// so do records that don't decode into their horizon type, without
+	// failing the rest of the page
+	var page OperationsPage
+	require.NoError(t, json.Unmarshal([]byte(`{"_embedded": {"records": [
+		{"id": "1", "type": "payment", "type_i": 1, "asset_code": 12},
+		`+horizonSummaryTests[1].json+`]}}`), &page))
+	require.Len(t, page.Embedded.Records, 2)
+	require.Equal(t, "1", page.Embedded.Records[0].ID)
+	require.Nil(t, page.Embedded.Records[0].Details)
+	require.NotNil(t, page.Embedded.Records[1].Details)
+}
+
+func TestOpSummaryNewTypes(t *testing.T) {
+	trustor := xdr.MustAddress("GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB")
+	asset := xdr.MustNewCreditAsset("BLAH", testSummaryAccount)
+	balanceID := xdr.ClaimableBalanceId{Type: xdr.ClaimableBalanceIdTypeClaimableBalanceIdTypeV0, V0: &xdr.Hash{1}}
+	// the xdr and horizon summaries agree
+	tests := []struct {
+		body xdr.OperationBody
+		json string
+	}{
+		{
+			xdr.OperationBody{Type: xdr.OperationTypeClawback, ClawbackOp: &xdr.ClawbackOp{Asset: asset, From: trustor.ToMuxedAccount(), Amount: 15000000}},
+			horizonSummaryTests[11].json,
+		},
+		{
+			xdr.OperationBody{Type: xdr.OperationTypeSetTrustLineFlags, SetTrustLineFlagsOp: &xdr.SetTrustLineFlagsOp{Trustor: trustor, Asset: asset, SetFlags: 1, ClearFlags: 4}},
+			horizonSummaryTests[12].json,
+		},
+		{
+			xdr.OperationBody{Type: xdr.OperationTypeManageBuyOffer, ManageBuyOfferOp: &xdr.ManageBuyOfferOp{Selling: xdr.MustNewNativeAsset(), Buying: xdr.MustNewCreditAsset("QWER", testSummaryAccount), BuyAmount: 50000000, Price: xdr.Price{N: 2, D: 1}}},
+			horizonSummaryTests[3].json,
+		},
+		{
+			xdr.OperationBody{Type: xdr.OperationTypeClaimClaimableBalance, ClaimClaimableBalanceOp: &xdr.ClaimClaimableBalanceOp{BalanceId: balanceID}},
+			`{"type": "claim_claimable_balance", "type_i": 15, "balance_id": "000000000100000000000000000000000000000000000000000000000000000000000000"}`,
+		},
+	}
+	for i, test := range tests {
+		var op Operation
+		require.NoError(t, json.Unmarshal([]byte(test.json), &op))
+		for _, past := range []bool{false, true} {
+			require.Equal(t, op.Summary(past), OpSummary(xdr.Operation{Body: test.body}, past), "test %d", i)
+		}
+	}
+
+	require.Equal(t, "Bumped sequence number to 12345", OpSummary(xdr.Operation{Body: xdr.OperationBody{Type: xdr.OperationTypeBumpSequence, BumpSequenceOp: &xdr.BumpSequenceOp{BumpTo: 12345}}}, true))
+}
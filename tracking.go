@@ -0,0 +1,284 @@
+package stellarnet
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	perrors "github.com/pkg/errors"
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/network"
+	horizonProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/xdr"
+)
+
+// TxState is the state of a tracked transaction.
+type TxState string
+
+// The states of a tracked transaction.  All but TxStatePending are
+// final.
+const (
+	// TxStatePending means the transaction isn't in a ledger yet but
+	// still could be.
+	TxStatePending TxState = "pending"
+	// TxStateSuccess means the transaction is in a ledger and
+	// succeeded.
+	TxStateSuccess TxState = "success"
+	// TxStateFailed means the transaction is in a ledger and failed,
+	// was rejected, or can't get into a ledger anymore because another
+	// transaction used its sequence number (tx_bad_seq).
+	TxStateFailed TxState = "failed"
+	// TxStateExpired means the transaction's time bounds ended before
+	// it got into a ledger.
+	TxStateExpired TxState = "expired"
+	// TxStateUnknown means the transaction's state couldn't be
+	// determined, because waiting for it was canceled.
+	TxStateUnknown TxState = "unknown"
+)
+
+// TxStatus is the state of a tracked transaction.
+type TxStatus struct {
+	TxHash    string
+	State     TxState
+	Ledger    int32
+	ResultXDR string
+	// ResultCodes has the codes horizon gave when it rejected the
+	// transaction, or the transaction code of a transaction that
+	// failed in a ledger (ResultXDR has the operation results).
+	ResultCodes *horizonProtocol.TransactionResultCodes
+}
+
+// TrackedTx is a transaction whose state can be polled until it is
+// final.  The exported fields are enough to make a new TrackedTx with
+// TrackTx after a restart.
+type TrackedTx struct {
+	TxHash string
+	Signed string
+	Source AddressStr
+	SeqNum int64
+	// MaxTime is the upper time bound of the transaction, or zero.
+	MaxTime int64
+
+	done   chan struct{}
+	result SubmitResult
+	err    error
+}
+
+// TrackTx returns a TrackedTx for the signed transaction, without
+// submitting it.
+func TrackTx(signed string) (*TrackedTx, error) {
+	var env xdr.TransactionEnvelope
+	if err := xdr.SafeUnmarshalBase64(signed, &env); err != nil {
+		return nil, err
+	}
+	hash, err := network.HashTransactionInEnvelope(env, NetworkPassphrase())
+	if err != nil {
+		return nil, err
+	}
+	source := env.SourceAccount().ToAccountId()
+	t := &TrackedTx{
+		TxHash: hex.EncodeToString(hash[:]),
+		Signed: signed,
+		Source: AddressStr(source.Address()),
+		SeqNum: env.SeqNum(),
+	}
+	if tb := env.TimeBounds(); tb != nil {
+		t.MaxTime = int64(tb.MaxTime)
+	}
+	return t, nil
+}
+
+// SubmitAsync submits the signed transaction in the background and
+// returns a TrackedTx for it.  If record is not nil, it is called with
+// the TrackedTx before the transaction is sent so its hash can be
+// saved; if record returns an error, nothing is sent.
+func SubmitAsync(signed string, record func(*TrackedTx) error) (*TrackedTx, error) {
+	t, err := TrackTx(signed)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil {
+		if err := record(t); err != nil {
+			return nil, err
+		}
+	}
+	t.done = make(chan struct{})
+	go func() {
+		t.result, t.err = Submit(signed)
+		close(t.done)
+	}()
+	return t, nil
+}
+
+// submitted returns the result of SubmitAsync if it is done.
+func (t *TrackedTx) submitted() (res SubmitResult, done bool, err error) {
+	if t.done == nil {
+		return SubmitResult{}, false, nil
+	}
+	select {
+	case <-t.done:
+		return t.result, true, t.err
+	default:
+		return SubmitResult{}, false, nil
+	}
+}
+
+// Status checks the state of the transaction once.  It returns an
+// error if the state couldn't be checked, for example because horizon
+// is unreachable.
+func (t *TrackedTx) Status() (TxStatus, error) {
+	status, found, err := t.ledgerStatus()
+	if err != nil || found {
+		return status, err
+	}
+
+	res, done, submitErr := t.submitted()
+	if done && submitErr == nil {
+		return TxStatus{TxHash: t.TxHash, State: TxStateSuccess, Ledger: res.Ledger, ResultXDR: res.ResultXDR}, nil
+	}
+	if done {
+		if codes := submitResultCodes(submitErr); codes != nil && codes.TransactionCode != "tx_bad_seq" && !submitStuck(submitErr) {
+			return TxStatus{TxHash: t.TxHash, State: TxStateFailed, ResultCodes: codes}, nil
+		}
+	}
+
+	state := TxStatePending
+	seqno, err := Client().SequenceForAccount(t.Source.String())
+	if err != nil {
+		if err = errMapAccount(err); err != ErrSourceAccountNotFound {
+			return TxStatus{}, err
+		}
+	} else if seqno >= t.SeqNum {
+		state = TxStateFailed
+	}
+	if state == TxStatePending && t.MaxTime > 0 {
+		ledger, err := LatestLedger()
+		if err != nil {
+			return TxStatus{}, err
+		}
+		if ledger.ClosedAt.Unix() > t.MaxTime {
+			state = TxStateExpired
+		}
+	}
+	if state == TxStatePending {
+		return TxStatus{TxHash: t.TxHash, State: state}, nil
+	}
+
+	// the transaction could have gotten into a ledger since it was
+	// looked up
+	status, found, err = t.ledgerStatus()
+	if err != nil || found {
+		return status, err
+	}
+	status = TxStatus{TxHash: t.TxHash, State: state}
+	if state == TxStateFailed {
+		status.ResultCodes = &horizonProtocol.TransactionResultCodes{TransactionCode: "tx_bad_seq"}
+	}
+	return status, nil
+}
+
+// ledgerStatus returns the status of the transaction if it is in a
+// ledger.
+func (t *TrackedTx) ledgerStatus() (status TxStatus, found bool, err error) {
+	tx, err := TxDetails(t.TxHash)
+	if err == ErrResourceNotFound {
+		return TxStatus{}, false, nil
+	}
+	if err != nil {
+		return TxStatus{}, false, err
+	}
+	status = TxStatus{TxHash: t.TxHash, State: TxStateSuccess, Ledger: tx.Ledger, ResultXDR: tx.ResultXdr}
+	if !tx.Successful {
+		status.State = TxStateFailed
+		var result xdr.TransactionResult
+		if err := xdr.SafeUnmarshalBase64(tx.ResultXdr, &result); err == nil {
+			status.ResultCodes = &horizonProtocol.TransactionResultCodes{TransactionCode: transactionResultCodeString(result.Result.Code)}
+		}
+	}
+	return status, true, nil
+}
+
+// defaultTxWaitInterval is how often Wait polls when it isn't given a
+// positive interval, about once per ledger.
+const defaultTxWaitInterval = 5 * time.Second
+
+// Wait polls Status every interval (every 5 seconds if it isn't
+// positive) until the state is final.  If ctx is done first, it
+// returns TxStateUnknown and ctx's error.  Errors checking the state
+// are retried.
+func (t *TrackedTx) Wait(ctx context.Context, interval time.Duration) (TxStatus, error) {
+	if interval <= 0 {
+		interval = defaultTxWaitInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status, err := t.Status()
+		if err == nil && status.State != TxStatePending {
+			return status, nil
+		}
+		select {
+		case <-ctx.Done():
+			return TxStatus{TxHash: t.TxHash, State: TxStateUnknown}, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// submitResultCodes returns the result codes of a Submit error, if any.
+func submitResultCodes(err error) *horizonProtocol.TransactionResultCodes {
+	var herr *horizonclient.Error
+	switch xerr := perrors.Cause(err).(type) {
+	case Error:
+		herr = xerr.HorizonError
+	case *horizonclient.Error:
+		herr = xerr
+	}
+	if herr == nil {
+		return nil
+	}
+	codes, err := herr.ResultCodes()
+	if err != nil {
+		return nil
+	}
+	return codes
+}
+
+// transactionResultCodeString returns the horizon name of code.
+func transactionResultCodeString(code xdr.TransactionResultCode) string {
+	switch code {
+	case xdr.TransactionResultCodeTxFeeBumpInnerSuccess:
+		return "tx_fee_bump_inner_success"
+	case xdr.TransactionResultCodeTxSuccess:
+		return "tx_success"
+	case xdr.TransactionResultCodeTxFailed:
+		return "tx_failed"
+	case xdr.TransactionResultCodeTxTooEarly:
+		return "tx_too_early"
+	case xdr.TransactionResultCodeTxTooLate:
+		return "tx_too_late"
+	case xdr.TransactionResultCodeTxMissingOperation:
+		return "tx_missing_operation"
+	case xdr.TransactionResultCodeTxBadSeq:
+		return "tx_bad_seq"
+	case xdr.TransactionResultCodeTxBadAuth:
+		return "tx_bad_auth"
+	case xdr.TransactionResultCodeTxInsufficientBalance:
+		return "tx_insufficient_balance"
+	case xdr.TransactionResultCodeTxNoAccount:
+		return "tx_no_source_account"
+	case xdr.TransactionResultCodeTxInsufficientFee:
+		return "tx_insufficient_fee"
+	case xdr.TransactionResultCodeTxBadAuthExtra:
+		return "tx_bad_auth_extra"
+	case xdr.TransactionResultCodeTxInternalError:
+		return "tx_internal_error"
+	case xdr.TransactionResultCodeTxNotSupported:
+		return "tx_not_supported"
+	case xdr.TransactionResultCodeTxFeeBumpInnerFailed:
+		return "tx_fee_bump_inner_failed"
+	case xdr.TransactionResultCodeTxBadSponsorship:
+		return "tx_bad_sponsorship"
+	}
+	return code.String()
+}
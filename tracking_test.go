@@ -0,0 +1,178 @@
+package stellarnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+// trackingHorizon is a fake horizon for tracking transactions.
+type trackingHorizon struct {
+	sync.Mutex
+	submit   string
+	found    map[string]string
+	sequence string
+	closedAt string
+	posts    int
+}
+
+func (h *trackingHorizon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Lock()
+	defer h.Unlock()
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/transactions":
+		h.posts++
+		switch h.submit {
+		case "timeout":
+			w.WriteHeader(http.StatusGatewayTimeout)
+			fmt.Fprint(w, `{"type": "https://stellar.org/horizon-errors/timeout", "title": "Timeout", "status": 504}`)
+		case "rejected":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"type": "https://stellar.org/horizon-errors/transaction_failed", "title": "Transaction Failed", "status": 400,
+				"extras": {"result_codes": {"transaction": "tx_failed", "operations": ["op_underfunded"]}}}`)
+		}
+	case strings.HasPrefix(r.URL.Path, "/transactions/"):
+		if tx, ok := h.found[strings.TrimPrefix(r.URL.Path, "/transactions/")]; ok {
+			fmt.Fprint(w, tx)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"status": 404, "title": "Resource Missing"}`)
+	case strings.HasPrefix(r.URL.Path, "/accounts/"):
+		id := strings.TrimPrefix(r.URL.Path, "/accounts/")
+		fmt.Fprintf(w, `{"id": %q, "account_id": %q, "sequence": %q, "balances": []}`, id, id, h.sequence)
+	case r.URL.Path == "/ledgers":
+		fmt.Fprintf(w, `{"_embedded": {"records": [{"sequence": 9, "closed_at": %q}]}}`, h.closedAt)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (h *trackingHorizon) set(f func()) {
+	h.Lock()
+	defer h.Unlock()
+	f()
+}
+
+func trackingTx(t *testing.T, source *keypair.Full, maxTime int64) string {
+	tx := NewBaseTx(addressStr(t, source), &testSeqnoProv{seqno: 100}, 0)
+	tx.AddPaymentOp(addressStr(t, keypair.MustRandom()), "1")
+	if maxTime > 0 {
+		tx.AddTimeBounds(0, maxTime)
+	}
+	sig, err := tx.Sign(seedStr(t, source))
+	require.NoError(t, err)
+	return sig.Signed
+}
+
+func TestTrackTransactions(t *testing.T) {
+	fake := &trackingHorizon{submit: "timeout", found: map[string]string{}, sequence: "100", closedAt: "2021-01-01T00:00:00Z"}
+	_, restore := withFakeHorizon(t, fake)
+	defer restore()
+	source := keypair.MustRandom()
+
+	// the hash is recorded before submitting
+	_, err := SubmitAsync(trackingTx(t, source, 0), func(*TrackedTx) error { return errors.New("no storage") })
+	require.Error(t, err)
+	require.Equal(t, 0, fake.posts)
+
+	var recorded string
+	pending, err := SubmitAsync(trackingTx(t, source, 0), func(tx *TrackedTx) error {
+		recorded = tx.TxHash
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, pending.TxHash, recorded)
+	require.Equal(t, int64(101), pending.SeqNum)
+	require.Equal(t, addressStr(t, source), pending.Source)
+	<-pending.done
+
+	// horizon timed out, but the transaction isn't in a ledger yet
+	status, err := pending.Status()
+	require.NoError(t, err)
+	require.Equal(t, TxStatePending, status.State)
+
+	fake.set(func() {
+		fake.found[pending.TxHash] = fmt.Sprintf(`{"hash": %q, "ledger": 8, "successful": true, "result_xdr": "AAAA"}`, pending.TxHash)
+		fake.sequence = "101"
+	})
+	status, err = pending.Wait(context.Background(), time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, TxStatus{TxHash: pending.TxHash, State: TxStateSuccess, Ledger: 8, ResultXDR: "AAAA"}, status)
+
+	// rejected by horizon
+	fake.set(func() { fake.submit, fake.sequence = "rejected", "100" })
+	rejected, err := SubmitAsync(trackingTx(t, source, 0), nil)
+	require.NoError(t, err)
+	status, err = rejected.Wait(context.Background(), time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, TxStateFailed, status.State)
+	require.Equal(t, "tx_failed", status.ResultCodes.TransactionCode)
+	require.Equal(t, []string{"op_underfunded"}, status.ResultCodes.OperationCodes)
+
+	// failed in a ledger
+	failedTx, err := TrackTx(trackingTx(t, source, 0))
+	require.NoError(t, err)
+	result, err := xdr.MarshalBase64(xdr.TransactionResult{FeeCharged: 100, Result: xdr.TransactionResultResult{
+		Code: xdr.TransactionResultCodeTxFailed, Results: &[]xdr.OperationResult{}}})
+	require.NoError(t, err)
+	fake.set(func() {
+		fake.found[failedTx.TxHash] = fmt.Sprintf(`{"hash": %q, "ledger": 8, "successful": false, "result_xdr": %q}`, failedTx.TxHash, result)
+	})
+	status, err = failedTx.Status()
+	require.NoError(t, err)
+	require.Equal(t, TxStateFailed, status.State)
+	require.Equal(t, "tx_failed", status.ResultCodes.TransactionCode)
+
+	// another transaction used the sequence number
+	other, err := TrackTx(trackingTx(t, source, 0))
+	require.NoError(t, err)
+	fake.set(func() { fake.sequence = "101" })
+	status, err = other.Status()
+	require.NoError(t, err)
+	require.Equal(t, TxStateFailed, status.State)
+	require.Equal(t, "tx_bad_seq", status.ResultCodes.TransactionCode)
+
+	// time bounds ended
+	fake.set(func() { fake.sequence = "100" })
+	expiring, err := TrackTx(trackingTx(t, source, 1609459200))
+	require.NoError(t, err)
+	require.Equal(t, int64(1609459200), expiring.MaxTime)
+	status, err = expiring.Status()
+	require.NoError(t, err)
+	require.Equal(t, TxStatePending, status.State)
+	fake.set(func() { fake.closedAt = "2021-01-01T00:00:01Z" })
+	status, err = expiring.Status()
+	require.NoError(t, err)
+	require.Equal(t, TxStateExpired, status.State)
+
+	// waiting can be canceled
+	waiting, err := TrackTx(trackingTx(t, source, 0))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	status, err = waiting.Wait(ctx, time.Millisecond)
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.Equal(t, TxStateUnknown, status.State)
+
+	// a non-positive interval uses the default instead of panicking
+	for _, interval := range []time.Duration{0, -time.Second} {
+		status, err = pending.Wait(context.Background(), interval)
+		require.NoError(t, err)
+		require.Equal(t, TxStateSuccess, status.State)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		status, err = waiting.Wait(ctx, interval)
+		cancel()
+		require.Equal(t, context.DeadlineExceeded, err)
+		require.Equal(t, TxStateUnknown, status.State)
+	}
+}
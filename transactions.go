@@ -1,5 +1,7 @@
 package stellarnet
 
+import "fmt"
+
 // Transaction contains the TransactionEmbed from the
 // horizon transactions endpoint and all the operations
 // for the transaction.
@@ -7,3 +9,64 @@ type Transaction struct {
 	Internal   TransactionEmbed
 	Operations []Operation
 }
+
+// defaultTransactionsAndOps is the number of transactions
+// LoadTransactionsAndOps returns when opts.MaxRecords is zero.
+const defaultTransactionsAndOps = 10
+
+// LoadTransactionsAndOps returns the account's transactions with their
+// operations, loaded from the account operations endpoint with
+// join=transactions.  Operations are grouped by transaction hash, so the
+// number of horizon requests depends only on the number of operations
+// in the window, not the number of transactions.
+//
+// opts.MaxRecords is the number of transactions to return (default 10).
+// The stop conditions in opts bound the window by time or ledger.  The
+// returned cursor is the paging token of the last operation of the last
+// transaction; use it as opts.Cursor to load the next window.
+//
+// Unlike TransactionsAndOps, only the operations that involve the
+// account are included.  Operations in a transaction are in
+// transaction order whatever opts.Order is.
+func (a *Account) LoadTransactionsAndOps(opts HistoryOptions) (txs []Transaction, cursor string, err error) {
+	max := opts.MaxRecords
+	if max <= 0 {
+		max = defaultTransactionsAndOps
+	}
+	// the limit is on transactions, not operations
+	opts.MaxRecords = 0
+	cursor = opts.Cursor
+
+	it := newOperationIterator("/accounts/"+a.address.String()+"/operations?join=transactions", opts)
+	for it.Next() {
+		op := it.Operation()
+		n := len(txs)
+		if n == 0 || txs[n-1].Internal.Hash != op.TransactionHash {
+			if n == max {
+				break
+			}
+			if op.Transaction == nil {
+				return nil, "", fmt.Errorf("operation %s has no transaction", op.ID)
+			}
+			txs = append(txs, Transaction{Internal: *op.Transaction})
+			n++
+		}
+		op.Transaction = nil
+		txs[n-1].Operations = append(txs[n-1].Operations, op)
+		cursor = op.PagingToken
+	}
+	if err := it.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if opts.order() == "desc" {
+		for _, tx := range txs {
+			ops := tx.Operations
+			for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+				ops[i], ops[j] = ops[j], ops[i]
+			}
+		}
+	}
+
+	return txs, cursor, nil
+}
@@ -0,0 +1,65 @@
+package stellarnet
+
+import (
+	"fmt"
+
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTransactionsAndOps(t *testing.T) {
+	fake, done := withHistoryHorizon(t)
+	defer done()
+
+	acct := NewAccount(testHistoryAccount)
+	txs, cursor, err := acct.LoadTransactionsAndOps(HistoryOptions{MaxRecords: 3, PageSize: 4})
+	require.NoError(t, err)
+	require.Len(t, txs, 3)
+	for i, tx := range txs {
+		n := 5 - i
+		require.Equal(t, testTxHash(n), tx.Internal.Hash)
+		require.Equal(t, int32(100+n), tx.Internal.Ledger)
+		require.Len(t, tx.Operations, n)
+		// operations are in transaction order
+		require.Equal(t, tx.Internal.Hash, tx.Operations[0].TransactionHash)
+		require.Equal(t, fmt.Sprintf("%d1", n), tx.Operations[0].ID)
+		require.Nil(t, tx.Operations[0].Transaction)
+	}
+	require.Equal(t, "31", cursor)
+	// 12 operations in pages of 4, plus the page that finds the next transaction
+	require.Len(t, fake.requests, 4)
+	for _, r := range fake.requests {
+		require.Contains(t, r, "join=transactions")
+	}
+
+	// resume from the cursor
+	txs, cursor, err = acct.LoadTransactionsAndOps(HistoryOptions{Cursor: cursor, PageSize: 4})
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	require.Equal(t, testTxHash(2), txs[0].Internal.Hash)
+	require.Equal(t, testTxHash(1), txs[1].Internal.Hash)
+	require.Equal(t, "11", cursor)
+
+	// ascending, stopped by ledger
+	txs, _, err = acct.LoadTransactionsAndOps(HistoryOptions{Order: "asc", StopLedger: 102})
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	require.Equal(t, "21", txs[1].Operations[0].ID)
+	require.Equal(t, "22", txs[1].Operations[1].ID)
+}
+
+func TestTxPaymentsPages(t *testing.T) {
+	fake, done := withHistoryHorizon(t)
+	defer done()
+
+	payments, err := TxPayments(testTxHash(5))
+	require.NoError(t, err)
+	require.Len(t, payments, 5)
+	for i, p := range payments {
+		require.Equal(t, fmt.Sprintf("%d.0000000", i+1), p.Amount)
+	}
+	// one short page of the largest size
+	require.Len(t, fake.requests, 1)
+	require.Contains(t, fake.requests[0], "limit=200")
+}
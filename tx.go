@@ -31,7 +31,13 @@ type Tx struct {
 }
 
 // NewBaseTx creates a Tx with the common transaction elements.
+// If baseFee is zero, the FeeStrategy (see SetFeeStrategy) picks it.
+// baseFee is raised to txnbuild.MinBaseFee.  NewBaseTx doesn't make
+// network requests; pass MinBaseFee() for the network's current minimum.
 func NewBaseTx(source AddressStr, seqnoProvider SequenceProvider, baseFee uint64) *Tx {
+	if baseFee == 0 {
+		baseFee = strategyBaseFee()
+	}
 	if baseFee < txnbuild.MinBaseFee {
 		baseFee = txnbuild.MinBaseFee
 	}
@@ -167,7 +173,7 @@ func (t *Tx) AddAccountMergeOp(to AddressStr) {
 		return
 	}
 
-	t.addOp(xdr.OperationTypeAccountMerge, accountID)
+	t.addOp(xdr.OperationTypeAccountMerge, accountID.ToMuxedAccount())
 }
 
 // AddInflationDestinationOp adds a set_options operation for the inflation
@@ -287,6 +293,156 @@ func (t *Tx) AddDeleteTrustlineOp(assetCode string, assetIssuer AddressStr) {
 	t.addOp(xdr.OperationTypeChangeTrust, op)
 }
 
+// AddCreatePoolShareTrustlineOp adds a change_trust operation that will
+// establish a trustline to the shares of the liquidity pool for the
+// pair a, b.
+func (t *Tx) AddCreatePoolShareTrustlineOp(a, b AssetBase, limit string) {
+	if t.skipAddOp() {
+		return
+	}
+
+	asset, err := makeXDRPoolShareChangeTrustAsset(a, b)
+	if err != nil {
+		t.err = err
+		return
+	}
+
+	limitAmount, err := amount.Parse(limit)
+	if err != nil {
+		t.err = err
+		return
+	}
+
+	if limitAmount <= 0 {
+		t.err = errors.New("limit must be greater than zero to create a trustline")
+		return
+	}
+
+	op := xdr.ChangeTrustOp{
+		Line:  asset,
+		Limit: limitAmount,
+	}
+
+	t.addOp(xdr.OperationTypeChangeTrust, op)
+}
+
+// AddDeletePoolShareTrustlineOp adds a change_trust operation that will
+// remove a trustline to the shares of the liquidity pool for the pair
+// a, b.
+func (t *Tx) AddDeletePoolShareTrustlineOp(a, b AssetBase) {
+	if t.skipAddOp() {
+		return
+	}
+
+	asset, err := makeXDRPoolShareChangeTrustAsset(a, b)
+	if err != nil {
+		t.err = err
+		return
+	}
+
+	op := xdr.ChangeTrustOp{
+		Line:  asset,
+		Limit: 0,
+	}
+
+	t.addOp(xdr.OperationTypeChangeTrust, op)
+}
+
+// AddLiquidityPoolDepositOp adds a liquidity_pool_deposit operation to
+// the transaction.  The deposit fails if the pool's price (amount of
+// asset A per unit of asset B) is outside [minPrice, maxPrice].
+func (t *Tx) AddLiquidityPoolDepositOp(poolID string, maxAmountA, maxAmountB, minPrice, maxPrice string) {
+	if t.skipAddOp() {
+		return
+	}
+
+	id, err := parsePoolID(poolID)
+	if err != nil {
+		t.err = err
+		return
+	}
+
+	maxA, err := amount.Parse(maxAmountA)
+	if err != nil {
+		t.err = err
+		return
+	}
+	maxB, err := amount.Parse(maxAmountB)
+	if err != nil {
+		t.err = err
+		return
+	}
+	if maxA <= 0 || maxB <= 0 {
+		t.err = errors.New("deposit amounts must be greater than zero")
+		return
+	}
+
+	minPriceXDR, err := price.Parse(minPrice)
+	if err != nil {
+		t.err = err
+		return
+	}
+	maxPriceXDR, err := price.Parse(maxPrice)
+	if err != nil {
+		t.err = err
+		return
+	}
+
+	op := xdr.LiquidityPoolDepositOp{
+		LiquidityPoolId: id,
+		MaxAmountA:      maxA,
+		MaxAmountB:      maxB,
+		MinPrice:        minPriceXDR,
+		MaxPrice:        maxPriceXDR,
+	}
+
+	t.addOp(xdr.OperationTypeLiquidityPoolDeposit, op)
+}
+
+// AddLiquidityPoolWithdrawOp adds a liquidity_pool_withdraw operation
+// to the transaction.  The withdrawal fails if shares are worth less
+// than minAmountA of asset A or minAmountB of asset B.
+func (t *Tx) AddLiquidityPoolWithdrawOp(poolID string, shares, minAmountA, minAmountB string) {
+	if t.skipAddOp() {
+		return
+	}
+
+	id, err := parsePoolID(poolID)
+	if err != nil {
+		t.err = err
+		return
+	}
+
+	sharesXDR, err := amount.Parse(shares)
+	if err != nil {
+		t.err = err
+		return
+	}
+	if sharesXDR <= 0 {
+		t.err = errors.New("withdraw amount must be greater than zero")
+		return
+	}
+	minA, err := amount.Parse(minAmountA)
+	if err != nil {
+		t.err = err
+		return
+	}
+	minB, err := amount.Parse(minAmountB)
+	if err != nil {
+		t.err = err
+		return
+	}
+
+	op := xdr.LiquidityPoolWithdrawOp{
+		LiquidityPoolId: id,
+		Amount:          sharesXDR,
+		MinAmountA:      minA,
+		MinAmountB:      minB,
+	}
+
+	t.addOp(xdr.OperationTypeLiquidityPoolWithdraw, op)
+}
+
 // addOp adds an operation to the internal transaction.
 func (t *Tx) addOp(opType xdr.OperationType, op interface{}) {
 	body, err := xdr.NewOperationBody(opType, op)
@@ -300,6 +456,209 @@ func (t *Tx) addOp(opType xdr.OperationType, op interface{}) {
 	t.internal.Operations = append(t.internal.Operations, wop)
 }
 
+// AddPathPaymentStrictSendOp adds a path payment operation that sends
+// exactly sendAmount of sendAsset and fails if to would receive less
+// than destMin of destAsset.
+func (t *Tx) AddPathPaymentStrictSendOp(to AddressStr, sendAsset AssetBase, sendAmount string, destAsset AssetBase, destMin string, path []AssetBase) {
+	if t.skipAddOp() {
+		return
+	}
+
+	var op xdr.PathPaymentStrictSendOp
+
+	op.SendAsset, t.err = assetBaseToXDR(sendAsset)
+	if t.err != nil {
+		return
+	}
+	op.DestAsset, t.err = assetBaseToXDR(destAsset)
+	if t.err != nil {
+		return
+	}
+	op.SendAmount, t.err = amount.Parse(sendAmount)
+	if t.err != nil {
+		return
+	}
+	op.Destination, t.err = to.MuxedAccount()
+	if t.err != nil {
+		return
+	}
+	op.DestMin, t.err = amount.Parse(destMin)
+	if t.err != nil {
+		return
+	}
+
+	xdrPath := make([]xdr.Asset, len(path))
+	for i, p := range path {
+		a, err := assetBaseToXDR(p)
+		if err != nil {
+			t.err = err
+			return
+		}
+		xdrPath[i] = a
+	}
+	op.Path = xdrPath
+
+	t.addOp(xdr.OperationTypePathPaymentStrictSend, op)
+}
+
+// AddCancelOfferOp adds a manage_offer operation that deletes the
+// source account's offer offerID.
+func (t *Tx) AddCancelOfferOp(selling, buying xdr.Asset, offerID int64) {
+	if t.skipAddOp() {
+		return
+	}
+
+	op := xdr.ManageSellOfferOp{
+		Selling: selling,
+		Buying:  buying,
+		Amount:  0,
+		Price:   xdr.Price{N: 1, D: 1},
+		OfferId: xdr.Int64(offerID),
+	}
+
+	t.addOp(xdr.OperationTypeManageSellOffer, op)
+}
+
+// AddDeleteDataOp adds a manage_data operation that removes the data
+// entry name.
+func (t *Tx) AddDeleteDataOp(name string) {
+	if t.skipAddOp() {
+		return
+	}
+
+	op := xdr.ManageDataOp{DataName: xdr.String64(name)}
+
+	t.addOp(xdr.OperationTypeManageData, op)
+}
+
+// AddRemoveSignerOp adds a set_options operation that removes the
+// signer with strkey key.
+func (t *Tx) AddRemoveSignerOp(key string) {
+	if t.skipAddOp() {
+		return
+	}
+
+	var signer xdr.Signer
+	if err := signer.Key.SetAddress(key); err != nil {
+		t.err = err
+		return
+	}
+	op := xdr.SetOptionsOp{Signer: &signer}
+
+	t.addOp(xdr.OperationTypeSetOptions, op)
+}
+
+// AddCreateClaimableBalanceOp adds a create_claimable_balance operation
+// for amt of asset that to can claim at any time.
+func (t *Tx) AddCreateClaimableBalanceOp(to AddressStr, asset AssetBase, amt string) {
+	if t.skipAddOp() {
+		return
+	}
+
+	var op xdr.CreateClaimableBalanceOp
+	op.Asset, t.err = assetBaseToXDR(asset)
+	if t.err != nil {
+		return
+	}
+	op.Amount, t.err = amount.Parse(amt)
+	if t.err != nil {
+		return
+	}
+	dest, err := to.AccountID()
+	if err != nil {
+		t.err = err
+		return
+	}
+	op.Claimants = []xdr.Claimant{{
+		Type: xdr.ClaimantTypeClaimantTypeV0,
+		V0: &xdr.ClaimantV0{
+			Destination: dest,
+			Predicate:   xdr.ClaimPredicate{Type: xdr.ClaimPredicateTypeClaimPredicateUnconditional},
+		},
+	}}
+
+	t.addOp(xdr.OperationTypeCreateClaimableBalance, op)
+}
+
+// AddBeginSponsoringOp adds a begin_sponsoring_future_reserves
+// operation.  Reserves of entries created by sponsored until the
+// matching AddEndSponsoringOp are paid by the operation's source.
+func (t *Tx) AddBeginSponsoringOp(sponsored AddressStr) {
+	if t.skipAddOp() {
+		return
+	}
+
+	var op xdr.BeginSponsoringFutureReservesOp
+	op.SponsoredId, t.err = sponsored.AccountID()
+	if t.err != nil {
+		return
+	}
+
+	t.addOp(xdr.OperationTypeBeginSponsoringFutureReserves, op)
+}
+
+// AddEndSponsoringOp adds an end_sponsoring_future_reserves operation.
+// Its source must be the sponsored account.
+func (t *Tx) AddEndSponsoringOp() {
+	if t.skipAddOp() {
+		return
+	}
+
+	t.addOp(xdr.OperationTypeEndSponsoringFutureReserves, nil)
+}
+
+// AddRevokeSponsorshipOp adds a revoke_sponsorship operation for the
+// ledger entry key.  The entry's owner must be able to pay its reserve.
+func (t *Tx) AddRevokeSponsorshipOp(key xdr.LedgerKey) {
+	if t.skipAddOp() {
+		return
+	}
+
+	op := xdr.RevokeSponsorshipOp{
+		Type:      xdr.RevokeSponsorshipTypeRevokeSponsorshipLedgerEntry,
+		LedgerKey: &key,
+	}
+
+	t.addOp(xdr.OperationTypeRevokeSponsorship, op)
+}
+
+// AddRevokeSignerSponsorshipOp adds a revoke_sponsorship operation for
+// the signer with strkey signerKey of account.
+func (t *Tx) AddRevokeSignerSponsorshipOp(account AddressStr, signerKey string) {
+	if t.skipAddOp() {
+		return
+	}
+
+	var signer xdr.RevokeSponsorshipOpSigner
+	signer.AccountId, t.err = account.AccountID()
+	if t.err != nil {
+		return
+	}
+	if err := signer.SignerKey.SetAddress(signerKey); err != nil {
+		t.err = err
+		return
+	}
+	op := xdr.RevokeSponsorshipOp{
+		Type:   xdr.RevokeSponsorshipTypeRevokeSponsorshipSigner,
+		Signer: &signer,
+	}
+
+	t.addOp(xdr.OperationTypeRevokeSponsorship, op)
+}
+
+// setOpSource sets the source account of the last operation added.
+func (t *Tx) setOpSource(source AddressStr) {
+	if t.err != nil || len(t.internal.Operations) == 0 {
+		return
+	}
+	muxed, err := source.MuxedAccount()
+	if err != nil {
+		t.err = err
+		return
+	}
+	t.internal.Operations[len(t.internal.Operations)-1].SourceAccount = &muxed
+}
+
 // skipAddOp returns true if there is already a condition that
 // prevents any further Add* operations.
 func (t *Tx) skipAddOp() bool {
@@ -432,15 +791,37 @@ func (t *Tx) Sign(from SeedStr) (SignResult, error) {
 	return t.sign(from)
 }
 
-func (t *Tx) sign(signers ...SeedStr) (SignResult, error) {
+// Envelope builds the transaction and returns it in an unsigned
+// xdr.TransactionEnvelope.  This is useful when someone else
+// will be signing the transaction (in a web+stellar URI, for example).
+func (t *Tx) Envelope() (xdr.TransactionEnvelope, error) {
+	if t.err != nil {
+		return xdr.TransactionEnvelope{}, errMap(t.err)
+	}
+	if len(t.internal.Operations) == 0 {
+		return xdr.TransactionEnvelope{}, errMap(ErrNoOps)
+	}
+	if err := t.build(); err != nil {
+		return xdr.TransactionEnvelope{}, err
+	}
+	return xdr.NewTransactionEnvelope(xdr.EnvelopeTypeEnvelopeTypeTx, xdr.TransactionV1Envelope{Tx: t.internal})
+}
+
+// build fills in the sequence number, fee and source account
+// of the internal transaction.
+func (t *Tx) build() error {
 	seqno, err := t.seqnoProv.SequenceForAccount(t.source.String())
 	if err != nil {
-		return SignResult{}, err
+		return err
 	}
 	t.internal.SeqNum = xdr.SequenceNumber(seqno + 1)
 	t.internal.Fee = xdr.Uint32(t.baseFee * uint64(len(t.internal.Operations)))
 	t.internal.SourceAccount, err = t.source.MuxedAccount()
-	if err != nil {
+	return err
+}
+
+func (t *Tx) sign(signers ...SeedStr) (SignResult, error) {
+	if err := t.build(); err != nil {
 		return SignResult{}, err
 	}
 
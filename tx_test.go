@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/keybase/stellarnet/testclient"
+	"github.com/stellar/go/keypair"
 	"github.com/stellar/go/txnbuild"
 	"github.com/stretchr/testify/require"
 )
@@ -98,3 +99,15 @@ func TestMultipleOps(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, ErrNoOps, err)
 }
+
+func TestAddAccountMergeOp(t *testing.T) {
+	from := keypair.MustRandom()
+	to := keypair.MustRandom()
+	tx := NewBaseTx(addressStr(t, from), &testSeqnoProv{seqno: 100}, txnbuild.MinBaseFee)
+	tx.AddAccountMergeOp(addressStr(t, to))
+	txEnv, err := tx.Envelope()
+	require.NoError(t, err)
+	require.Len(t, txEnv.Operations(), 1)
+	destination := txEnv.Operations()[0].Body.MustDestination()
+	require.Equal(t, to.Address(), destination.Address())
+}
@@ -0,0 +1,757 @@
+package stellarnet
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+// Txrep (SEP11) is a human readable, line-oriented representation of a
+// transaction envelope.  Each line is a "key: value" pair, where the
+// key is the path to the field using the field names from the stellar
+// XDR definitions.  For example:
+//
+//	type: ENVELOPE_TYPE_TX
+//	tx.sourceAccount: GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO
+//	tx.fee: 100
+//	tx.seqNum: 46489056724385793
+//	tx.timeBounds._present: false
+//	tx.memo.type: MEMO_TEXT
+//	tx.memo.text: "Enjoy this transaction"
+//	tx.operations.len: 1
+//	tx.operations[0].sourceAccount._present: false
+//	tx.operations[0].body.type: PAYMENT
+//	tx.operations[0].body.paymentOp.destination: GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB
+//	tx.operations[0].body.paymentOp.asset: USD:GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX
+//	tx.operations[0].body.paymentOp.amount: 400004000
+//	tx.ext.v: 0
+//	signatures.len: 0
+
+// txrepMaxLen is the largest array length accepted when decoding.  No
+// array in a transaction envelope can hold more than 100 elements.
+const txrepMaxLen = 100
+
+// ErrTxrepMissingField is returned when a Txrep document is missing a
+// field that the transaction needs.
+type ErrTxrepMissingField struct {
+	Key string
+}
+
+// Error implements error for ErrTxrepMissingField.
+func (e ErrTxrepMissingField) Error() string {
+	return fmt.Sprintf("txrep missing field %q", e.Key)
+}
+
+// ErrTxrepInvalidValue is returned when a Txrep field has a value
+// that can't be decoded.
+type ErrTxrepInvalidValue struct {
+	Key string
+}
+
+// Error implements error for ErrTxrepInvalidValue.
+func (e ErrTxrepInvalidValue) Error() string {
+	return fmt.Sprintf("txrep field %q has an invalid value", e.Key)
+}
+
+// ErrTxrepUnknownField is returned when a Txrep document contains a
+// field that isn't part of the transaction.
+type ErrTxrepUnknownField struct {
+	Key string
+}
+
+// Error implements error for ErrTxrepUnknownField.
+func (e ErrTxrepUnknownField) Error() string {
+	return fmt.Sprintf("txrep field %q is not part of the transaction", e.Key)
+}
+
+// ErrTxrepInvalidLine is returned when a line of a Txrep document
+// isn't in the "key: value" format.
+type ErrTxrepInvalidLine struct {
+	Line int
+}
+
+// Error implements error for ErrTxrepInvalidLine.
+func (e ErrTxrepInvalidLine) Error() string {
+	return fmt.Sprintf("txrep line %d is invalid", e.Line)
+}
+
+// TxrepFromEnvelope returns the Txrep (SEP11) representation of txEnv.
+func TxrepFromEnvelope(txEnv xdr.TransactionEnvelope) (string, error) {
+	enc, err := newTxrepEncoding(txEnv)
+	if err != nil {
+		return "", err
+	}
+	return enc.String(), nil
+}
+
+// EnvelopeFromTxrep parses a Txrep (SEP11) document into a transaction
+// envelope.  Blank lines and lines starting with # are ignored, as is
+// anything after the value on a line.
+func EnvelopeFromTxrep(txrep string) (xdr.TransactionEnvelope, error) {
+	enc, err := parseTxrep(txrep)
+	if err != nil {
+		return xdr.TransactionEnvelope{}, err
+	}
+	return enc.envelope()
+}
+
+// txrepEncoding holds the key/value pairs of a Txrep document in
+// order.
+type txrepEncoding struct {
+	keys   []string
+	values map[string]string
+}
+
+func newTxrepEncoding(txEnv xdr.TransactionEnvelope) (*txrepEncoding, error) {
+	enc := &txrepEncoding{values: make(map[string]string)}
+	if err := enc.encode("", reflect.ValueOf(txEnv)); err != nil {
+		return nil, err
+	}
+	return enc, nil
+}
+
+func parseTxrep(txrep string) (*txrepEncoding, error) {
+	enc := &txrepEncoding{values: make(map[string]string)}
+	scanner := bufio.NewScanner(strings.NewReader(txrep))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		index := strings.Index(line, ":")
+		if index <= 0 {
+			return nil, ErrTxrepInvalidLine{Line: lineNum}
+		}
+		key := strings.TrimSpace(line[:index])
+		value, err := txrepParseValue(strings.TrimSpace(line[index+1:]))
+		if err != nil {
+			return nil, ErrTxrepInvalidValue{Key: key}
+		}
+		if _, ok := enc.values[key]; ok {
+			return nil, ErrTxrepInvalidLine{Line: lineNum}
+		}
+		enc.set(key, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return enc, nil
+}
+
+// txrepParseValue strips any comment after the value.  Strings are
+// quoted and unescaped, everything else ends at the first space.
+func txrepParseValue(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				return strconv.Unquote(s[:i+1])
+			}
+		}
+		return "", strconv.ErrSyntax
+	}
+	if fields := strings.Fields(s); len(fields) > 0 {
+		return fields[0], nil
+	}
+	return "", nil
+}
+
+// String returns the Txrep document.
+func (t *txrepEncoding) String() string {
+	var b strings.Builder
+	for _, k := range t.keys {
+		b.WriteString(k)
+		b.WriteString(": ")
+		b.WriteString(t.values[k])
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (t *txrepEncoding) set(key, value string) {
+	if _, ok := t.values[key]; !ok {
+		t.keys = append(t.keys, key)
+	}
+	t.values[key] = value
+}
+
+// envelope decodes the transaction envelope and checks that every
+// field was used.
+func (t *txrepEncoding) envelope() (xdr.TransactionEnvelope, error) {
+	dec := &txrepDecoder{values: t.values, used: make(map[string]bool)}
+	var txEnv xdr.TransactionEnvelope
+	if err := dec.decode("", reflect.ValueOf(&txEnv).Elem()); err != nil {
+		return xdr.TransactionEnvelope{}, err
+	}
+	for _, k := range t.keys {
+		if !dec.used[k] {
+			return xdr.TransactionEnvelope{}, ErrTxrepUnknownField{Key: k}
+		}
+	}
+
+	// make sure the result is valid xdr (enums in range, etc.)
+	if _, err := xdr.MarshalBase64(txEnv); err != nil {
+		return xdr.TransactionEnvelope{}, err
+	}
+
+	return txEnv, nil
+}
+
+// txrepUnion is implemented by all the generated xdr union types.
+type txrepUnion interface {
+	SwitchFieldName() string
+	ArmForSwitch(sw int32) (string, bool)
+}
+
+// txrepEnum is implemented by all the generated xdr enum types.
+type txrepEnum interface {
+	ValidEnum(v int32) bool
+	String() string
+}
+
+var (
+	txrepUnionType            = reflect.TypeOf((*txrepUnion)(nil)).Elem()
+	txrepEnumType             = reflect.TypeOf((*txrepEnum)(nil)).Elem()
+	txrepAccountIDType        = reflect.TypeOf(xdr.AccountId{})
+	txrepPublicKeyType        = reflect.TypeOf(xdr.PublicKey{})
+	txrepMuxedAccountType     = reflect.TypeOf(xdr.MuxedAccount{})
+	txrepSignerKeyType        = reflect.TypeOf(xdr.SignerKey{})
+	txrepAssetType            = reflect.TypeOf(xdr.Asset{})
+	txrepChangeTrustAssetType = reflect.TypeOf(xdr.ChangeTrustAsset{})
+	txrepTrustLineAssetType   = reflect.TypeOf(xdr.TrustLineAsset{})
+	txrepAssetCodeType        = reflect.TypeOf(xdr.AssetCode{})
+	txrepEnvelopeType         = reflect.TypeOf(xdr.TransactionEnvelope{})
+	txrepInnerTxType          = reflect.TypeOf(xdr.FeeBumpTransactionInnerTx{})
+	txrepTransactionV0Type    = reflect.TypeOf(xdr.TransactionV0{})
+)
+
+// txrepJoin adds name to the prefix path.  An empty name means the
+// value is flattened into the prefix.
+func txrepJoin(prefix, name string) string {
+	if name == "" {
+		return prefix
+	}
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// txrepFieldName converts a generated Go field name back to the name
+// in the XDR definition (SourceAccount => sourceAccount,
+// SponsoredId => sponsoredID).
+func txrepFieldName(name string) string {
+	if len(name) > 2 && strings.HasSuffix(name, "Id") {
+		name = name[:len(name)-2] + "ID"
+	}
+	r := []rune(name)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// txrepArmName returns the name of a union arm.  SEP11 puts the tx and
+// signatures of regular envelopes at the top level and calls inner
+// transactions of fee bumps "tx".
+func txrepArmName(t reflect.Type, arm string) string {
+	switch t {
+	case txrepEnvelopeType:
+		if arm == "V0" || arm == "V1" {
+			return ""
+		}
+	case txrepInnerTxType:
+		return "tx"
+	}
+	return txrepFieldName(arm)
+}
+
+// txrepEnumName converts a generated enum name back to the name in the
+// XDR definition (OperationTypePathPaymentStrictSend =>
+// PATH_PAYMENT_STRICT_SEND).
+func txrepEnumName(t reflect.Type, goName string) string {
+	goName = strings.TrimPrefix(goName, t.Name())
+	var b strings.Builder
+	var prev rune
+	for i, r := range goName {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		prev = r
+	}
+	return b.String()
+}
+
+// txrepEnumValue finds the value of an enum from its XDR name.  All the
+// enums in a transaction envelope have small values.
+func txrepEnumValue(t reflect.Type, name string) (int32, bool) {
+	zero := reflect.Zero(t).Interface().(txrepEnum)
+	for i := int32(-256); i <= 256; i++ {
+		if !zero.ValidEnum(i) {
+			continue
+		}
+		e := reflect.ValueOf(i).Convert(t).Interface().(txrepEnum)
+		if txrepEnumName(t, e.String()) == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (t *txrepEncoding) encode(key string, v reflect.Value) error {
+	handled, err := t.encodeSpecial(key, v)
+	if err != nil || handled {
+		return err
+	}
+
+	typ := v.Type()
+	if typ.Implements(txrepEnumType) && typ.Kind() == reflect.Int32 {
+		t.set(key, txrepEnumName(typ, v.Interface().(txrepEnum).String()))
+		return nil
+	}
+	if typ.Kind() == reflect.Struct && typ.Implements(txrepUnionType) {
+		return t.encodeUnion(key, v)
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		// pointers outside of union arms are optional values
+		if v.IsNil() {
+			t.set(txrepJoin(key, "_present"), "false")
+			return nil
+		}
+		t.set(txrepJoin(key, "_present"), "true")
+		return t.encode(key, v.Elem())
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			name := txrepJoin(key, txrepFieldName(f.Name))
+			if typ == txrepTransactionV0Type && f.Name == "SourceAccountEd25519" {
+				raw := v.Field(i).Interface().(xdr.Uint256)
+				address, err := strkey.Encode(strkey.VersionByteAccountID, raw[:])
+				if err != nil {
+					return err
+				}
+				t.set(name, address)
+				continue
+			}
+			if err := t.encode(name, v.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			t.set(key, hex.EncodeToString(b))
+			return nil
+		}
+		t.set(txrepJoin(key, "len"), strconv.Itoa(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if err := t.encode(fmt.Sprintf("%s[%d]", key, i), v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		t.set(key, strconv.FormatInt(v.Int(), 10))
+		return nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		t.set(key, strconv.FormatUint(v.Uint(), 10))
+		return nil
+	case reflect.Bool:
+		t.set(key, strconv.FormatBool(v.Bool()))
+		return nil
+	case reflect.String:
+		t.set(key, strconv.Quote(v.String()))
+		return nil
+	}
+
+	return fmt.Errorf("txrep: unsupported type %s at %s", typ, key)
+}
+
+func (t *txrepEncoding) encodeUnion(key string, v reflect.Value) error {
+	u := v.Interface().(txrepUnion)
+	swName := u.SwitchFieldName()
+	sw := v.FieldByName(swName)
+	if err := t.encode(txrepJoin(key, txrepFieldName(swName)), sw); err != nil {
+		return err
+	}
+	arm, ok := u.ArmForSwitch(int32(sw.Int()))
+	if !ok {
+		return ErrTxrepInvalidValue{Key: txrepJoin(key, txrepFieldName(swName))}
+	}
+	if arm == "" {
+		return nil
+	}
+	armValue := v.FieldByName(arm)
+	if armValue.IsNil() {
+		return ErrTxrepMissingField{Key: txrepJoin(key, txrepArmName(v.Type(), arm))}
+	}
+	return t.encode(txrepJoin(key, txrepArmName(v.Type(), arm)), armValue.Elem())
+}
+
+// encodeSpecial encodes the types that SEP11 writes in a compact form:
+// accounts and signer keys as strkeys, assets as XLM or CODE:ISSUER.
+func (t *txrepEncoding) encodeSpecial(key string, v reflect.Value) (bool, error) {
+	switch v.Type() {
+	case txrepAccountIDType:
+		aid := v.Interface().(xdr.AccountId)
+		address, err := aid.GetAddress()
+		if err != nil {
+			return true, err
+		}
+		t.set(key, address)
+		return true, nil
+	case txrepPublicKeyType:
+		aid := xdr.AccountId(v.Interface().(xdr.PublicKey))
+		address, err := aid.GetAddress()
+		if err != nil {
+			return true, err
+		}
+		t.set(key, address)
+		return true, nil
+	case txrepMuxedAccountType:
+		m := v.Interface().(xdr.MuxedAccount)
+		address, err := m.GetAddress()
+		if err != nil {
+			return true, err
+		}
+		t.set(key, address)
+		return true, nil
+	case txrepSignerKeyType:
+		skey := v.Interface().(xdr.SignerKey)
+		address, err := skey.GetAddress()
+		if err != nil {
+			return true, err
+		}
+		t.set(key, address)
+		return true, nil
+	case txrepAssetType:
+		s, err := txrepAssetString(v.Interface().(xdr.Asset))
+		if err != nil {
+			return true, err
+		}
+		t.set(key, s)
+		return true, nil
+	case txrepChangeTrustAssetType:
+		a := v.Interface().(xdr.ChangeTrustAsset)
+		if a.Type == xdr.AssetTypeAssetTypePoolShare {
+			return false, nil
+		}
+		s, err := txrepAssetString(a.ToAsset())
+		if err != nil {
+			return true, err
+		}
+		t.set(key, s)
+		return true, nil
+	case txrepTrustLineAssetType:
+		a := v.Interface().(xdr.TrustLineAsset)
+		if a.Type == xdr.AssetTypeAssetTypePoolShare {
+			return false, nil
+		}
+		s, err := txrepAssetString(a.ToAsset())
+		if err != nil {
+			return true, err
+		}
+		t.set(key, s)
+		return true, nil
+	case txrepAssetCodeType:
+		code := v.Interface().(xdr.AssetCode)
+		switch code.Type {
+		case xdr.AssetTypeAssetTypeCreditAlphanum4:
+			t.set(key, strings.TrimRight(string(code.AssetCode4[:]), "\x00"))
+		case xdr.AssetTypeAssetTypeCreditAlphanum12:
+			t.set(key, strings.TrimRight(string(code.AssetCode12[:]), "\x00"))
+		default:
+			return true, ErrTxrepInvalidValue{Key: key}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func txrepAssetString(a xdr.Asset) (string, error) {
+	var typ xdr.AssetType
+	var code, issuer string
+	if err := a.Extract(&typ, &code, &issuer); err != nil {
+		return "", err
+	}
+	if typ == xdr.AssetTypeAssetTypeNative {
+		return "XLM", nil
+	}
+	return code + ":" + issuer, nil
+}
+
+func txrepParseAsset(s string) (xdr.Asset, error) {
+	if s == "XLM" || s == "native" {
+		return xdr.Asset{Type: xdr.AssetTypeAssetTypeNative}, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return xdr.Asset{}, strconv.ErrSyntax
+	}
+	return xdr.NewCreditAsset(parts[0], parts[1])
+}
+
+// txrepDecoder decodes values into a transaction envelope, keeping
+// track of the fields that it used.
+type txrepDecoder struct {
+	values map[string]string
+	used   map[string]bool
+}
+
+func (d *txrepDecoder) get(key string) (string, error) {
+	value, ok := d.values[key]
+	if !ok {
+		return "", ErrTxrepMissingField{Key: key}
+	}
+	d.used[key] = true
+	return value, nil
+}
+
+func (d *txrepDecoder) has(key string) bool {
+	_, ok := d.values[key]
+	return ok
+}
+
+func (d *txrepDecoder) decode(key string, v reflect.Value) error {
+	handled, err := d.decodeSpecial(key, v)
+	if err != nil || handled {
+		return err
+	}
+
+	typ := v.Type()
+	if typ.Implements(txrepEnumType) && typ.Kind() == reflect.Int32 {
+		s, err := d.get(key)
+		if err != nil {
+			return err
+		}
+		n, ok := txrepEnumValue(typ, s)
+		if !ok {
+			return ErrTxrepInvalidValue{Key: key}
+		}
+		v.SetInt(int64(n))
+		return nil
+	}
+	if typ.Kind() == reflect.Struct && typ.Implements(txrepUnionType) {
+		return d.decodeUnion(key, v)
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		presentKey := txrepJoin(key, "_present")
+		s, err := d.get(presentKey)
+		if err != nil {
+			return err
+		}
+		present, err := strconv.ParseBool(s)
+		if err != nil {
+			return ErrTxrepInvalidValue{Key: presentKey}
+		}
+		if !present {
+			v.Set(reflect.Zero(typ))
+			return nil
+		}
+		elem := reflect.New(typ.Elem())
+		if err := d.decode(key, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			name := txrepJoin(key, txrepFieldName(f.Name))
+			if typ == txrepTransactionV0Type && f.Name == "SourceAccountEd25519" {
+				s, err := d.get(name)
+				if err != nil {
+					return err
+				}
+				raw, err := strkey.Decode(strkey.VersionByteAccountID, s)
+				if err != nil {
+					return ErrTxrepInvalidValue{Key: name}
+				}
+				var u xdr.Uint256
+				copy(u[:], raw)
+				v.Field(i).Set(reflect.ValueOf(u))
+				continue
+			}
+			if err := d.decode(name, v.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			s, err := d.get(key)
+			if err != nil {
+				return err
+			}
+			b, err := hex.DecodeString(s)
+			if err != nil {
+				return ErrTxrepInvalidValue{Key: key}
+			}
+			if typ.Kind() == reflect.Array {
+				if len(b) != typ.Len() {
+					return ErrTxrepInvalidValue{Key: key}
+				}
+				reflect.Copy(v, reflect.ValueOf(b))
+				return nil
+			}
+			v.Set(reflect.ValueOf(b).Convert(typ))
+			return nil
+		}
+		if typ.Kind() == reflect.Array {
+			return fmt.Errorf("txrep: unsupported type %s at %s", typ, key)
+		}
+		lenKey := txrepJoin(key, "len")
+		s, err := d.get(lenKey)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > txrepMaxLen {
+			return ErrTxrepInvalidValue{Key: lenKey}
+		}
+		slice := reflect.MakeSlice(typ, n, n)
+		for i := 0; i < n; i++ {
+			if err := d.decode(fmt.Sprintf("%s[%d]", key, i), slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s, err := d.get(key)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.ParseInt(s, 10, typ.Bits())
+		if err != nil {
+			return ErrTxrepInvalidValue{Key: key}
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s, err := d.get(key)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.ParseUint(s, 10, typ.Bits())
+		if err != nil {
+			return ErrTxrepInvalidValue{Key: key}
+		}
+		v.SetUint(n)
+		return nil
+	case reflect.Bool:
+		s, err := d.get(key)
+		if err != nil {
+			return err
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return ErrTxrepInvalidValue{Key: key}
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.String:
+		s, err := d.get(key)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+		return nil
+	}
+
+	return fmt.Errorf("txrep: unsupported type %s at %s", typ, key)
+}
+
+func (d *txrepDecoder) decodeUnion(key string, v reflect.Value) error {
+	swName := v.Interface().(txrepUnion).SwitchFieldName()
+	if err := d.decode(txrepJoin(key, txrepFieldName(swName)), v.FieldByName(swName)); err != nil {
+		return err
+	}
+	u := v.Interface().(txrepUnion)
+	arm, ok := u.ArmForSwitch(int32(v.FieldByName(swName).Int()))
+	if !ok {
+		return ErrTxrepInvalidValue{Key: txrepJoin(key, txrepFieldName(swName))}
+	}
+	if arm == "" {
+		return nil
+	}
+	armValue := v.FieldByName(arm)
+	elem := reflect.New(armValue.Type().Elem())
+	if err := d.decode(txrepJoin(key, txrepArmName(v.Type(), arm)), elem.Elem()); err != nil {
+		return err
+	}
+	armValue.Set(elem)
+	return nil
+}
+
+func (d *txrepDecoder) decodeSpecial(key string, v reflect.Value) (bool, error) {
+	typ := v.Type()
+	switch typ {
+	case txrepAccountIDType, txrepPublicKeyType, txrepMuxedAccountType, txrepSignerKeyType, txrepAssetType, txrepAssetCodeType:
+	case txrepChangeTrustAssetType, txrepTrustLineAssetType:
+		// pool shares are written out in full
+		if !d.has(key) {
+			return false, nil
+		}
+	default:
+		return false, nil
+	}
+
+	s, err := d.get(key)
+	if err != nil {
+		return true, err
+	}
+
+	var out interface{}
+	switch typ {
+	case txrepAccountIDType:
+		var aid xdr.AccountId
+		err = aid.SetAddress(s)
+		out = aid
+	case txrepPublicKeyType:
+		var aid xdr.AccountId
+		err = aid.SetAddress(s)
+		out = xdr.PublicKey(aid)
+	case txrepMuxedAccountType:
+		var m xdr.MuxedAccount
+		err = m.SetAddress(s)
+		out = m
+	case txrepSignerKeyType:
+		var skey xdr.SignerKey
+		err = skey.SetAddress(s)
+		out = skey
+	case txrepAssetType:
+		out, err = txrepParseAsset(s)
+	case txrepChangeTrustAssetType:
+		var a xdr.Asset
+		a, err = txrepParseAsset(s)
+		out = a.ToChangeTrustAsset()
+	case txrepTrustLineAssetType:
+		var a xdr.Asset
+		a, err = txrepParseAsset(s)
+		out = a.ToTrustLineAsset()
+	case txrepAssetCodeType:
+		out, err = xdr.NewAssetCodeFromString(s)
+	}
+	if err != nil {
+		return true, ErrTxrepInvalidValue{Key: key}
+	}
+	v.Set(reflect.ValueOf(out))
+	return true, nil
+}
@@ -0,0 +1,267 @@
+package stellarnet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	txrepTestSource  = "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO"
+	txrepTestDest    = "GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB"
+	txrepTestIssuer  = "GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX"
+	txrepTestPayment = `type: ENVELOPE_TYPE_TX
+tx.sourceAccount: GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO
+tx.fee: 100
+tx.seqNum: 46489056724385793
+tx.timeBounds._present: true
+tx.timeBounds.minTime: 1535756672
+tx.timeBounds.maxTime: 1567292672
+tx.memo.type: MEMO_TEXT
+tx.memo.text: "Enjoy this transaction"
+tx.operations.len: 1
+tx.operations[0].sourceAccount._present: false
+tx.operations[0].body.type: PAYMENT
+tx.operations[0].body.paymentOp.destination: GBZX4364PEPQTDICMIQDZ56K4T75QZCR4NBEYKO6PDRJAHZKGUOJPCXB
+tx.operations[0].body.paymentOp.asset: USD:GDUKMGUGDZQK6YHYA5Z6AY2G4XDSZPSZ3SW5UN3ARVMO6QSRDWP5YLEX
+tx.operations[0].body.paymentOp.amount: 400004000
+tx.ext.v: 0
+signatures.len: 1
+signatures[0].hint: 4aa07ed0
+signatures[0].signature: 0a1b2c3d4e5f
+`
+)
+
+func TestTxrepPayment(t *testing.T) {
+	txEnv, err := EnvelopeFromTxrep(txrepTestPayment)
+	require.NoError(t, err)
+	require.Equal(t, xdr.EnvelopeTypeEnvelopeTypeTx, txEnv.Type)
+	require.Equal(t, int64(46489056724385793), txEnv.SeqNum())
+	require.Equal(t, "Enjoy this transaction", *txEnv.Memo().Text)
+	ops := txEnv.Operations()
+	require.Len(t, ops, 1)
+	require.Equal(t, txrepTestDest, ops[0].Body.PaymentOp.Destination.Address())
+	require.Equal(t, xdr.Int64(400004000), ops[0].Body.PaymentOp.Amount)
+	require.Len(t, txEnv.Signatures(), 1)
+
+	txrep, err := TxrepFromEnvelope(txEnv)
+	require.NoError(t, err)
+	require.Equal(t, txrepTestPayment, txrep)
+}
+
+func TestTxrepComments(t *testing.T) {
+	in := strings.Replace(txrepTestPayment, "amount: 400004000", "amount: 400004000 (40.0004e7)", 1)
+	in = strings.Replace(in, `"Enjoy this transaction"`, `"Enjoy \"this\" transaction" # quoted`, 1)
+	in = "# hand edited\n\n" + in
+	txEnv, err := EnvelopeFromTxrep(in)
+	require.NoError(t, err)
+	require.Equal(t, `Enjoy "this" transaction`, *txEnv.Memo().Text)
+	require.Equal(t, xdr.Int64(400004000), txEnv.Operations()[0].Body.PaymentOp.Amount)
+}
+
+func TestTxrepErrors(t *testing.T) {
+	_, err := EnvelopeFromTxrep(strings.Replace(txrepTestPayment, "tx.fee: 100\n", "", 1))
+	require.Equal(t, ErrTxrepMissingField{Key: "tx.fee"}, err)
+
+	_, err = EnvelopeFromTxrep(strings.Replace(txrepTestPayment, "tx.fee: 100", "tx.fee: lots", 1))
+	require.Equal(t, ErrTxrepInvalidValue{Key: "tx.fee"}, err)
+
+	_, err = EnvelopeFromTxrep(strings.Replace(txrepTestPayment, "body.type: PAYMENT", "body.type: PAYMENTS", 1))
+	require.Equal(t, ErrTxrepInvalidValue{Key: "tx.operations[0].body.type"}, err)
+
+	_, err = EnvelopeFromTxrep(strings.Replace(txrepTestPayment, "paymentOp.destination: G", "paymentOp.destination: X", 1))
+	require.Equal(t, ErrTxrepInvalidValue{Key: "tx.operations[0].body.paymentOp.destination"}, err)
+
+	_, err = EnvelopeFromTxrep(txrepTestPayment + "tx.feee: 100\n")
+	require.Equal(t, ErrTxrepUnknownField{Key: "tx.feee"}, err)
+
+	_, err = EnvelopeFromTxrep(txrepTestPayment + "tx.fee: 100\n")
+	require.Equal(t, ErrTxrepInvalidLine{Line: 20}, err)
+
+	_, err = EnvelopeFromTxrep("type ENVELOPE_TYPE_TX")
+	require.Equal(t, ErrTxrepInvalidLine{Line: 1}, err)
+
+	_, err = EnvelopeFromTxrep(strings.Replace(txrepTestPayment, "tx.operations.len: 1", "tx.operations.len: 1000000", 1))
+	require.Equal(t, ErrTxrepInvalidValue{Key: "tx.operations.len"}, err)
+}
+
+// txrepTestOps returns an operation of every type.
+func txrepTestOps(t *testing.T) []xdr.Operation {
+	source := xdr.MustAddress(txrepTestSource)
+	dest := xdr.MustAddress(txrepTestDest)
+	muxed := xdr.MustMuxedAddress(txrepTestDest)
+	usd := xdr.MustNewCreditAsset("USD", txrepTestIssuer)
+	eurt := xdr.MustNewCreditAsset("EURTOKEN", txrepTestIssuer)
+	native := xdr.MustNewNativeAsset()
+	price := xdr.Price{N: 3, D: 7}
+	var hash xdr.Hash
+	for i := range hash {
+		hash[i] = byte(i)
+	}
+	balanceID := xdr.ClaimableBalanceId{Type: xdr.ClaimableBalanceIdTypeClaimableBalanceIdTypeV0, V0: &hash}
+	weight := xdr.Uint32(10)
+	homeDomain := xdr.String32("example.com")
+	dataValue := xdr.DataValue("hello")
+	absBefore := xdr.Int64(1600000000)
+	relBefore := xdr.Int64(3600)
+	notPredicate := &xdr.ClaimPredicate{Type: xdr.ClaimPredicateTypeClaimPredicateBeforeRelativeTime, RelBefore: &relBefore}
+	andPredicates := []xdr.ClaimPredicate{
+		{Type: xdr.ClaimPredicateTypeClaimPredicateBeforeAbsoluteTime, AbsBefore: &absBefore},
+		{Type: xdr.ClaimPredicateTypeClaimPredicateNot, NotPredicate: &notPredicate},
+	}
+	signerKey := xdr.MustSigner(txrepTestIssuer)
+	poolShare := xdr.ChangeTrustAsset{
+		Type: xdr.AssetTypeAssetTypePoolShare,
+		LiquidityPool: &xdr.LiquidityPoolParameters{
+			Type: xdr.LiquidityPoolTypeLiquidityPoolConstantProduct,
+			ConstantProduct: &xdr.LiquidityPoolConstantProductParameters{
+				AssetA: native,
+				AssetB: usd,
+				Fee:    xdr.LiquidityPoolFeeV18,
+			},
+		},
+	}
+
+	bodies := []xdr.OperationBody{
+		{Type: xdr.OperationTypeCreateAccount, CreateAccountOp: &xdr.CreateAccountOp{Destination: dest, StartingBalance: 10000000}},
+		{Type: xdr.OperationTypePayment, PaymentOp: &xdr.PaymentOp{Destination: muxed, Asset: native, Amount: 1}},
+		{Type: xdr.OperationTypePathPaymentStrictReceive, PathPaymentStrictReceiveOp: &xdr.PathPaymentStrictReceiveOp{SendAsset: native, SendMax: 100, Destination: muxed, DestAsset: usd, DestAmount: 50, Path: []xdr.Asset{eurt}}},
+		{Type: xdr.OperationTypeManageSellOffer, ManageSellOfferOp: &xdr.ManageSellOfferOp{Selling: usd, Buying: native, Amount: 20, Price: price, OfferId: 12}},
+		{Type: xdr.OperationTypeCreatePassiveSellOffer, CreatePassiveSellOfferOp: &xdr.CreatePassiveSellOfferOp{Selling: usd, Buying: eurt, Amount: 20, Price: price}},
+		{Type: xdr.OperationTypeSetOptions, SetOptionsOp: &xdr.SetOptionsOp{InflationDest: &dest, MasterWeight: &weight, HomeDomain: &homeDomain, Signer: &xdr.Signer{Key: signerKey, Weight: 1}}},
+		{Type: xdr.OperationTypeChangeTrust, ChangeTrustOp: &xdr.ChangeTrustOp{Line: usd.ToChangeTrustAsset(), Limit: 1000}},
+		{Type: xdr.OperationTypeAllowTrust, AllowTrustOp: &xdr.AllowTrustOp{Trustor: dest, Asset: xdr.MustNewAssetCodeFromString("EURTOKEN"), Authorize: 1}},
+		{Type: xdr.OperationTypeAccountMerge, Destination: &muxed},
+		{Type: xdr.OperationTypeInflation},
+		{Type: xdr.OperationTypeManageData, ManageDataOp: &xdr.ManageDataOp{DataName: "name", DataValue: &dataValue}},
+		{Type: xdr.OperationTypeBumpSequence, BumpSequenceOp: &xdr.BumpSequenceOp{BumpTo: 12345}},
+		{Type: xdr.OperationTypeManageBuyOffer, ManageBuyOfferOp: &xdr.ManageBuyOfferOp{Selling: native, Buying: usd, BuyAmount: 30, Price: price}},
+		{Type: xdr.OperationTypePathPaymentStrictSend, PathPaymentStrictSendOp: &xdr.PathPaymentStrictSendOp{SendAsset: usd, SendAmount: 100, Destination: muxed, DestAsset: native, DestMin: 90}},
+		{Type: xdr.OperationTypeCreateClaimableBalance, CreateClaimableBalanceOp: &xdr.CreateClaimableBalanceOp{Asset: usd, Amount: 5, Claimants: []xdr.Claimant{
+			{Type: xdr.ClaimantTypeClaimantTypeV0, V0: &xdr.ClaimantV0{Destination: dest, Predicate: xdr.ClaimPredicate{Type: xdr.ClaimPredicateTypeClaimPredicateAnd, AndPredicates: &andPredicates}}},
+			{Type: xdr.ClaimantTypeClaimantTypeV0, V0: &xdr.ClaimantV0{Destination: source, Predicate: xdr.ClaimPredicate{Type: xdr.ClaimPredicateTypeClaimPredicateUnconditional}}},
+		}}},
+		{Type: xdr.OperationTypeClaimClaimableBalance, ClaimClaimableBalanceOp: &xdr.ClaimClaimableBalanceOp{BalanceId: balanceID}},
+		{Type: xdr.OperationTypeBeginSponsoringFutureReserves, BeginSponsoringFutureReservesOp: &xdr.BeginSponsoringFutureReservesOp{SponsoredId: dest}},
+		{Type: xdr.OperationTypeEndSponsoringFutureReserves},
+		{Type: xdr.OperationTypeRevokeSponsorship, RevokeSponsorshipOp: &xdr.RevokeSponsorshipOp{Type: xdr.RevokeSponsorshipTypeRevokeSponsorshipLedgerEntry, LedgerKey: &xdr.LedgerKey{Type: xdr.LedgerEntryTypeAccount, Account: &xdr.LedgerKeyAccount{AccountId: dest}}}},
+		{Type: xdr.OperationTypeRevokeSponsorship, RevokeSponsorshipOp: &xdr.RevokeSponsorshipOp{Type: xdr.RevokeSponsorshipTypeRevokeSponsorshipSigner, Signer: &xdr.RevokeSponsorshipOpSigner{AccountId: dest, SignerKey: signerKey}}},
+		{Type: xdr.OperationTypeClawback, ClawbackOp: &xdr.ClawbackOp{Asset: usd, From: muxed, Amount: 3}},
+		{Type: xdr.OperationTypeClawbackClaimableBalance, ClawbackClaimableBalanceOp: &xdr.ClawbackClaimableBalanceOp{BalanceId: balanceID}},
+		{Type: xdr.OperationTypeSetTrustLineFlags, SetTrustLineFlagsOp: &xdr.SetTrustLineFlagsOp{Trustor: dest, Asset: usd, ClearFlags: 1, SetFlags: 4}},
+		{Type: xdr.OperationTypeChangeTrust, ChangeTrustOp: &xdr.ChangeTrustOp{Line: poolShare, Limit: 1000}},
+		{Type: xdr.OperationTypeLiquidityPoolDeposit, LiquidityPoolDepositOp: &xdr.LiquidityPoolDepositOp{LiquidityPoolId: xdr.PoolId(hash), MaxAmountA: 10, MaxAmountB: 20, MinPrice: price, MaxPrice: price}},
+		{Type: xdr.OperationTypeLiquidityPoolWithdraw, LiquidityPoolWithdrawOp: &xdr.LiquidityPoolWithdrawOp{LiquidityPoolId: xdr.PoolId(hash), Amount: 10, MinAmountA: 1, MinAmountB: 2}},
+	}
+
+	ops := make([]xdr.Operation, len(bodies))
+	for i, body := range bodies {
+		ops[i].Body = body
+	}
+	opSource := xdr.MustMuxedAddress(txrepTestSource)
+	ops[1].SourceAccount = &opSource
+	return ops
+}
+
+func txrepRoundTrip(t *testing.T, txEnv xdr.TransactionEnvelope) string {
+	txrep, err := TxrepFromEnvelope(txEnv)
+	require.NoError(t, err)
+	decoded, err := EnvelopeFromTxrep(txrep)
+	require.NoError(t, err)
+
+	want, err := xdr.MarshalBase64(txEnv)
+	require.NoError(t, err)
+	got, err := xdr.MarshalBase64(decoded)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	return txrep
+}
+
+func TestTxrepAllOperations(t *testing.T) {
+	ops := txrepTestOps(t)
+	memoID := xdr.Uint64(99)
+	txEnv := xdr.TransactionEnvelope{
+		Type: xdr.EnvelopeTypeEnvelopeTypeTx,
+		V1: &xdr.TransactionV1Envelope{
+			Tx: xdr.Transaction{
+				SourceAccount: xdr.MustMuxedAddress(txrepTestSource),
+				Fee:           xdr.Uint32(100 * len(ops)),
+				SeqNum:        101,
+				Memo:          xdr.Memo{Type: xdr.MemoTypeMemoId, Id: &memoID},
+				Operations:    ops,
+			},
+		},
+	}
+
+	txrep := txrepRoundTrip(t, txEnv)
+	for _, s := range []string{
+		"tx.memo.id: 99\n",
+		"tx.operations[1].sourceAccount._present: true\n",
+		"tx.operations[1].sourceAccount: " + txrepTestSource + "\n",
+		"tx.operations[7].body.allowTrustOp.asset: EURTOKEN\n",
+		"tx.operations[8].body.destination: " + txrepTestDest + "\n",
+		"tx.operations[9].body.type: INFLATION\n",
+		"tx.operations[10].body.manageDataOp.dataValue: 68656c6c6f\n",
+		"tx.operations[14].body.createClaimableBalanceOp.claimants[0].v0.predicate.andPredicates[1].notPredicate._present: true\n",
+		"tx.operations[16].body.beginSponsoringFutureReservesOp.sponsoredID: " + txrepTestDest + "\n",
+		"tx.operations[18].body.revokeSponsorshipOp.ledgerKey.account.accountID: " + txrepTestDest + "\n",
+		"tx.operations[23].body.changeTrustOp.line.type: ASSET_TYPE_POOL_SHARE\n",
+		"tx.operations[23].body.changeTrustOp.line.liquidityPool.constantProduct.assetA: XLM\n",
+		"tx.operations[24].body.type: LIQUIDITY_POOL_DEPOSIT\n",
+	} {
+		require.Contains(t, txrep, s)
+	}
+}
+
+func TestTxrepFeeBump(t *testing.T) {
+	inner := xdr.TransactionV1Envelope{
+		Tx: xdr.Transaction{
+			SourceAccount: xdr.MustMuxedAddress(txrepTestSource),
+			Fee:           100,
+			SeqNum:        7,
+			Memo:          xdr.Memo{Type: xdr.MemoTypeMemoNone},
+			Operations:    txrepTestOps(t)[:2],
+		},
+		Signatures: []xdr.DecoratedSignature{{Hint: xdr.SignatureHint{1, 2, 3, 4}, Signature: xdr.Signature{5, 6, 7}}},
+	}
+	txEnv := xdr.TransactionEnvelope{
+		Type: xdr.EnvelopeTypeEnvelopeTypeTxFeeBump,
+		FeeBump: &xdr.FeeBumpTransactionEnvelope{
+			Tx: xdr.FeeBumpTransaction{
+				FeeSource: xdr.MustMuxedAddress(txrepTestDest),
+				Fee:       400,
+				InnerTx: xdr.FeeBumpTransactionInnerTx{
+					Type: xdr.EnvelopeTypeEnvelopeTypeTx,
+					V1:   &inner,
+				},
+			},
+		},
+	}
+
+	txrep := txrepRoundTrip(t, txEnv)
+	for _, s := range []string{
+		"type: ENVELOPE_TYPE_TX_FEE_BUMP\n",
+		"feeBump.tx.feeSource: " + txrepTestDest + "\n",
+		"feeBump.tx.innerTx.type: ENVELOPE_TYPE_TX\n",
+		"feeBump.tx.innerTx.tx.tx.sourceAccount: " + txrepTestSource + "\n",
+		"feeBump.tx.innerTx.tx.signatures[0].hint: 01020304\n",
+		"feeBump.tx.ext.v: 0\n",
+		"feeBump.signatures.len: 0\n",
+	} {
+		require.Contains(t, txrep, s)
+	}
+}
+
+func TestTxrepV0(t *testing.T) {
+	var txEnv xdr.TransactionEnvelope
+	err := xdr.SafeUnmarshalBase64("AAAAAP+yw+ZEuNg533pUmwlYxfrq6/BoMJqiJ8vuQhf6rHWmAAAAZAB8NHAAAAABAAAAAAAAAAAAAAABAAAAAAAAAAEAAAAA/7LD5kS42DnfelSbCVjF+urr8GgwmqIny+5CF/qsdaYAAAAAAAAAAACYloAAAAAAAAAAAA==", &txEnv)
+	require.NoError(t, err)
+
+	txrep := txrepRoundTrip(t, txEnv)
+	sourceAccount := txEnv.SourceAccount()
+	require.Contains(t, txrep, "type: ENVELOPE_TYPE_TX_V0\n")
+	require.Contains(t, txrep, "tx.sourceAccountEd25519: "+sourceAccount.Address()+"\n")
+}